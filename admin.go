@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"ohohestudio/sogorro/libs"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// stationCacheTTL bounds how long a station read by ID is reused. The admin API only drops the
+// station from the cache of the instance serving it, the other instances and the changes made
+// outside of the admin API, like the daily import, show up after it.
+const stationCacheTTL = 5 * time.Minute
+
+// stationEditor changes the stations along with an audit record of each change, it is implemented
+// by libs.StationRepository.
+type stationEditor interface {
+	Get(ctx context.Context, id string) (libs.GoStation, error)
+	Update(ctx context.Context, id string, audit libs.StationAudit, change func(before *libs.GoStation) (*libs.GoStation, error)) (libs.StationAudit, error)
+}
+
+// stationInput is the station as it is stored, the distance and the travel of a search are left out
+// so an admin can't write them.
+type stationInput struct {
+	Id        string  `json:"id"`
+	Address   string  `json:"address"`
+	City      string  `json:"city"`
+	District  string  `json:"district"`
+	Location  string  `json:"location"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	VMType    int64   `json:"vmType"`
+	State     int64   `json:"state"`
+}
+
+func inputOf(station libs.GoStation) stationInput {
+	return stationInput{
+		Id:        station.Id,
+		Address:   station.Address,
+		City:      station.City,
+		District:  station.District,
+		Location:  station.Location,
+		Latitude:  station.Latitude,
+		Longitude: station.Longitude,
+		VMType:    station.VMType,
+		State:     station.State,
+	}
+}
+
+func (i stationInput) station() libs.GoStation {
+	return libs.GoStation{
+		Id:        i.Id,
+		Address:   i.Address,
+		City:      i.City,
+		District:  i.District,
+		Location:  i.Location,
+		Latitude:  i.Latitude,
+		Longitude: i.Longitude,
+		VMType:    i.VMType,
+		State:     i.State,
+	}
+}
+
+// invalidStationError rejects the body of an admin request, it is answered with a bad request.
+type invalidStationError struct {
+	message string
+}
+
+func (e invalidStationError) Error() string {
+	return e.message
+}
+
+// cachedStations reads the stations by ID through the station cache, the nearby searches always
+// query the stations.
+type cachedStations struct {
+	stationFinder
+	cache *libs.StationCache
+}
+
+func (s cachedStations) Get(ctx context.Context, id string) (libs.GoStation, error) {
+	if station, ok := s.cache.Get(id); ok {
+		return station, nil
+	}
+
+	station, err := s.stationFinder.Get(ctx, id)
+	if err != nil {
+		return station, err
+	}
+	s.cache.Put(station)
+
+	return station, nil
+}
+
+// invalidateStation drops the station from the in-memory caches after it changed.
+func (a *App) invalidateStation(id string) {
+	if a.stationCache != nil {
+		a.stationCache.Invalidate(id)
+	}
+}
+
+type adminKey struct{}
+
+// requireAdmin only lets through the requests bearing a Google ID token issued for the admin
+// audience to one of the admin accounts.
+func (a *App) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := libs.LoggerFromContext(r.Context())
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing bearer token"})
+			return
+		}
+
+		email, err := a.verifyIDToken(r.Context(), token, a.config.AdminAudience, a.config.AdminEmails)
+		if errors.Is(err, errUnknownAccount) {
+			logger.Warn("rejected admin request of unknown account", "email", email)
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "account is not an admin"})
+			return
+		}
+		if err != nil {
+			logger.Warn("rejected admin request with invalid ID token", "error", err)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid ID token"})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), adminKey{}, email)
+		ctx = libs.WithLogger(ctx, logger.With("admin", email))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+var (
+	errInvalidIDToken = errors.New("invalid ID token")
+	errUnknownAccount = errors.New("account is not allowed")
+)
+
+// verifyIDToken returns the email of the account a Google ID token was issued to for the audience,
+// the account must be verified and one of the accounts.
+func (a *App) verifyIDToken(ctx context.Context, token, audience string, accounts []string) (string, error) {
+	payload, err := a.validateIDToken(ctx, token, audience)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+
+	email, _ := payload.Claims["email"].(string)
+	verified, _ := payload.Claims["email_verified"].(bool)
+	if !verified || email == "" {
+		return email, errUnknownAccount
+	}
+	for _, account := range accounts {
+		if strings.EqualFold(account, email) {
+			return email, nil
+		}
+	}
+
+	return email, errUnknownAccount
+}
+
+func (a *App) getStation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	station, err := a.stationAdmin.Get(r.Context(), id)
+	if err != nil {
+		a.writeStationError(w, r, "get", id, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, inputOf(station))
+}
+
+// putStation creates or replaces the station with the one of the body.
+func (a *App) putStation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "failed to read body"})
+		return
+	}
+
+	var input stationInput
+	if err := decodeStrict(body, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if input.Id != "" && input.Id != id {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id doesn't match the station of the URL"})
+		return
+	}
+	input.Id = id
+
+	station := input.station()
+	if err := station.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	audit, ok := a.updateStation(w, r, "put", id, func(before *libs.GoStation) (*libs.GoStation, error) {
+		return &station, nil
+	})
+	if !ok {
+		return
+	}
+
+	status := http.StatusOK
+	if audit.Before == nil {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, inputOf(station))
+}
+
+// patchStation changes the fields of the body, like the state to close a station.
+func (a *App) patchStation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "failed to read body"})
+		return
+	}
+
+	// the body applies to the station read in the transaction, it is decoded again on a retry
+	audit, ok := a.updateStation(w, r, "patch", id, func(before *libs.GoStation) (*libs.GoStation, error) {
+		if before == nil {
+			return nil, libs.ErrStationNotFound
+		}
+
+		input := inputOf(*before)
+		if err := decodeStrict(body, &input); err != nil {
+			return nil, invalidStationError{err.Error()}
+		}
+		if input.Id != before.Id {
+			return nil, invalidStationError{"id can't be changed"}
+		}
+
+		station := input.station()
+		if err := station.Validate(); err != nil {
+			return nil, invalidStationError{err.Error()}
+		}
+
+		return &station, nil
+	})
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, inputOf(*audit.After))
+}
+
+func (a *App) deleteStation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	_, ok := a.updateStation(w, r, "delete", id, func(before *libs.GoStation) (*libs.GoStation, error) {
+		if before == nil {
+			return nil, libs.ErrStationNotFound
+		}
+
+		return nil, nil
+	})
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateStation changes the station in a transaction with the audit record of the change, it
+// answers the request when the change fails.
+func (a *App) updateStation(w http.ResponseWriter, r *http.Request, action, id string, change func(before *libs.GoStation) (*libs.GoStation, error)) (libs.StationAudit, bool) {
+	audit := libs.StationAudit{Action: action, Actor: adminFromContext(r.Context()), At: time.Now()}
+	audit, err := a.stationAdmin.Update(r.Context(), id, audit, change)
+	if err != nil {
+		a.writeStationError(w, r, action, id, err)
+		return audit, false
+	}
+	a.invalidateStation(id)
+
+	libs.LoggerFromContext(r.Context()).Info("station updated", "stationId", id, "action", action)
+	return audit, true
+}
+
+// writeStationError answers a request failing to read or change the station.
+func (a *App) writeStationError(w http.ResponseWriter, r *http.Request, action, id string, err error) {
+	var invalid invalidStationError
+	switch {
+	case errors.Is(err, libs.ErrStationNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "station not found"})
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.message})
+	default:
+		libs.LoggerFromContext(r.Context()).Error(fmt.Sprintf("failed to %s station", action), "stationId", id, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("failed to %s station", action)})
+	}
+}
+
+func adminFromContext(ctx context.Context) string {
+	email, _ := ctx.Value(adminKey{}).(string)
+	return email
+}
+
+// decodeStrict decodes the JSON body into value, rejecting the unknown fields.
+func decodeStrict(body []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("invalid station: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/libs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/idtoken"
+)
+
+// memoryStationEditor changes memoryStations and keeps the audit records.
+type memoryStationEditor struct {
+	memoryStations
+	audits []libs.StationAudit
+}
+
+func (m *memoryStationEditor) Update(ctx context.Context, id string, audit libs.StationAudit, change func(before *libs.GoStation) (*libs.GoStation, error)) (libs.StationAudit, error) {
+	if before, ok := m.memoryStations[id]; ok {
+		audit.Before = &before
+	}
+
+	after, err := change(audit.Before)
+	if err != nil {
+		return audit, err
+	}
+	audit.After = after
+
+	if after == nil {
+		delete(m.memoryStations, id)
+	} else {
+		m.memoryStations[id] = *after
+	}
+	m.audits = append(m.audits, audit)
+
+	return audit, nil
+}
+
+func TestAdminStations(t *testing.T) {
+	station := libs.GoStation{Id: "station-1", Location: "台北101站", Address: "信義路五段7號", City: "臺北市", District: "信義區", Latitude: 25.033964, Longitude: 121.564468, VMType: 1, State: libs.ActiveState}
+	editor := &memoryStationEditor{memoryStations: memoryStations{station.Id: station}}
+	cache := libs.NewStationCache(time.Hour)
+
+	cfg := testConfig()
+	cfg.AdminAudience = "https://sogorro.example.com"
+	cfg.AdminEmails = []string{"ops@example.com"}
+	app := &App{
+		config:       cfg,
+		stations:     cachedStations{stationFinder: editor.memoryStations, cache: cache},
+		stationAdmin: editor,
+		stationCache: cache,
+		validateIDToken: func(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+			if audience != cfg.AdminAudience {
+				return nil, errors.New("audience mismatch")
+			}
+			switch token {
+			case "ops-token":
+				return &idtoken.Payload{Claims: map[string]interface{}{"email": "OPS@example.com", "email_verified": true}}, nil
+			case "rider-token":
+				return &idtoken.Payload{Claims: map[string]interface{}{"email": "rider@example.com", "email_verified": true}}, nil
+			}
+			return nil, errors.New("invalid token")
+		},
+	}
+	server := httptest.NewServer(app.router())
+	defer server.Close()
+
+	// the station is cached by a search of the bot
+	app.stations.Get(context.TODO(), station.Id)
+
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		token          string
+		body           string
+		expectedStatus int
+		expected       func(t *testing.T, body map[string]interface{})
+	}{
+		{
+			name:           "missing token",
+			method:         http.MethodGet,
+			id:             "station-1",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "invalid token",
+			method:         http.MethodGet,
+			id:             "station-1",
+			token:          "forged-token",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "not an admin",
+			method:         http.MethodGet,
+			id:             "station-1",
+			token:          "rider-token",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "get station",
+			method:         http.MethodGet,
+			id:             "station-1",
+			token:          "ops-token",
+			expectedStatus: http.StatusOK,
+			expected: func(t *testing.T, body map[string]interface{}) {
+				assert.Equal(t, "台北101站", body["location"])
+			},
+		},
+		{
+			name:           "get missing station",
+			method:         http.MethodGet,
+			id:             "missing",
+			token:          "ops-token",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "fix the address",
+			method:         http.MethodPatch,
+			id:             "station-1",
+			token:          "ops-token",
+			body:           `{"address": "信義路五段7號B1"}`,
+			expectedStatus: http.StatusOK,
+			expected: func(t *testing.T, body map[string]interface{}) {
+				assert.Equal(t, "信義路五段7號B1", body["address"])
+				assert.Equal(t, "台北101站", body["location"])
+			},
+		},
+		{
+			name:           "patch with an unknown field",
+			method:         http.MethodPatch,
+			id:             "station-1",
+			token:          "ops-token",
+			body:           `{"adress": "typo"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "patch the id",
+			method:         http.MethodPatch,
+			id:             "station-1",
+			token:          "ops-token",
+			body:           `{"id": "station-2"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "patch the distance of a search",
+			method:         http.MethodPatch,
+			id:             "station-1",
+			token:          "ops-token",
+			body:           `{"travelMinutes": 5}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "patch a missing station",
+			method:         http.MethodPatch,
+			id:             "missing",
+			token:          "ops-token",
+			body:           `{"state": 0}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "put an invalid station",
+			method:         http.MethodPut,
+			id:             "station-2",
+			token:          "ops-token",
+			body:           `{"location": "新站"}`,
+			expectedStatus: http.StatusBadRequest,
+			expected: func(t *testing.T, body map[string]interface{}) {
+				assert.Contains(t, body["error"], "address is required")
+			},
+		},
+		{
+			name:           "create a station",
+			method:         http.MethodPut,
+			id:             "station-2",
+			token:          "ops-token",
+			body:           `{"location": "新站", "address": "市府路1號", "city": "臺北市", "district": "信義區", "latitude": 25.0375, "longitude": 121.5637, "vmType": 3, "state": 1}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "delete a station",
+			method:         http.MethodDelete,
+			id:             "station-2",
+			token:          "ops-token",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "delete a missing station",
+			method:         http.MethodDelete,
+			id:             "station-2",
+			token:          "ops-token",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, server.URL+"/admin/stations/"+tt.id, bytes.NewReader([]byte(tt.body)))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expected != nil {
+				var body map[string]interface{}
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				tt.expected(t, body)
+			}
+		})
+	}
+
+	assert.Len(t, editor.audits, 3)
+	assert.Equal(t, "patch", editor.audits[0].Action)
+	assert.Equal(t, "OPS@example.com", editor.audits[0].Actor)
+	assert.Equal(t, "信義路五段7號", editor.audits[0].Before.Address)
+	assert.Nil(t, editor.audits[1].Before)
+	assert.Nil(t, editor.audits[2].After)
+
+	// the bot reads the fixed address instead of the cached one
+	fixed, err := app.stations.Get(context.TODO(), station.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "信義路五段7號B1", fixed.Address)
+}
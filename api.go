@@ -0,0 +1,180 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"ohohestudio/sogorro/libs"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// openAPISpec describes the REST API, it is served at /api/v1/openapi.json.
+//
+//go:embed api/openapi.json
+var openAPISpec []byte
+
+// apiRouter serves the REST API of the stations, for the web map and the dashboards.
+func (a *App) apiRouter(r *mux.Router) {
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(a.allowOrigins)
+	api.HandleFunc("/openapi.json", a.openAPI).Methods("GET")
+	api.HandleFunc("/stations/nearest", a.nearestStations).Methods("GET")
+	api.HandleFunc("/stations/{id}", a.stationById).Methods("GET")
+	api.HandleFunc("/export/stations.geojson", a.exportStations(geoJSONContentType, writeGeoJSON)).Methods("GET")
+	api.HandleFunc("/export/stations.kml", a.exportStations(kmlContentType, writeKML)).Methods("GET")
+}
+
+// allowOrigins lets the browsers of the configured origins call the API.
+func (a *App) allowOrigins(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		for _, allowed := range a.config.ApiAllowedOrigins {
+			if allowed == "*" || allowed == origin {
+				w.Header().Set("Access-Control-Allow-Origin", allowed)
+				w.Header().Add("Vary", "Origin")
+				break
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// nearestStations returns the nearest active stations of a location, nearest first, with their
+// distance in kilometers.
+func (a *App) nearestStations(w http.ResponseWriter, r *http.Request) {
+	query, err := parseStationQuery(r, a.config.ResultCount)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	stations, err := a.searchStations(r.Context(), query)
+	if err != nil {
+		libs.LoggerFromContext(r.Context()).Error("failed to search stations", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to search stations"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"stations": stations})
+}
+
+func (a *App) stationById(w http.ResponseWriter, r *http.Request) {
+	station, err := a.stations.Get(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, libs.ErrStationNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "station not found"})
+		return
+	}
+	if err != nil {
+		libs.LoggerFromContext(r.Context()).Error("failed to get station", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to get station"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, station)
+}
+
+const (
+	geoJSONContentType = "application/geo+json"
+	kmlContentType     = "application/vnd.google-earth.kml+xml"
+	exportName         = "sogorro stations"
+)
+
+func writeGeoJSON(w io.Writer, stations []libs.GoStation) error {
+	return libs.WriteGeoJSON(w, stations)
+}
+
+func writeKML(w io.Writer, stations []libs.GoStation) error {
+	return libs.WriteKML(w, exportName, stations)
+}
+
+// exportStations returns the handler exporting the stations selected by the city, district, type
+// and state parameters with write.
+func (a *App) exportStations(contentType string, write func(io.Writer, []libs.GoStation) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseStationFilter(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		stations, err := a.stations.All(r.Context(), filter)
+		if err != nil {
+			libs.LoggerFromContext(r.Context()).Error("failed to export stations", "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to export stations"})
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if err := write(w, stations); err != nil {
+			libs.LoggerFromContext(r.Context()).Error("failed to write stations", "error", err)
+		}
+	}
+}
+
+// parseStationFilter reads the city, district, type and state parameters of an export request.
+func parseStationFilter(values url.Values) (libs.StationFilter, error) {
+	filter := libs.StationFilter{City: values.Get("city"), District: values.Get("district")}
+
+	if vmType := values.Get("type"); vmType != "" {
+		var err error
+		if filter.VMType, err = strconv.ParseInt(vmType, 10, 64); err != nil || filter.VMType < 1 {
+			return filter, fmt.Errorf("type must be a station type like 1 or 3, got %q", vmType)
+		}
+	}
+
+	if state := values.Get("state"); state != "" {
+		value, err := strconv.ParseInt(state, 10, 64)
+		if err != nil || value < 0 {
+			return filter, fmt.Errorf("state must be a station state like 1, got %q", state)
+		}
+		filter.State = &value
+	}
+
+	return filter, nil
+}
+
+// parseStationQuery reads the lat, lng, limit and type parameters of a nearest stations request.
+func parseStationQuery(r *http.Request, defaultLimit int) (stationQuery, error) {
+	values := r.URL.Query()
+	query := stationQuery{Limit: defaultLimit, Source: "api"}
+
+	var err error
+	if query.Latitude, err = strconv.ParseFloat(values.Get("lat"), 64); err != nil || !validCoordinate(query.Latitude, 90) {
+		return query, fmt.Errorf("lat must be a latitude between -90 and 90, got %q", values.Get("lat"))
+	}
+	if query.Longitude, err = strconv.ParseFloat(values.Get("lng"), 64); err != nil || !validCoordinate(query.Longitude, 180) {
+		return query, fmt.Errorf("lng must be a longitude between -180 and 180, got %q", values.Get("lng"))
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > maxSearchLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d, got %q", maxSearchLimit, limit)
+		}
+	}
+
+	if vmType := values.Get("type"); vmType != "" {
+		if query.VMType, err = strconv.ParseInt(vmType, 10, 64); err != nil || query.VMType < 1 {
+			return query, fmt.Errorf("type must be a station type like 1 or 3, got %q", vmType)
+		}
+	}
+
+	return query, nil
+}
+
+// validCoordinate reports whether value is a number between -bound and bound, ParseFloat accepts
+// NaN and Inf which would pass the comparisons.
+func validCoordinate(value, bound float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0) && value >= -bound && value <= bound
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/libs"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationsAPI(t *testing.T) {
+	cfg := testConfig()
+	cfg.ApiAllowedOrigins = []string{"https://map.example.com"}
+	app := &App{
+		config: cfg,
+		stations: memoryStations{
+			"taipei-101": {Id: "taipei-101", Location: "台北101站", Latitude: 25.033964, Longitude: 121.564468, VMType: 1, State: libs.ActiveState},
+			"city-hall":  {Id: "city-hall", Location: "市政府站", Latitude: 25.0375, Longitude: 121.5637, VMType: 3, State: libs.ActiveState},
+			"xinyi":      {Id: "xinyi", Location: "信義站", Latitude: 25.0330, Longitude: 121.5654, VMType: 1, State: 2},
+		},
+	}
+	server := httptest.NewServer(app.router())
+	defer server.Close()
+
+	tests := []struct {
+		name             string
+		path             string
+		origin           string
+		expectedStatus   int
+		expectedStations []string
+		expectedError    string
+		expectedOrigin   string
+	}{
+		{
+			name:             "nearest stations",
+			path:             "/api/v1/stations/nearest?lat=25.0339&lng=121.5645",
+			origin:           "https://map.example.com",
+			expectedStatus:   http.StatusOK,
+			expectedStations: []string{"taipei-101", "city-hall"},
+			expectedOrigin:   "https://map.example.com",
+		},
+		{
+			name:             "limit",
+			path:             "/api/v1/stations/nearest?lat=25.0339&lng=121.5645&limit=1",
+			origin:           "https://evil.example.com",
+			expectedStatus:   http.StatusOK,
+			expectedStations: []string{"taipei-101"},
+		},
+		{
+			name:             "Super GoStations",
+			path:             "/api/v1/stations/nearest?lat=25.0339&lng=121.5645&limit=1&type=3",
+			expectedStatus:   http.StatusOK,
+			expectedStations: []string{"city-hall"},
+		},
+		{
+			name:           "missing location",
+			path:           "/api/v1/stations/nearest?lat=25.0339",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "lng must be a longitude",
+		},
+		{
+			name:           "not a number location",
+			path:           "/api/v1/stations/nearest?lat=NaN&lng=121.5645",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "lat must be a latitude",
+		},
+		{
+			name:           "infinite location",
+			path:           "/api/v1/stations/nearest?lat=25.0339&lng=-Inf",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "lng must be a longitude",
+		},
+		{
+			name:           "limit too large",
+			path:           "/api/v1/stations/nearest?lat=25.0339&lng=121.5645&limit=500",
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "limit must be between 1 and 50",
+		},
+		{
+			name:             "station by ID",
+			path:             "/api/v1/stations/xinyi",
+			expectedStatus:   http.StatusOK,
+			expectedStations: []string{"xinyi"},
+		},
+		{
+			name:           "missing station",
+			path:           "/api/v1/stations/missing",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "station not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+
+			var body struct {
+				Error    string           `json:"error"`
+				Id       string           `json:"id"`
+				Stations []libs.GoStation `json:"stations"`
+			}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Contains(t, body.Error, tt.expectedError)
+
+			ids := []string{}
+			if body.Id != "" {
+				ids = append(ids, body.Id)
+			}
+			for _, station := range body.Stations {
+				ids = append(ids, station.Id)
+				assert.Greater(t, station.Distance, 0.0)
+			}
+			if tt.expectedStations != nil {
+				assert.Equal(t, tt.expectedStations, ids)
+			}
+		})
+	}
+}
+
+func TestExportStations(t *testing.T) {
+	app := &App{
+		config: testConfig(),
+		stations: memoryStations{
+			"taipei-101": {Id: "taipei-101", Location: "台北101站", City: "臺北市", District: "信義區", Latitude: 25.033964, Longitude: 121.564468, VMType: 1, State: libs.ActiveState},
+			"city-hall":  {Id: "city-hall", Location: "市政府站", City: "臺北市", District: "信義區", Latitude: 25.0375, Longitude: 121.5637, VMType: 3, State: 2},
+			"banqiao":    {Id: "banqiao", Location: "板橋站", City: "新北市", District: "板橋區", Latitude: 25.0143, Longitude: 121.4672, VMType: 1, State: libs.ActiveState},
+		},
+	}
+	server := httptest.NewServer(app.router())
+	defer server.Close()
+
+	tests := []struct {
+		name                string
+		path                string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        []string
+		unexpectedBody      []string
+	}{
+		{
+			name:                "all stations as GeoJSON",
+			path:                "/api/v1/export/stations.geojson",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/geo+json",
+			expectedBody:        []string{`"FeatureCollection"`, `"id": "banqiao"`, `"id": "city-hall"`, `"style": "super-gostation"`},
+		},
+		{
+			name:                "active stations of a city as KML",
+			path:                "/api/v1/export/stations.kml?city=臺北市&state=1",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/vnd.google-earth.kml+xml",
+			expectedBody:        []string{"<name>台北101站</name>", "<styleUrl>#gostation</styleUrl>"},
+			unexpectedBody:      []string{"市政府站", "板橋站"},
+		},
+		{
+			name:                "Super GoStations",
+			path:                "/api/v1/export/stations.geojson?type=3",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/geo+json",
+			expectedBody:        []string{`"id": "city-hall"`},
+			unexpectedBody:      []string{"taipei-101", "banqiao"},
+		},
+		{
+			name:                "invalid state",
+			path:                "/api/v1/export/stations.kml?state=offline",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "application/json",
+			expectedBody:        []string{"state must be a station state"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedContentType, resp.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			for _, expected := range tt.expectedBody {
+				assert.Contains(t, string(body), expected)
+			}
+			for _, unexpected := range tt.unexpectedBody {
+				assert.NotContains(t, string(body), unexpected)
+			}
+		})
+	}
+}
+
+func TestOpenAPISpecDocumentsRoutes(t *testing.T) {
+	var spec struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(openAPISpec, &spec))
+	assert.NotEmpty(t, spec.OpenAPI)
+
+	app := &App{config: testConfig()}
+	r := mux.NewRouter()
+	app.apiRouter(r)
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		if err != nil || len(methods) == 0 || strings.HasSuffix(path, "/openapi.json") {
+			return nil
+		}
+
+		for _, method := range methods {
+			assert.Contains(t, spec.Paths[path], strings.ToLower(method), "%s %s is not documented", method, path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"ohohestudio/sogorro/config"
+	"ohohestudio/sogorro/libs"
+	"time"
+)
+
+// channel is a LINE bot served by the app, with its own credentials and message branding.
+type channel struct {
+	name          string
+	destination   string
+	channelSecret string
+	tokens        *libs.TokenManager
+	quota         *libs.QuotaTracker
+	branding      config.Branding
+}
+
+// newChannel loads the channel secret and the first access token of the channel, the token and
+// the push quota are then refreshed in the background until ctx is done.
+func newChannel(ctx context.Context, cfg *config.Config, secrets libs.SecretProvider, channelConfig config.Channel) (*channel, error) {
+	ch := &channel{
+		name:        channelConfig.Name,
+		destination: channelConfig.Destination,
+		branding:    channelConfig.Branding,
+	}
+
+	// Get Linebot channel secret, used to validate webhook signatures
+	channelSecret, err := secrets.Secret(ctx, channelConfig.ChannelSecretName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load channel secret of %s: %v", ch.name, err)
+	}
+	if channelSecret == "" {
+		return nil, fmt.Errorf("channel secret of %s is empty", ch.name)
+	}
+	ch.channelSecret = channelSecret
+
+	// Get Linebot access token, read from the secret or issued with the channel ID and secret
+	ch.tokens = libs.NewTokenManager(cfg.TokenSource(channelConfig, secrets, ch.channelSecret), time.Duration(cfg.TokenRefreshInterval))
+	if _, err := ch.tokens.Token(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load access token of %s: %v", ch.name, err)
+	}
+	go ch.tokens.Run(libs.WithLogger(ctx, libs.LoggerFromContext(ctx).With("channel", ch.name)))
+
+	// Push quota, polled in the background
+	ch.quota = libs.NewQuotaTracker(ch.name, cfg.LineQuotaEndpoint, ch.tokens, cfg.QuotaBudget, time.Duration(cfg.QuotaRefreshInterval))
+	go ch.quota.Run(libs.WithLogger(ctx, libs.LoggerFromContext(ctx).With("channel", ch.name)))
+
+	return ch, nil
+}
+
+// overBudget tells whether the channel crossed its push budget, and should only reply.
+func (ch *channel) overBudget() bool {
+	return ch.quota != nil && ch.quota.OverBudget()
+}
+
+// reserve counts n push messages against the budget of the channel before they are sent, it
+// returns false when they would cross it.
+func (ch *channel) reserve(n int) bool {
+	return ch.quota == nil || ch.quota.Reserve(int64(n))
+}
+
+// channelFor returns the channel of the webhook destination. The channel configured without a
+// destination handles any webhook not matching another channel.
+func (a *App) channelFor(destination string) (*channel, bool) {
+	if ch, ok := a.channels[destination]; ok {
+		return ch, true
+	}
+
+	return a.defaultChannel, a.defaultChannel != nil
+}
+
+// channelNamed returns the channel of the name, or nil.
+func (a *App) channelNamed(name string) *channel {
+	for _, ch := range a.allChannels() {
+		if ch.name == name {
+			return ch
+		}
+	}
+
+	return nil
+}
+
+// allChannels returns every channel served by the app.
+func (a *App) allChannels() []*channel {
+	var channels []*channel
+	if a.defaultChannel != nil {
+		channels = append(channels, a.defaultChannel)
+	}
+	for _, ch := range a.channels {
+		channels = append(channels, ch)
+	}
+
+	return channels
+}
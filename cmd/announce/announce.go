@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"ohohestudio/sogorro/libs"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/announcement.json
+var templates embed.FS
+
+// previewRecipients is the number of user IDs listed by a dry run.
+const previewRecipients = 5
+
+// announcement fills the message template.
+type announcement struct {
+	Title     string
+	Body      string
+	AltText   string
+	ImageUrl  string
+	LinkUrl   string
+	LinkLabel string
+}
+
+// render executes the template, the default one when path is empty, and decodes the resulting
+// Flex message, the title and the body are required. String values are written with the json template function, like {{json .Title}}.
+func render(path string, data announcement) (map[string]interface{}, error) {
+	if strings.TrimSpace(data.Title) == "" || strings.TrimSpace(data.Body) == "" {
+		return nil, errors.New("announcement title and body are required")
+	}
+	if data.AltText == "" {
+		data.AltText = data.Title
+	}
+
+	// the template is named after the file it is parsed from
+	name := "announcement.json"
+	if path != "" {
+		name = filepath.Base(path)
+	}
+
+	tmpl := template.New(name).Funcs(template.FuncMap{
+		"json": func(value string) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+	})
+
+	var err error
+	if path == "" {
+		tmpl, err = tmpl.ParseFS(templates, "templates/announcement.json")
+	} else {
+		tmpl, err = tmpl.ParseFiles(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message template: %v", err)
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return nil, fmt.Errorf("failed to render message template: %v", err)
+	}
+
+	var message map[string]interface{}
+	if err := json.Unmarshal([]byte(rendered.String()), &message); err != nil {
+		return nil, fmt.Errorf("message template doesn't render valid JSON: %v", err)
+	}
+
+	if altText, ok := message["altText"].(string); message["type"] != "flex" || !ok || altText == "" {
+		return nil, errors.New("message template must render a flex message with an altText")
+	}
+
+	return message, nil
+}
+
+// audience is who the announcement is sent to, all the followers or the listed users.
+type audience struct {
+	all         bool
+	userIds     []string
+	description string
+}
+
+// readRecipients reads the user IDs from the first column of a CSV, a header row named userId is
+// skipped and the repeated IDs are sent once.
+func readRecipients(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	seen := map[string]bool{}
+	userIds := []string{}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipients: %v", err)
+		}
+
+		userId := strings.TrimSpace(record[0])
+		if userId == "" || (row == 1 && strings.EqualFold(userId, "userId")) || seen[userId] {
+			continue
+		}
+		seen[userId] = true
+		userIds = append(userIds, userId)
+	}
+
+	return userIds, nil
+}
+
+// cityRecipients returns the users whose last search was in the city, through the channel when set.
+func cityRecipients(searches []libs.UserSearch, channel string) []string {
+	userIds := []string{}
+	for _, search := range searches {
+		if channel != "" && search.Channel != channel {
+			continue
+		}
+		userIds = append(userIds, search.UserId)
+	}
+
+	return userIds
+}
+
+// announcer sends announcements through the LINE Messaging API as a channel.
+type announcer struct {
+	channel           string
+	broadcastEndpoint string
+	multicastEndpoint string
+	tokens            *libs.TokenManager
+	quota             *libs.QuotaTracker
+	out               io.Writer
+	makeRequest       func(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error)
+}
+
+// send broadcasts the message to all the followers, or multicasts it to the users in chunks of
+// libs.MaxMulticastRecipients. Nothing is sent when the channel is over its push budget, or when
+// the users would cross it. A dry run only previews the message and its recipients.
+func (a *announcer) send(ctx context.Context, message map[string]interface{}, to audience, dryRun bool) error {
+	chunks := libs.ChunkRecipients(to.userIds)
+	if !to.all && len(chunks) == 0 {
+		return fmt.Errorf("no recipients in %s", to.description)
+	}
+
+	if dryRun {
+		preview, err := json.MarshalIndent(message, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode message: %v", err)
+		}
+		fmt.Fprintf(a.out, "%s\n", preview)
+
+		if to.all {
+			fmt.Fprintf(a.out, "dry run: 1 broadcast to %s\n", to.description)
+			return nil
+		}
+
+		fmt.Fprintf(a.out, "dry run: %d multicasts to %d users of %s\n", len(chunks), len(to.userIds), to.description)
+		for i, userId := range to.userIds {
+			if i == previewRecipients {
+				fmt.Fprintf(a.out, "  … and %d more\n", len(to.userIds)-previewRecipients)
+				break
+			}
+			fmt.Fprintf(a.out, "  %s\n", userId)
+		}
+		return nil
+	}
+
+	if err := a.quota.Refresh(ctx); err != nil {
+		return err
+	}
+	quota := a.quota.Quota()
+
+	if to.all {
+		// the number of followers is unknown, the broadcast is only refused over the budget
+		if a.quota.OverBudget() {
+			return fmt.Errorf("channel %s is over its push budget, %d of %d messages used", a.channel, quota.Used, quota.Limit)
+		}
+		payload := map[string]interface{}{"messages": []interface{}{message}}
+		if err := a.post(ctx, a.broadcastEndpoint, payload); err != nil {
+			return fmt.Errorf("failed to broadcast announcement: %v", err)
+		}
+		fmt.Fprintf(a.out, "broadcast to %s\n", to.description)
+		return nil
+	}
+
+	if !a.quota.Reserve(int64(len(to.userIds))) {
+		return fmt.Errorf("%d users would cross the push budget of channel %s, %d of %d messages used", len(to.userIds), a.channel, quota.Used, quota.Limit)
+	}
+
+	sent := 0
+	for i, chunk := range chunks {
+		payload := map[string]interface{}{"to": chunk, "messages": []interface{}{message}}
+		if err := a.post(ctx, a.multicastEndpoint, payload); err != nil {
+			return fmt.Errorf("failed to multicast announcement %d of %d, %d users were sent: %v", i+1, len(chunks), sent, err)
+		}
+		sent += len(chunk)
+		fmt.Fprintf(a.out, "multicast %d of %d sent to %d users\n", i+1, len(chunks), len(chunk))
+	}
+
+	return nil
+}
+
+// post sends the payload with the access token of the channel, refreshed once when LINE rejects it.
+func (a *announcer) post(ctx context.Context, endpoint string, payload interface{}) error {
+	return a.tokens.Authorized(ctx, func(token string) error {
+		_, err := a.makeRequest(ctx, http.MethodPost, endpoint, map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": fmt.Sprintf("Bearer %s", token),
+		}, payload)
+		return err
+	})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"ohohestudio/sogorro/libs"
+	"ohohestudio/sogorro/linefake"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	invalidTemplate := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalidTemplate, []byte(`{"type": "flex", "altText": {{.Title}}}`), 0600)
+	textTemplate := filepath.Join(dir, "text.json")
+	os.WriteFile(textTemplate, []byte(`{"type": "text", "text": {{json .Title}}}`), 0600)
+	missingAltTemplate := filepath.Join(dir, "missing-alt.json")
+	os.WriteFile(missingAltTemplate, []byte(`{"type": "flex", "contents": {"type": "bubble"}}`), 0600)
+	numberAltTemplate := filepath.Join(dir, "number-alt.json")
+	os.WriteFile(numberAltTemplate, []byte(`{"type": "flex", "altText": 1, "contents": {"type": "bubble"}}`), 0600)
+
+	tests := []struct {
+		name          string
+		path          string
+		data          announcement
+		expected      func(t *testing.T, message map[string]interface{})
+		expectedError string
+	}{
+		{
+			name: "default template",
+			data: announcement{Title: `新的 "Super" GoStation`, Body: "臺北市新增 3 座站點", LinkUrl: "https://example.com", LinkLabel: "查看"},
+			expected: func(t *testing.T, message map[string]interface{}) {
+				assert.Equal(t, `新的 "Super" GoStation`, message["altText"])
+				contents := message["contents"].(map[string]interface{})
+				assert.NotContains(t, contents, "hero")
+				assert.Contains(t, contents, "footer")
+			},
+		},
+		{
+			name: "hero image without button",
+			data: announcement{Title: "公告", Body: "新的 Super GoStation", AltText: "sogorro 公告", ImageUrl: "https://example.com/hero.png"},
+			expected: func(t *testing.T, message map[string]interface{}) {
+				assert.Equal(t, "sogorro 公告", message["altText"])
+				contents := message["contents"].(map[string]interface{})
+				assert.Equal(t, "https://example.com/hero.png", contents["hero"].(map[string]interface{})["url"])
+				assert.NotContains(t, contents, "footer")
+			},
+		},
+		{
+			name:          "invalid JSON",
+			path:          invalidTemplate,
+			data:          announcement{Title: "公告", Body: "內容"},
+			expectedError: "valid JSON",
+		},
+		{
+			name:          "not a flex message",
+			path:          textTemplate,
+			data:          announcement{Title: "公告", Body: "內容"},
+			expectedError: "flex message",
+		},
+		{
+			name:          "missing altText",
+			path:          missingAltTemplate,
+			data:          announcement{Title: "公告", Body: "內容"},
+			expectedError: "with an altText",
+		},
+		{
+			name:          "altText not a string",
+			path:          numberAltTemplate,
+			data:          announcement{Title: "公告", Body: "內容"},
+			expectedError: "with an altText",
+		},
+		{
+			name:          "empty title",
+			data:          announcement{Title: " ", Body: "內容"},
+			expectedError: "title and body are required",
+		},
+		{
+			name:          "empty body",
+			data:          announcement{Title: "公告"},
+			expectedError: "title and body are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := render(tt.path, tt.data)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "flex", message["type"])
+			tt.expected(t, message)
+		})
+	}
+}
+
+func TestReadRecipients(t *testing.T) {
+	userIds, err := readRecipients(strings.NewReader("userId,name\nU1,Rider\n\nU2\nU1,Again\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"U1", "U2"}, userIds)
+}
+
+func TestCityRecipients(t *testing.T) {
+	searches := []libs.UserSearch{
+		{UserId: "U1", Channel: "production"},
+		{UserId: "U2", Channel: "partner"},
+	}
+
+	assert.Equal(t, []string{"U1", "U2"}, cityRecipients(searches, ""))
+	assert.Equal(t, []string{"U2"}, cityRecipients(searches, "partner"))
+}
+
+// rotatedTokenSource returns its tokens in turn, the last one once they are all used.
+type rotatedTokenSource struct {
+	tokens []string
+}
+
+func (s *rotatedTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	token := s.tokens[0]
+	if len(s.tokens) > 1 {
+		s.tokens = s.tokens[1:]
+	}
+	return token, time.Time{}, nil
+}
+
+func TestSend(t *testing.T) {
+	message, err := render("", announcement{Title: "公告", Body: "新的 Super GoStation"})
+	assert.NoError(t, err)
+
+	userIds := make([]string, 1001)
+	for i := range userIds {
+		userIds[i] = fmt.Sprintf("U%d", i)
+	}
+
+	tests := []struct {
+		name               string
+		to                 audience
+		quota              int64
+		dryRun             bool
+		expectedMessages   int
+		expectedBroadcasts int
+		expectedOutput     string
+		expectedError      string
+	}{
+		{
+			name:             "multicast in chunks",
+			to:               audience{userIds: userIds, description: "city 臺北市"},
+			expectedMessages: 1001,
+			expectedOutput:   "multicast 3 of 3 sent to 1 users",
+		},
+		{
+			name:               "broadcast",
+			to:                 audience{all: true, description: "all the followers"},
+			expectedBroadcasts: 1,
+			expectedOutput:     "broadcast to all the followers",
+		},
+		{
+			name:             "multicast within the budget",
+			to:               audience{userIds: userIds, description: "city 臺北市"},
+			quota:            2000,
+			expectedMessages: 1001,
+			expectedOutput:   "multicast 3 of 3 sent to 1 users",
+		},
+		{
+			name:          "multicast over the budget",
+			to:            audience{userIds: userIds, description: "city 臺北市"},
+			quota:         1000,
+			expectedError: "1001 users would cross the push budget of channel default, 0 of 1000 messages used",
+		},
+		{
+			name:           "dry run",
+			to:             audience{userIds: userIds, description: "city 臺北市"},
+			dryRun:         true,
+			expectedOutput: "dry run: 3 multicasts to 1001 users of city 臺北市",
+		},
+		{
+			name:          "no recipients",
+			to:            audience{description: "city 高雄市"},
+			expectedError: "no recipients in city 高雄市",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := linefake.New("access-token", "channel-secret")
+			defer fake.Close()
+
+			fake.SetQuota(tt.quota)
+
+			// the first token is rejected like a rotated one, and replaced once
+			source := &rotatedTokenSource{tokens: []string{"stale-token", fake.AccessToken}}
+			tokens := libs.NewTokenManager(source, 0)
+
+			var out strings.Builder
+			sender := &announcer{
+				channel:           "default",
+				broadcastEndpoint: fake.BroadcastEndpoint(),
+				multicastEndpoint: fake.MulticastEndpoint(),
+				tokens:            tokens,
+				quota:             libs.NewQuotaTracker("default", fake.QuotaEndpoint(), tokens, 0.9, 0),
+				out:               &out,
+				makeRequest:       libs.MakeRequestContext,
+			}
+
+			err := sender.send(context.TODO(), message, tt.to, tt.dryRun)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				assert.Empty(t, fake.Messages())
+				return
+			}
+
+			assert.NoError(t, err)
+			for _, rejection := range fake.Rejections() {
+				assert.Equal(t, http.StatusUnauthorized, rejection.StatusCode)
+			}
+			assert.Len(t, fake.MessagesTo(linefake.Broadcast), tt.expectedBroadcasts)
+			assert.Len(t, fake.Messages(), tt.expectedMessages+tt.expectedBroadcasts)
+			assert.Contains(t, out.String(), tt.expectedOutput)
+		})
+	}
+}
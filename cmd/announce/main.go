@@ -0,0 +1,130 @@
+// Command announce sends an announcement, like new Super GoStations in a city, as a Flex message
+// rendered from a template:
+//
+//	go run ./cmd/announce -title "新的 Super GoStation" -body "..." -city 臺北市 -dry-run
+//
+// The message is broadcast to all the followers with -all, or multicast to the users who last
+// searched in a city with -city, or to the user IDs of the first column of a CSV with -csv.
+// Preview the message and its recipients with -dry-run before sending it.
+//
+// The configuration of the server is loaded from CONFIG_FILE and the environment: the message is
+// sent as the channel named by -channel, the first one by default, with its access token, to the
+// configured broadcast and multicast endpoints. Multicasts that would cross the push budget of the
+// channel are refused before any message is sent. The users of a city are read from the Firestore
+// users collection of GOOGLE_CLOUD_PROJECT.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"ohohestudio/sogorro/config"
+	"ohohestudio/sogorro/libs"
+	"os"
+)
+
+func main() {
+	templatePath := flag.String("template", "", "Flex message template, the built-in announcement when empty")
+	title := flag.String("title", "", "title of the announcement")
+	body := flag.String("body", "", "text of the announcement")
+	altText := flag.String("alt", "", "notification text, the title when empty")
+	imageUrl := flag.String("image", "", "HTTPS URL of the hero image")
+	linkUrl := flag.String("link", "", "URL opened by the button")
+	linkLabel := flag.String("link-label", "了解更多", "label of the button")
+	all := flag.Bool("all", false, "broadcast to all the followers")
+	city := flag.String("city", "", "multicast to the users who last searched in the city, like 臺北市")
+	channel := flag.String("channel", "", "name of the channel sending the announcement, the first configured one when empty")
+	csvPath := flag.String("csv", "", "multicast to the user IDs of the first column of the CSV")
+	dryRun := flag.Bool("dry-run", false, "preview the message and its recipients without sending")
+	flag.Parse()
+
+	targets := 0
+	for _, set := range []bool{*all, *city != "", *csvPath != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		log.Fatal("exactly one of -all, -city or -csv is required")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	channelConfig, ok := cfg.ChannelNamed(*channel)
+	if !ok {
+		log.Fatalf("channel %q is not configured", *channel)
+	}
+
+	ctx := context.Background()
+	message, err := render(*templatePath, announcement{
+		Title:     *title,
+		Body:      *body,
+		AltText:   *altText,
+		ImageUrl:  *imageUrl,
+		LinkUrl:   *linkUrl,
+		LinkLabel: *linkLabel,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	to := audience{all: true, description: "all the followers"}
+	switch {
+	case *city != "":
+		client, err := libs.GetFirebaseClient(ctx, cfg.ProjectId)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer client.Close()
+
+		searches, err := libs.NewFirestoreUserStore(client, libs.UsersCollection).UsersInCity(ctx, *city)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// with several channels, only the users of the sending channel can receive its messages
+		recipientsChannel := ""
+		if len(cfg.ChannelConfigs()) > 1 {
+			recipientsChannel = channelConfig.Name
+		}
+		to = audience{userIds: cityRecipients(searches, recipientsChannel), description: fmt.Sprintf("city %s", *city)}
+	case *csvPath != "":
+		file, err := os.Open(*csvPath)
+		if err != nil {
+			log.Fatalf("failed to open recipients: %v", err)
+		}
+		defer file.Close()
+
+		userIds, err := readRecipients(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		to = audience{userIds: userIds, description: *csvPath}
+	}
+
+	// the channel secret issues the access token of channels with an ID
+	secrets := cfg.SecretProvider()
+	channelSecret := ""
+	if channelConfig.ChannelId != "" && !*dryRun {
+		channelSecret, err = secrets.Secret(ctx, channelConfig.ChannelSecretName)
+		if err != nil {
+			log.Fatalf("failed to load channel secret of %s: %v", channelConfig.Name, err)
+		}
+	}
+	tokens := libs.NewTokenManager(cfg.TokenSource(channelConfig, secrets, channelSecret), 0)
+
+	sender := &announcer{
+		channel:           channelConfig.Name,
+		broadcastEndpoint: cfg.LineBroadcastEndpoint,
+		multicastEndpoint: cfg.LineMulticastEndpoint,
+		tokens:            tokens,
+		quota:             libs.NewQuotaTracker(channelConfig.Name, cfg.LineQuotaEndpoint, tokens, cfg.QuotaBudget, 0),
+		out:               os.Stdout,
+		makeRequest:       libs.MakeRequestContext,
+	}
+	if err := sender.send(ctx, message, to, *dryRun); err != nil {
+		log.Fatal(err)
+	}
+}
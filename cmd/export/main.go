@@ -0,0 +1,68 @@
+// Command export writes the GoStations of the Firestore stations collection of
+// GOOGLE_CLOUD_PROJECT as GeoJSON or KML, for QGIS, Google Earth or Google My Maps:
+//
+//	go run ./cmd/export -format kml -city 臺北市 -state 1 -o taipei.kml
+//
+// All the stations are exported unless filtered by -city, -district, -type or -state, and the
+// stations of each vmType share a style.
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"ohohestudio/sogorro/libs"
+	"os"
+)
+
+func main() {
+	format := flag.String("format", "geojson", "output format, geojson or kml")
+	city := flag.String("city", "", "only the stations of the city, like 臺北市")
+	district := flag.String("district", "", "only the stations of the district, like 信義區")
+	vmType := flag.Int64("type", 0, "only the stations of the type, 3 for the Super GoStations")
+	state := flag.Int64("state", -1, "only the stations of the state, 1 for the stations in service")
+	output := flag.String("o", "", "output file, stdout when empty")
+	name := flag.String("name", "sogorro stations", "name of the KML document")
+	flag.Parse()
+
+	var write func(io.Writer, []libs.GoStation) error
+	switch *format {
+	case "geojson":
+		write = libs.WriteGeoJSON
+	case "kml":
+		write = func(w io.Writer, stations []libs.GoStation) error { return libs.WriteKML(w, *name, stations) }
+	default:
+		log.Fatalf("unknown format %q, use geojson or kml", *format)
+	}
+
+	filter := libs.StationFilter{City: *city, District: *district, VMType: *vmType}
+	if *state >= 0 {
+		filter.State = state
+	}
+
+	ctx := context.Background()
+	client, err := libs.GetFirebaseClient(ctx, os.Getenv("GOOGLE_CLOUD_PROJECT"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	stations, err := libs.NewStationRepository(client, libs.DefaultSearchRadius).All(ctx, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			log.Fatalf("failed to create output: %v", err)
+		}
+		defer out.Close()
+	}
+
+	if err := write(out, stations); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("exported %d stations", len(stations))
+}
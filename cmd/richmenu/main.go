@@ -0,0 +1,71 @@
+// Command richmenu uploads the sogorro rich menu and links it to all users of the bot.
+//
+// The menu is defined in code, run it again after changing the definition or the image:
+//
+//	go run ./cmd/richmenu -image images/richmenu.png
+//
+// The configuration of the server is loaded from CONFIG_FILE and the environment: the menu is
+// uploaded to the channel named by -channel, the first one by default, with its access token.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"ohohestudio/sogorro/config"
+	"ohohestudio/sogorro/libs"
+	"os"
+	"time"
+)
+
+func main() {
+	apiEndpoint := flag.String("api", "https://api.line.me", "base URL of the LINE Messaging API")
+	dataApiEndpoint := flag.String("data-api", "https://api-data.line.me", "base URL of the LINE Messaging API for content upload")
+	imagePath := flag.String("image", "images/richmenu.png", "rich menu image, 2500x843 PNG or JPEG")
+	channel := flag.String("channel", "", "name of the channel of the rich menu, the first configured one when empty")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	channelConfig, ok := cfg.ChannelNamed(*channel)
+	if !ok {
+		log.Fatalf("channel %q is not configured", *channel)
+	}
+
+	// the channel secret issues the access token of channels with an ID
+	ctx := context.Background()
+	secrets := cfg.SecretProvider()
+	channelSecret := ""
+	if channelConfig.ChannelId != "" {
+		channelSecret, err = secrets.Secret(ctx, channelConfig.ChannelSecretName)
+		if err != nil {
+			log.Fatalf("failed to load channel secret of %s: %v", channelConfig.Name, err)
+		}
+	}
+	accessToken, err := libs.NewTokenManager(cfg.TokenSource(channelConfig, secrets, channelSecret), 0).Token(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	image, err := os.ReadFile(*imagePath)
+	if err != nil {
+		log.Fatalf("failed to read rich menu image: %v", err)
+	}
+
+	client := &lineClient{
+		apiEndpoint:     *apiEndpoint,
+		dataApiEndpoint: *dataApiEndpoint,
+		accessToken:     accessToken,
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+	}
+
+	richMenuId, err := provision(client, sogorroRichMenu(), image)
+	if err != nil {
+		log.Fatalf("failed to provision rich menu: %v", err)
+	}
+
+	log.Printf("rich menu %s is linked to all users\n", richMenuId)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"ohohestudio/sogorro/libs"
+	"strings"
+)
+
+// richMenuPrefix marks the rich menus managed by this tool, the rest of the name is a hash of
+// the definition and image so a changed menu gets a new name.
+const richMenuPrefix = "sogorro-"
+
+type richMenuSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type richMenuBounds struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type richMenuArea struct {
+	Bounds richMenuBounds      `json:"bounds"`
+	Action libs.ActionTemplate `json:"action"`
+}
+
+type richMenu struct {
+	RichMenuId  string         `json:"richMenuId,omitempty"`
+	Size        richMenuSize   `json:"size"`
+	Selected    bool           `json:"selected"`
+	Name        string         `json:"name"`
+	ChatBarText string         `json:"chatBarText"`
+	Areas       []richMenuArea `json:"areas"`
+}
+
+// sogorroRichMenu is the rich menu of the bot: share location, favourites, settings and help,
+// laid out as four columns matching images/richmenu.png.
+func sogorroRichMenu() richMenu {
+	actions := []libs.ActionTemplate{
+		{
+			Type:  libs.URIAction,
+			Label: "分享位置",
+			URI:   "https://line.me/R/nv/location/",
+		},
+		{
+			Type:        libs.PostbackAction,
+			Label:       "我的最愛",
+			Data:        libs.FavouritesPostback,
+			DisplayText: "我的最愛",
+		},
+		{
+			Type:        libs.PostbackAction,
+			Label:       "設定",
+			Data:        libs.SettingsPostback,
+			DisplayText: "設定",
+		},
+		{
+			Type:  libs.MessageAction,
+			Label: "使用說明",
+			Text:  "使用說明",
+		},
+	}
+
+	menu := richMenu{
+		Size:        richMenuSize{Width: 2500, Height: 843},
+		Selected:    true,
+		ChatBarText: "sogorro 選單",
+	}
+
+	width := menu.Size.Width / len(actions)
+	for i, action := range actions {
+		menu.Areas = append(menu.Areas, richMenuArea{
+			Bounds: richMenuBounds{X: i * width, Y: 0, Width: width, Height: menu.Size.Height},
+			Action: action,
+		})
+	}
+
+	return menu
+}
+
+// richMenuName derives the name of the menu from its definition and image.
+func richMenuName(menu richMenu, image []byte) (string, error) {
+	menu.Name = ""
+	definition, err := json.Marshal(menu)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode rich menu: %v", err)
+	}
+
+	hash := sha256.New()
+	hash.Write(definition)
+	hash.Write(image)
+	return richMenuPrefix + hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// lineClient calls the rich menu endpoints of the LINE Messaging API.
+type lineClient struct {
+	apiEndpoint     string
+	dataApiEndpoint string
+	accessToken     string
+	httpClient      *http.Client
+}
+
+func (c *lineClient) do(method, url, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %v", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to make request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %v", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s %s failed with status %d: %s", method, url, resp.StatusCode, result)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(result, out); err != nil {
+			return fmt.Errorf("unable to decode response body: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *lineClient) listRichMenus() ([]richMenu, error) {
+	var result struct {
+		RichMenus []richMenu `json:"richmenus"`
+	}
+
+	err := c.do(http.MethodGet, c.apiEndpoint+"/v2/bot/richmenu/list", "", nil, &result)
+	return result.RichMenus, err
+}
+
+func (c *lineClient) createRichMenu(menu richMenu) (string, error) {
+	definition, err := json.Marshal(menu)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode rich menu: %v", err)
+	}
+
+	var result struct {
+		RichMenuId string `json:"richMenuId"`
+	}
+	err = c.do(http.MethodPost, c.apiEndpoint+"/v2/bot/richmenu", "application/json", bytes.NewReader(definition), &result)
+	return result.RichMenuId, err
+}
+
+func (c *lineClient) uploadRichMenuImage(richMenuId string, image []byte) error {
+	url := fmt.Sprintf("%s/v2/bot/richmenu/%s/content", c.dataApiEndpoint, richMenuId)
+	return c.do(http.MethodPost, url, http.DetectContentType(image), bytes.NewReader(image), nil)
+}
+
+func (c *lineClient) setDefaultRichMenu(richMenuId string) error {
+	return c.do(http.MethodPost, fmt.Sprintf("%s/v2/bot/user/all/richmenu/%s", c.apiEndpoint, richMenuId), "", nil, nil)
+}
+
+func (c *lineClient) deleteRichMenu(richMenuId string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("%s/v2/bot/richmenu/%s", c.apiEndpoint, richMenuId), "", nil, nil)
+}
+
+// provision makes sure the menu is uploaded and linked to all users. Re-running it without
+// changes reuses the existing menu, after a change the new menu replaces the old ones.
+func provision(client *lineClient, menu richMenu, image []byte) (string, error) {
+	name, err := richMenuName(menu, image)
+	if err != nil {
+		return "", err
+	}
+	menu.Name = name
+
+	menus, err := client.listRichMenus()
+	if err != nil {
+		return "", err
+	}
+
+	richMenuId := ""
+	for _, m := range menus {
+		if m.Name == name {
+			richMenuId = m.RichMenuId
+			break
+		}
+	}
+
+	if richMenuId == "" {
+		richMenuId, err = client.createRichMenu(menu)
+		if err != nil {
+			return "", err
+		}
+
+		if err := client.uploadRichMenuImage(richMenuId, image); err != nil {
+			// a menu without image can't be linked, don't leave it behind
+			client.deleteRichMenu(richMenuId)
+			return "", err
+		}
+	}
+
+	if err := client.setDefaultRichMenu(richMenuId); err != nil {
+		return "", err
+	}
+
+	for _, m := range menus {
+		if strings.HasPrefix(m.Name, richMenuPrefix) && m.RichMenuId != richMenuId {
+			if err := client.deleteRichMenu(m.RichMenuId); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return richMenuId, nil
+}
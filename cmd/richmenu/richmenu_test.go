@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRichMenuAPI keeps rich menus in memory, like the LINE rich menu endpoints do.
+type fakeRichMenuAPI struct {
+	mu          sync.Mutex
+	menus       map[string]richMenu
+	images      map[string][]byte
+	defaultMenu string
+	created     int
+}
+
+func newFakeRichMenuAPI(t *testing.T) *httptest.Server {
+	api := &fakeRichMenuAPI{
+		menus:  make(map[string]richMenu),
+		images: make(map[string][]byte),
+	}
+
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				http.Error(w, `{"message":"Authentication failed"}`, http.StatusUnauthorized)
+				return
+			}
+			api.mu.Lock()
+			defer api.mu.Unlock()
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.HandleFunc("/v2/bot/richmenu/list", func(w http.ResponseWriter, r *http.Request) {
+		menus := []richMenu{}
+		for _, m := range api.menus {
+			menus = append(menus, m)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"richmenus": menus})
+	}).Methods("GET")
+	r.HandleFunc("/v2/bot/richmenu", func(w http.ResponseWriter, r *http.Request) {
+		var menu richMenu
+		if err := json.NewDecoder(r.Body).Decode(&menu); err != nil || len(menu.Areas) == 0 {
+			http.Error(w, `{"message":"invalid rich menu"}`, http.StatusBadRequest)
+			return
+		}
+		api.created++
+		menu.RichMenuId = fmt.Sprintf("richmenu-%d", api.created)
+		api.menus[menu.RichMenuId] = menu
+		json.NewEncoder(w).Encode(map[string]string{"richMenuId": menu.RichMenuId})
+	}).Methods("POST")
+	r.HandleFunc("/v2/bot/richmenu/{id}/content", func(w http.ResponseWriter, r *http.Request) {
+		image, _ := io.ReadAll(r.Body)
+		api.images[mux.Vars(r)["id"]] = image
+		w.Write([]byte("{}"))
+	}).Methods("POST")
+	r.HandleFunc("/v2/bot/richmenu/{id}", func(w http.ResponseWriter, r *http.Request) {
+		delete(api.menus, mux.Vars(r)["id"])
+		w.Write([]byte("{}"))
+	}).Methods("DELETE")
+	r.HandleFunc("/v2/bot/user/all/richmenu/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if _, ok := api.images[id]; !ok {
+			http.Error(w, `{"message":"must upload richmenu image before applying it to user"}`, http.StatusBadRequest)
+			return
+		}
+		api.defaultMenu = id
+		w.Write([]byte("{}"))
+	}).Methods("POST")
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestProvision(t *testing.T) {
+	server := newFakeRichMenuAPI(t)
+	client := &lineClient{
+		apiEndpoint:     server.URL,
+		dataApiEndpoint: server.URL,
+		accessToken:     "access-token",
+		httpClient:      server.Client(),
+	}
+
+	image := []byte("\x89PNG\r\n\x1a\nimage")
+	first, err := provision(client, sogorroRichMenu(), image)
+	assert.NoError(t, err)
+
+	// re-running without changes reuses the menu
+	second, err := provision(client, sogorroRichMenu(), image)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	// a new image replaces the old menu
+	third, err := provision(client, sogorroRichMenu(), []byte("\x89PNG\r\n\x1a\nnew image"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, third)
+
+	menus, err := client.listRichMenus()
+	assert.NoError(t, err)
+	assert.Len(t, menus, 1)
+	assert.Equal(t, third, menus[0].RichMenuId)
+	assert.True(t, strings.HasPrefix(menus[0].Name, richMenuPrefix))
+	assert.Len(t, menus[0].Areas, 4)
+}
+
+func TestProvisionUnauthorized(t *testing.T) {
+	server := newFakeRichMenuAPI(t)
+	client := &lineClient{
+		apiEndpoint:     server.URL,
+		dataApiEndpoint: server.URL,
+		accessToken:     "wrong-token",
+		httpClient:      server.Client(),
+	}
+
+	_, err := provision(client, sogorroRichMenu(), []byte("image"))
+	assert.ErrorContains(t, err, "status 401")
+}
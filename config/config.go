@@ -0,0 +1,675 @@
+// Package config loads the settings of the sogorro API server once at startup.
+//
+// Settings are read from an optional JSON file named by CONFIG_FILE, then overridden by
+// environment variables, and validated before the server starts.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"ohohestudio/sogorro/libs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	// the container image has no zoneinfo, the timezone of the reminders is embedded
+	_ "time/tzdata"
+)
+
+// Defaults of the optional settings.
+const (
+	DefaultPort              = "8080"
+	DefaultSearchRadius      = libs.DefaultSearchRadius
+	DefaultResultCount       = 3
+	DefaultEventWorkers      = 4
+	DefaultEventQueueSize    = 100
+	DefaultUserRateLimit     = 0.5
+	DefaultUserRateBurst     = 5
+	DefaultRateLimit         = 50
+	DefaultRateBurst         = 100
+	DefaultTokenEndpoint     = "https://api.line.me/v2/oauth/accessToken"
+	DefaultTokenRefresh      = Duration(time.Hour)
+	DefaultReplyEndpoint     = "https://api.line.me/v2/bot/message/reply"
+	DefaultQuotaEndpoint     = "https://api.line.me/v2/bot/message/quota"
+	DefaultMulticastEndpoint = "https://api.line.me/v2/bot/message/multicast"
+	DefaultBroadcastEndpoint = "https://api.line.me/v2/bot/message/broadcast"
+	DefaultQuotaBudget       = 0.9
+	DefaultQuotaRefresh      = Duration(10 * time.Minute)
+	DefaultTimezone          = "Asia/Taipei"
+	DefaultRoutingProfile    = "driving"
+)
+
+// routingTimeout bounds the travel estimation of a search, the straight-line estimation is used past it.
+const routingTimeout = 2 * time.Second
+
+// Backends of the SecretProvider.
+const (
+	SecretManagerBackend = "secretmanager"
+	EnvSecretBackend     = "env"
+	FileSecretBackend    = "file"
+)
+
+// Providers of the station maps, the maps are not shown when MapProvider is empty.
+const (
+	GoogleMapProvider = "google"
+	LocalMapProvider  = "local"
+)
+
+// Default branding of the messages, used when a channel doesn't override it.
+const (
+	DefaultAltText      = "sogorro"
+	DefaultWelcomeText  = "歡迎使用 sogorro \n\n只要分享您的目前位置，我們會為您找到離您最近的 GoStation，方便您快速找到充電站！隨時隨地，讓騎乘更輕鬆愜意！"
+	DefaultNotFoundText = "抱歉，您附近沒有找到 Gogoro 充電站。請嘗試分享其他位置或稍後再試。"
+	DefaultSlowDownText = "您的訊息太頻繁了，請稍後再試。"
+)
+
+// maxResultCount is the number of messages LINE accepts in a single push.
+const maxResultCount = 5
+
+type Config struct {
+	// Port the HTTP server listens on. Env: PORT
+	Port string `json:"port"`
+	// Grpc serves the gRPC station search on the HTTP port, the calls are told apart by their
+	// content type. Env: GRPC
+	Grpc bool `json:"grpc"`
+	// GrpcAudience is the audience of the Google ID tokens the gRPC callers send, like the URL of
+	// the service. Env: GRPC_AUDIENCE
+	GrpcAudience string `json:"grpcAudience"`
+	// GrpcCallers are the service accounts allowed to call the gRPC station search, comma
+	// separated. Env: GRPC_CALLERS
+	GrpcCallers []string `json:"grpcCallers"`
+	// ProjectId of the Firestore database, detected from the metadata server when empty. Env: GOOGLE_CLOUD_PROJECT
+	ProjectId string `json:"projectId"`
+	// SecretBackend resolves the secrets: secretmanager, env (variable named after the secret) or
+	// file (file named after the secret in SecretDir). Env: SECRET_BACKEND
+	SecretBackend string `json:"secretBackend"`
+	// SecretProjectId hosts the secrets in Secret Manager. Env: SECRET_PROJECT_ID
+	SecretProjectId string `json:"secretProjectId"`
+	// SecretDir holds the secret files of the file backend. Env: SECRET_DIR
+	SecretDir string `json:"secretDir"`
+	// SecretName of the LINE channel access token. Env: SECRET_NAME
+	SecretName string `json:"secretName"`
+	// ChannelSecretName of the LINE channel secret, which validates the webhook signatures. Env: CHANNEL_SECRET_NAME
+	ChannelSecretName string `json:"channelSecretName"`
+	// ChannelId issues short-lived channel access tokens with the channel secret instead of reading
+	// the token from SecretName. Env: LINE_CHANNEL_ID
+	ChannelId string `json:"channelId"`
+	// TokenEndpoint is the LINE OAuth URL short-lived tokens are issued from. Env: LINE_TOKEN_ENDPOINT
+	TokenEndpoint string `json:"tokenEndpoint"`
+	// TokenRefreshInterval is how often the channel access token is refreshed, like "1h". Env: TOKEN_REFRESH_INTERVAL
+	TokenRefreshInterval Duration `json:"tokenRefreshInterval"`
+	// Branding of the messages of the channel configured by the settings above.
+	Branding Branding `json:"branding"`
+	// Channels served by the deployment, like a staging and a production bot, replace the single
+	// channel configured by the settings above. Only set in the config file.
+	Channels []Channel `json:"channels"`
+	// LineAPIEndpoint is the URL messages are pushed to. Env: LINE_API_ENDPOINT
+	LineAPIEndpoint string `json:"lineApiEndpoint"`
+	// LineReplyEndpoint is the URL messages are replied to while over the push budget, and the
+	// throttled users are asked to slow down with. Env: LINE_REPLY_ENDPOINT
+	LineReplyEndpoint string `json:"lineReplyEndpoint"`
+	// LineMulticastEndpoint is the URL messages to several users are sent to. Env: LINE_MULTICAST_ENDPOINT
+	LineMulticastEndpoint string `json:"lineMulticastEndpoint"`
+	// LineBroadcastEndpoint is the URL announcements to all the followers are sent to. Env: LINE_BROADCAST_ENDPOINT
+	LineBroadcastEndpoint string `json:"lineBroadcastEndpoint"`
+	// LineQuotaEndpoint is the URL of the push quota, its consumption is read from the /consumption
+	// sub-path. Env: LINE_QUOTA_ENDPOINT
+	LineQuotaEndpoint string `json:"lineQuotaEndpoint"`
+	// QuotaBudget is the fraction of the monthly push quota after which the channels only reply
+	// to messages and skip the other pushes. Env: QUOTA_BUDGET
+	QuotaBudget float64 `json:"quotaBudget"`
+	// QuotaRefreshInterval is how often the push quota is read, like "10m". Env: QUOTA_REFRESH_INTERVAL
+	QuotaRefreshInterval Duration `json:"quotaRefreshInterval"`
+	// StationNotifications listens to the stations and notifies the subscribed users when a station
+	// goes offline or is back in service. Env: STATION_NOTIFICATIONS
+	StationNotifications bool `json:"stationNotifications"`
+	// SchedulerTokenName of the token Cloud Scheduler sends in the X-Scheduler-Token header to run
+	// the reminders, the cron endpoints are disabled when empty. Env: SCHEDULER_TOKEN_NAME
+	SchedulerTokenName string `json:"schedulerTokenName"`
+	// Timezone the reminder times are set in. Env: TIMEZONE
+	Timezone string `json:"timezone"`
+	// AdminAudience is the audience of the Google ID tokens accepted by the admin API, like the URL
+	// of the service, the admin API is disabled when empty. Env: ADMIN_AUDIENCE
+	AdminAudience string `json:"adminAudience"`
+	// AdminEmails are the accounts allowed to use the admin API, comma separated. Env: ADMIN_EMAILS
+	AdminEmails []string `json:"adminEmails"`
+	// ApiAllowedOrigins are the web origins allowed to call the REST API from a browser, comma
+	// separated, * allows any. Env: API_ALLOWED_ORIGINS
+	ApiAllowedOrigins []string `json:"apiAllowedOrigins"`
+	// MapProvider draws the map of the station bubbles: google (Maps Static API) or local (drawn by
+	// the bot at /map/{lat},{lng}.png), no map when empty. Env: MAP_PROVIDER
+	MapProvider string `json:"mapProvider"`
+	// MapTileUrl is the URL template of the raster tiles the local maps are drawn over, like
+	// https://tile.openstreetmap.org/{z}/{x}/{y}.png, the tile server must allow the use. Env: MAP_TILE_URL
+	MapTileUrl string `json:"mapTileUrl"`
+	// GoogleMapsKey is the API key of the Maps Static API, it is sent to the users in the map URLs
+	// and must be restricted to the API. Env: GOOGLE_MAPS_KEY
+	GoogleMapsKey string `json:"googleMapsKey"`
+	// PublicUrl is the HTTPS URL the bot is reached at, like https://sogorro.example.com. Env: PUBLIC_URL
+	PublicUrl string `json:"publicUrl"`
+	// RoutingEndpoint is the URL of an OSRM server estimating the travel time to the stations, like
+	// https://router.project-osrm.org, the stations are ranked by straight-line distance when empty.
+	// Env: ROUTING_ENDPOINT
+	RoutingEndpoint string `json:"routingEndpoint"`
+	// RoutingProfile is the OSRM profile of the routes, like driving or a scooter profile of the
+	// server. Env: ROUTING_PROFILE
+	RoutingProfile string `json:"routingProfile"`
+	// SearchRadius is half the side of the box searched around a location, in degrees. Env: SEARCH_RADIUS
+	SearchRadius float64 `json:"searchRadius"`
+	// ResultCount is the number of nearest stations sent back. Env: RESULT_COUNT
+	ResultCount int `json:"resultCount"`
+	// EventWorkers process the webhook events in the background. Env: EVENT_WORKERS
+	EventWorkers int `json:"eventWorkers"`
+	// EventQueueSize is the number of webhook events waiting for a worker before the webhook fails. Env: EVENT_QUEUE_SIZE
+	EventQueueSize int `json:"eventQueueSize"`
+	// UserRateLimit is the number of webhook events per second processed for a user. Env: USER_RATE_LIMIT
+	UserRateLimit float64 `json:"userRateLimit"`
+	// UserRateBurst is the number of webhook events of a user processed at once. Env: USER_RATE_BURST
+	UserRateBurst int `json:"userRateBurst"`
+	// RateLimit is the number of webhook events per second processed for all the users. Env: RATE_LIMIT
+	RateLimit float64 `json:"rateLimit"`
+	// RateBurst is the number of webhook events processed at once for all the users. Env: RATE_BURST
+	RateBurst int `json:"rateBurst"`
+	// LogLevel is one of debug, info, warn or error. Env: LOG_LEVEL
+	LogLevel string `json:"logLevel"`
+	// TracesExporter is one of otlp, stdout or none. Env: OTEL_TRACES_EXPORTER
+	TracesExporter string `json:"tracesExporter"`
+}
+
+// Channel is a LINE bot served by the deployment. Webhooks are routed to the channel by their
+// destination, the user ID of the bot.
+type Channel struct {
+	// Name identifies the channel in logs and metrics.
+	Name string `json:"name"`
+	// Destination is the bot user ID sent in the webhook destination field, empty matches any
+	// destination, like the channel configured by the top-level settings.
+	Destination string `json:"destination"`
+	// SecretName of the channel access token.
+	SecretName string `json:"secretName"`
+	// ChannelSecretName of the channel secret, which validates the webhook signatures.
+	ChannelSecretName string `json:"channelSecretName"`
+	// ChannelId issues short-lived channel access tokens instead of reading them from SecretName.
+	ChannelId string `json:"channelId"`
+	// Branding of the messages sent by the channel.
+	Branding Branding `json:"branding"`
+}
+
+// Branding customises the texts of the messages sent by a channel.
+type Branding struct {
+	// AltText is shown in notifications and chat lists for flex messages.
+	AltText string `json:"altText"`
+	// WelcomeText answers any message other than a location.
+	WelcomeText string `json:"welcomeText"`
+	// NotFoundText answers a location without any station nearby.
+	NotFoundText string `json:"notFoundText"`
+	// SlowDownText answers the first message of a user throttled by the rate limiter.
+	SlowDownText string `json:"slowDownText"`
+}
+
+// WithDefaults returns the branding with the default texts in place of the empty ones.
+func (b Branding) WithDefaults() Branding {
+	if b.AltText == "" {
+		b.AltText = DefaultAltText
+	}
+	if b.WelcomeText == "" {
+		b.WelcomeText = DefaultWelcomeText
+	}
+	if b.NotFoundText == "" {
+		b.NotFoundText = DefaultNotFoundText
+	}
+	if b.SlowDownText == "" {
+		b.SlowDownText = DefaultSlowDownText
+	}
+
+	return b
+}
+
+// Load reads the configuration from CONFIG_FILE and the environment, and validates it.
+func Load() (*Config, error) {
+	return load(os.LookupEnv)
+}
+
+func load(lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := &Config{
+		Port:           DefaultPort,
+		SecretBackend:  SecretManagerBackend,
+		SearchRadius:   DefaultSearchRadius,
+		RoutingProfile: DefaultRoutingProfile,
+		ResultCount:    DefaultResultCount,
+		EventWorkers:   DefaultEventWorkers,
+		EventQueueSize: DefaultEventQueueSize,
+		UserRateLimit:  DefaultUserRateLimit,
+		UserRateBurst:  DefaultUserRateBurst,
+		RateLimit:      DefaultRateLimit,
+		RateBurst:      DefaultRateBurst,
+		TokenEndpoint:  DefaultTokenEndpoint,
+		QuotaBudget:    DefaultQuotaBudget,
+
+		LineReplyEndpoint: DefaultReplyEndpoint,
+		LineQuotaEndpoint: DefaultQuotaEndpoint,
+
+		LineMulticastEndpoint: DefaultMulticastEndpoint,
+		LineBroadcastEndpoint: DefaultBroadcastEndpoint,
+		QuotaRefreshInterval:  DefaultQuotaRefresh,
+
+		TokenRefreshInterval: DefaultTokenRefresh,
+
+		Timezone: DefaultTimezone,
+	}
+
+	if path, ok := lookupEnv("CONFIG_FILE"); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("failed to decode config file %s: %v", path, err)
+		}
+	}
+
+	var errs []error
+	setString := func(name string, value *string) {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			*value = v
+		}
+	}
+	setInt := func(name string, value *int) {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be an integer, got %q", name, v))
+				return
+			}
+			*value = n
+		}
+	}
+	setFloat := func(name string, value *float64) {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a number, got %q", name, v))
+				return
+			}
+			*value = n
+		}
+	}
+	setBool := func(name string, value *bool) {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be true or false, got %q", name, v))
+				return
+			}
+			*value = b
+		}
+	}
+	setList := func(name string, value *[]string) {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			*value = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*value = append(*value, item)
+				}
+			}
+		}
+	}
+	setDuration := func(name string, value *Duration) {
+		if v, ok := lookupEnv(name); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a duration like 1h, got %q", name, v))
+				return
+			}
+			*value = Duration(d)
+		}
+	}
+
+	setString("PORT", &cfg.Port)
+	setString("GOOGLE_CLOUD_PROJECT", &cfg.ProjectId)
+	setString("SECRET_BACKEND", &cfg.SecretBackend)
+	setString("SECRET_PROJECT_ID", &cfg.SecretProjectId)
+	setString("SECRET_DIR", &cfg.SecretDir)
+	setString("SECRET_NAME", &cfg.SecretName)
+	setString("CHANNEL_SECRET_NAME", &cfg.ChannelSecretName)
+	setString("LINE_CHANNEL_ID", &cfg.ChannelId)
+	setString("LINE_TOKEN_ENDPOINT", &cfg.TokenEndpoint)
+	setDuration("TOKEN_REFRESH_INTERVAL", &cfg.TokenRefreshInterval)
+	setString("LINE_API_ENDPOINT", &cfg.LineAPIEndpoint)
+	setString("LINE_REPLY_ENDPOINT", &cfg.LineReplyEndpoint)
+	setString("LINE_MULTICAST_ENDPOINT", &cfg.LineMulticastEndpoint)
+	setString("LINE_BROADCAST_ENDPOINT", &cfg.LineBroadcastEndpoint)
+	setString("LINE_QUOTA_ENDPOINT", &cfg.LineQuotaEndpoint)
+	setBool("GRPC", &cfg.Grpc)
+	setString("GRPC_AUDIENCE", &cfg.GrpcAudience)
+	setList("GRPC_CALLERS", &cfg.GrpcCallers)
+	setBool("STATION_NOTIFICATIONS", &cfg.StationNotifications)
+	setString("SCHEDULER_TOKEN_NAME", &cfg.SchedulerTokenName)
+	setString("TIMEZONE", &cfg.Timezone)
+	setFloat("QUOTA_BUDGET", &cfg.QuotaBudget)
+	setDuration("QUOTA_REFRESH_INTERVAL", &cfg.QuotaRefreshInterval)
+	setString("ADMIN_AUDIENCE", &cfg.AdminAudience)
+	setList("ADMIN_EMAILS", &cfg.AdminEmails)
+	setList("API_ALLOWED_ORIGINS", &cfg.ApiAllowedOrigins)
+	setString("MAP_PROVIDER", &cfg.MapProvider)
+	setString("MAP_TILE_URL", &cfg.MapTileUrl)
+	setString("GOOGLE_MAPS_KEY", &cfg.GoogleMapsKey)
+	setString("PUBLIC_URL", &cfg.PublicUrl)
+	setString("ROUTING_ENDPOINT", &cfg.RoutingEndpoint)
+	setString("ROUTING_PROFILE", &cfg.RoutingProfile)
+	setFloat("SEARCH_RADIUS", &cfg.SearchRadius)
+	setInt("RESULT_COUNT", &cfg.ResultCount)
+	setInt("EVENT_WORKERS", &cfg.EventWorkers)
+	setInt("EVENT_QUEUE_SIZE", &cfg.EventQueueSize)
+	setFloat("USER_RATE_LIMIT", &cfg.UserRateLimit)
+	setInt("USER_RATE_BURST", &cfg.UserRateBurst)
+	setFloat("RATE_LIMIT", &cfg.RateLimit)
+	setInt("RATE_BURST", &cfg.RateBurst)
+	setString("LOG_LEVEL", &cfg.LogLevel)
+	setString("OTEL_TRACES_EXPORTER", &cfg.TracesExporter)
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %q", c.Port))
+	}
+	switch c.SecretBackend {
+	case SecretManagerBackend:
+		if c.SecretProjectId == "" {
+			errs = append(errs, fmt.Errorf("secretProjectId (SECRET_PROJECT_ID) is required by the secretmanager backend"))
+		}
+	case EnvSecretBackend:
+	case FileSecretBackend:
+		if c.SecretDir == "" {
+			errs = append(errs, fmt.Errorf("secretDir (SECRET_DIR) is required by the file backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("secretBackend must be secretmanager, env or file, got %q", c.SecretBackend))
+	}
+
+	if len(c.Channels) == 0 {
+		if c.ChannelId == "" && c.SecretName == "" {
+			errs = append(errs, fmt.Errorf("secretName (SECRET_NAME) is required"))
+		}
+
+		if c.ChannelSecretName == "" {
+			errs = append(errs, fmt.Errorf("channelSecretName (CHANNEL_SECRET_NAME) is required to validate the webhook signatures"))
+		}
+	}
+
+	destinations := map[string]bool{}
+	for i, channel := range c.Channels {
+		if channel.Name == "" {
+			errs = append(errs, fmt.Errorf("channels[%d].name is required", i))
+		}
+
+		// the destination is only trusted once the signature is validated with the channel secret
+		if channel.Destination == "" {
+			errs = append(errs, fmt.Errorf("channels[%d].destination is required", i))
+		} else if destinations[channel.Destination] {
+			errs = append(errs, fmt.Errorf("channels[%d].destination %s is already used by another channel", i, channel.Destination))
+		}
+		destinations[channel.Destination] = true
+
+		if channel.ChannelSecretName == "" {
+			errs = append(errs, fmt.Errorf("channels[%d].channelSecretName is required", i))
+		}
+
+		if channel.SecretName == "" && channel.ChannelId == "" {
+			errs = append(errs, fmt.Errorf("channels[%d].secretName or channels[%d].channelId is required", i, i))
+		}
+	}
+
+	if c.usesChannelId() {
+		if u, err := url.Parse(c.TokenEndpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tokenEndpoint must be an absolute HTTP(S) URL, got %q", c.TokenEndpoint))
+		}
+	}
+
+	if c.TokenRefreshInterval < Duration(time.Minute) {
+		errs = append(errs, fmt.Errorf("tokenRefreshInterval must be at least 1m, got %v", time.Duration(c.TokenRefreshInterval)))
+	}
+
+	if c.LineAPIEndpoint == "" {
+		errs = append(errs, fmt.Errorf("lineApiEndpoint (LINE_API_ENDPOINT) is required"))
+	} else if u, err := url.Parse(c.LineAPIEndpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("lineApiEndpoint must be an absolute HTTP(S) URL, got %q", c.LineAPIEndpoint))
+	}
+
+	for name, endpoint := range map[string]string{
+		"lineReplyEndpoint":     c.LineReplyEndpoint,
+		"lineMulticastEndpoint": c.LineMulticastEndpoint,
+		"lineBroadcastEndpoint": c.LineBroadcastEndpoint,
+		"lineQuotaEndpoint":     c.LineQuotaEndpoint,
+	} {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an absolute HTTP(S) URL, got %q", name, endpoint))
+		}
+	}
+
+	if c.QuotaBudget <= 0 || c.QuotaBudget > 1 {
+		errs = append(errs, fmt.Errorf("quotaBudget must be greater than 0 and at most 1, got %v", c.QuotaBudget))
+	}
+
+	if c.QuotaRefreshInterval < Duration(time.Minute) {
+		errs = append(errs, fmt.Errorf("quotaRefreshInterval must be at least 1m, got %v", time.Duration(c.QuotaRefreshInterval)))
+	}
+
+	if _, err := time.LoadLocation(c.Timezone); err != nil || c.Timezone == "" {
+		errs = append(errs, fmt.Errorf("timezone must be an IANA time zone like Asia/Taipei, got %q", c.Timezone))
+	}
+
+	if c.AdminAudience != "" && len(c.AdminEmails) == 0 {
+		errs = append(errs, fmt.Errorf("adminEmails (ADMIN_EMAILS) is required by the admin API"))
+	}
+
+	if c.Grpc && (c.GrpcAudience == "" || len(c.GrpcCallers) == 0) {
+		errs = append(errs, fmt.Errorf("grpcAudience (GRPC_AUDIENCE) and grpcCallers (GRPC_CALLERS) are required by the gRPC station search"))
+	}
+
+	switch c.MapProvider {
+	case "":
+	case GoogleMapProvider:
+		if c.GoogleMapsKey == "" {
+			errs = append(errs, fmt.Errorf("googleMapsKey (GOOGLE_MAPS_KEY) is required by the google map provider"))
+		}
+	case LocalMapProvider:
+		if u, err := url.Parse(c.PublicUrl); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("publicUrl (PUBLIC_URL) must be an HTTPS URL for the local map provider, got %q", c.PublicUrl))
+		}
+		if u, err := url.Parse(c.MapTileUrl); err != nil || !u.IsAbs() || u.Host == "" || !strings.Contains(c.MapTileUrl, "{z}") || !strings.Contains(c.MapTileUrl, "{x}") || !strings.Contains(c.MapTileUrl, "{y}") {
+			errs = append(errs, fmt.Errorf("mapTileUrl (MAP_TILE_URL) must be a tile URL template with {z}, {x} and {y} for the local map provider, got %q", c.MapTileUrl))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mapProvider must be google or local, got %q", c.MapProvider))
+	}
+
+	if c.RoutingEndpoint != "" {
+		if u, err := url.Parse(c.RoutingEndpoint); err != nil || !u.IsAbs() || u.Host == "" {
+			errs = append(errs, fmt.Errorf("routingEndpoint must be an absolute URL, got %q", c.RoutingEndpoint))
+		}
+		if c.RoutingProfile == "" {
+			errs = append(errs, fmt.Errorf("routingProfile (ROUTING_PROFILE) is required by the routing endpoint"))
+		}
+	}
+
+	if c.SearchRadius <= 0 || c.SearchRadius > 1 {
+		errs = append(errs, fmt.Errorf("searchRadius must be greater than 0 and at most 1 degree, got %v", c.SearchRadius))
+	}
+
+	if c.ResultCount < 1 || c.ResultCount > maxResultCount {
+		errs = append(errs, fmt.Errorf("resultCount must be between 1 and %d, got %d", maxResultCount, c.ResultCount))
+	}
+
+	if c.EventWorkers < 1 {
+		errs = append(errs, fmt.Errorf("eventWorkers must be at least 1, got %d", c.EventWorkers))
+	}
+
+	if c.EventQueueSize < 1 {
+		errs = append(errs, fmt.Errorf("eventQueueSize must be at least 1, got %d", c.EventQueueSize))
+	}
+
+	if c.UserRateLimit <= 0 || c.UserRateBurst < 1 {
+		errs = append(errs, fmt.Errorf("userRateLimit must be greater than 0 and userRateBurst at least 1, got %v and %d", c.UserRateLimit, c.UserRateBurst))
+	}
+
+	if c.RateLimit <= 0 || c.RateBurst < 1 {
+		errs = append(errs, fmt.Errorf("rateLimit must be greater than 0 and rateBurst at least 1, got %v and %d", c.RateLimit, c.RateBurst))
+	}
+
+	if _, err := libs.ParseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("logLevel must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+
+	switch c.TracesExporter {
+	case "", "none", "otlp", "stdout", "console":
+	default:
+		errs = append(errs, fmt.Errorf("tracesExporter must be otlp, stdout or none, got %q", c.TracesExporter))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// ChannelConfigs returns the channels served by the deployment, with the default branding applied.
+// Without channels, the top-level settings configure a single channel matching any destination.
+func (c *Config) ChannelConfigs() []Channel {
+	channels := c.Channels
+	if len(channels) == 0 {
+		channels = []Channel{{
+			Name:              "default",
+			SecretName:        c.SecretName,
+			ChannelSecretName: c.ChannelSecretName,
+			ChannelId:         c.ChannelId,
+			Branding:          c.Branding,
+		}}
+	}
+
+	result := make([]Channel, len(channels))
+	for i, channel := range channels {
+		channel.Branding = channel.Branding.WithDefaults()
+		result[i] = channel
+	}
+
+	return result
+}
+
+// ChannelNamed returns the channel of the name, the first channel when name is empty.
+func (c *Config) ChannelNamed(name string) (Channel, bool) {
+	channels := c.ChannelConfigs()
+	if name == "" {
+		return channels[0], true
+	}
+
+	for _, channel := range channels {
+		if channel.Name == name {
+			return channel, true
+		}
+	}
+
+	return Channel{}, false
+}
+
+// TokenSource returns the source of the channel access tokens of the channel, issued with the
+// channel ID and secret when the channel has an ID, read from its secret otherwise.
+func (c *Config) TokenSource(channel Channel, secrets libs.SecretProvider, channelSecret string) libs.TokenSource {
+	if channel.ChannelId != "" {
+		return libs.ChannelTokenSource{
+			Endpoint:      c.TokenEndpoint,
+			ChannelId:     channel.ChannelId,
+			ChannelSecret: channelSecret,
+		}
+	}
+
+	return libs.SecretTokenSource{Secrets: secrets, Name: channel.SecretName}
+}
+
+func (c *Config) usesChannelId() bool {
+	for _, channel := range c.ChannelConfigs() {
+		if channel.ChannelId != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SecretProvider returns the provider of the configured backend.
+func (c *Config) SecretProvider() libs.SecretProvider {
+	switch c.SecretBackend {
+	case EnvSecretBackend:
+		return libs.EnvSecretProvider{}
+	case FileSecretBackend:
+		return libs.NewFileSecretProvider(c.SecretDir)
+	default:
+		return libs.NewSecretManagerProvider(c.SecretProjectId)
+	}
+}
+
+// StaticMapProvider returns the provider of the station maps, nil when the maps are not shown.
+func (c *Config) StaticMapProvider() libs.StaticMapProvider {
+	switch c.MapProvider {
+	case GoogleMapProvider:
+		return libs.GoogleStaticMap{Key: c.GoogleMapsKey}
+	case LocalMapProvider:
+		return libs.LocalStaticMap{BaseUrl: strings.TrimSuffix(c.PublicUrl, "/")}
+	default:
+		return nil
+	}
+}
+
+// RoutingProvider returns the OSRM routing falling back to the straight-line estimation, nil when
+// no routing endpoint is configured.
+func (c *Config) RoutingProvider() libs.RoutingProvider {
+	if c.RoutingEndpoint == "" {
+		return nil
+	}
+
+	return libs.FallbackRouting{
+		Primary:  libs.NewOSRMRouting(c.RoutingEndpoint, c.RoutingProfile, routingTimeout),
+		Fallback: libs.DefaultHaversineRouting,
+	}
+}
+
+// Duration is a time.Duration written as a string like "1h" in the config file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"1h\": %v", err)
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,321 @@
+package config
+
+import (
+	"ohohestudio/sogorro/libs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.json")
+	os.WriteFile(configFile, []byte(`{"secretProjectId":"file-project","secretName":"file-secret","channelSecretName":"file-channel-secret","resultCount":5,"tokenRefreshInterval":"30m"}`), 0600)
+	channelsFile := filepath.Join(dir, "channels.json")
+	os.WriteFile(channelsFile, []byte(`{"channels":[
+		{"name":"production","destination":"Uproduction","secretName":"production-token","channelSecretName":"production-secret"},
+		{"name":"partner","destination":"Upartner","channelId":"1234","channelSecretName":"partner-secret","branding":{"altText":"Partner"}}
+	]}`), 0600)
+	invalidChannelsFile := filepath.Join(dir, "invalid-channels.json")
+	os.WriteFile(invalidChannelsFile, []byte(`{"channels":[
+		{"name":"production","destination":"Uproduction","secretName":"production-token"},
+		{"destination":"Uproduction","channelSecretName":"staging-secret"}
+	]}`), 0600)
+	unknownFile := filepath.Join(dir, "unknown.json")
+	os.WriteFile(unknownFile, []byte(`{"secretNmae":"typo"}`), 0600)
+
+	required := map[string]string{
+		"SECRET_PROJECT_ID":   "secret-project",
+		"SECRET_NAME":         "secret-name",
+		"CHANNEL_SECRET_NAME": "channel-secret",
+		"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+	}
+
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expected      func(t *testing.T, cfg *Config)
+		expectedError []string
+	}{
+		{
+			name: "defaults",
+			env:  required,
+			expected: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, DefaultPort, cfg.Port)
+				assert.Equal(t, DefaultSearchRadius, cfg.SearchRadius)
+				assert.Equal(t, DefaultResultCount, cfg.ResultCount)
+				assert.Equal(t, "secret-name", cfg.SecretName)
+				assert.Equal(t, DefaultTokenRefresh, cfg.TokenRefreshInterval)
+				assert.Equal(t, DefaultUserRateLimit, cfg.UserRateLimit)
+				assert.Equal(t, DefaultRateBurst, cfg.RateBurst)
+				assert.Equal(t, DefaultQuotaBudget, cfg.QuotaBudget)
+				assert.Equal(t, DefaultReplyEndpoint, cfg.LineReplyEndpoint)
+				assert.Equal(t, DefaultTimezone, cfg.Timezone)
+				assert.IsType(t, &libs.SecretManagerProvider{}, cfg.SecretProvider())
+				assert.Nil(t, cfg.StaticMapProvider())
+				assert.Equal(t, DefaultRoutingProfile, cfg.RoutingProfile)
+				assert.Nil(t, cfg.RoutingProvider())
+			},
+		},
+		{
+			name: "environment overrides the file",
+			env: map[string]string{
+				"CONFIG_FILE":       configFile,
+				"SECRET_NAME":       "env-secret",
+				"LINE_API_ENDPOINT": "https://api.line.me/v2/bot/message/push",
+				"SEARCH_RADIUS":     "0.05",
+				"GRPC":              "true",
+				"ROUTING_ENDPOINT":  "http://osrm:5000",
+
+				"STATION_NOTIFICATIONS": "true",
+				"ADMIN_AUDIENCE":        "https://sogorro.example.com",
+				"ADMIN_EMAILS":          "ops@example.com, admin@example.com",
+				"GRPC_AUDIENCE":         "https://sogorro.example.com",
+				"GRPC_CALLERS":          "search@example.iam.gserviceaccount.com",
+			},
+			expected: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "file-project", cfg.SecretProjectId)
+				assert.Equal(t, "env-secret", cfg.SecretName)
+				assert.Equal(t, 5, cfg.ResultCount)
+				assert.Equal(t, 0.05, cfg.SearchRadius)
+				assert.Equal(t, Duration(30*time.Minute), cfg.TokenRefreshInterval)
+				assert.True(t, cfg.Grpc)
+				assert.IsType(t, libs.FallbackRouting{}, cfg.RoutingProvider())
+				assert.True(t, cfg.StationNotifications)
+				assert.Equal(t, []string{"ops@example.com", "admin@example.com"}, cfg.AdminEmails)
+				assert.Equal(t, []string{"search@example.iam.gserviceaccount.com"}, cfg.GrpcCallers)
+			},
+		},
+		{
+			name:          "missing required settings",
+			env:           map[string]string{},
+			expectedError: []string{"SECRET_PROJECT_ID", "SECRET_NAME", "LINE_API_ENDPOINT"},
+		},
+		{
+			name: "invalid values",
+			env: map[string]string{
+				"SECRET_PROJECT_ID": "secret-project",
+				"SECRET_NAME":       "secret-name",
+				"LINE_API_ENDPOINT": "api.line.me",
+				"PORT":              "http",
+				"ROUTING_ENDPOINT":  "osrm",
+				"RESULT_COUNT":      "10",
+				"SEARCH_RADIUS":     "-1",
+				"LOG_LEVEL":         "verbose",
+				"USER_RATE_LIMIT":   "0",
+				"RATE_BURST":        "0",
+				"QUOTA_BUDGET":      "90",
+				"TIMEZONE":          "Taipei",
+				"ADMIN_AUDIENCE":    "https://sogorro.example.com",
+				"GRPC":              "true",
+			},
+			expectedError: []string{"ADMIN_EMAILS", "GRPC_CALLERS", "port", "routingEndpoint", "lineApiEndpoint must be an absolute", "resultCount", "searchRadius", "logLevel", "userRateLimit", "rateBurst", "quotaBudget", "timezone"},
+		},
+		{
+			name: "short-lived tokens from the channel ID",
+			env: map[string]string{
+				"SECRET_PROJECT_ID":   "secret-project",
+				"LINE_CHANNEL_ID":     "1234567890",
+				"CHANNEL_SECRET_NAME": "channel-secret",
+				"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+			},
+			expected: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, "1234567890", cfg.ChannelId)
+				assert.Equal(t, DefaultTokenEndpoint, cfg.TokenEndpoint)
+			},
+		},
+		{
+			name: "channel ID requires the channel secret",
+			env: map[string]string{
+				"SECRET_PROJECT_ID":      "secret-project",
+				"LINE_CHANNEL_ID":        "1234567890",
+				"LINE_API_ENDPOINT":      "https://api.line.me/v2/bot/message/push",
+				"TOKEN_REFRESH_INTERVAL": "1s",
+			},
+			expectedError: []string{"CHANNEL_SECRET_NAME", "tokenRefreshInterval must be at least 1m"},
+		},
+		{
+			name: "access token requires the channel secret",
+			env: map[string]string{
+				"SECRET_PROJECT_ID": "secret-project",
+				"SECRET_NAME":       "secret-name",
+				"LINE_API_ENDPOINT": "https://api.line.me/v2/bot/message/push",
+			},
+			expectedError: []string{"channelSecretName (CHANNEL_SECRET_NAME) is required"},
+		},
+		{
+			name: "single channel from the top-level settings",
+			env:  required,
+			expected: func(t *testing.T, cfg *Config) {
+				channels := cfg.ChannelConfigs()
+				assert.Len(t, channels, 1)
+				assert.Equal(t, "", channels[0].Destination)
+				assert.Equal(t, "secret-name", channels[0].SecretName)
+				assert.Equal(t, Branding{}.WithDefaults(), channels[0].Branding)
+			},
+		},
+		{
+			name: "channels from the file",
+			env: map[string]string{
+				"CONFIG_FILE":       channelsFile,
+				"SECRET_PROJECT_ID": "secret-project",
+				"LINE_API_ENDPOINT": "https://api.line.me/v2/bot/message/push",
+			},
+			expected: func(t *testing.T, cfg *Config) {
+				channels := cfg.ChannelConfigs()
+				assert.Len(t, channels, 2)
+				assert.Equal(t, "Uproduction", channels[0].Destination)
+				assert.Equal(t, DefaultAltText, channels[0].Branding.AltText)
+				assert.Equal(t, "Partner", channels[1].Branding.AltText)
+				assert.Equal(t, DefaultWelcomeText, channels[1].Branding.WelcomeText)
+
+				channel, ok := cfg.ChannelNamed("")
+				assert.True(t, ok)
+				assert.Equal(t, channels[0], channel)
+				channel, ok = cfg.ChannelNamed(channels[1].Name)
+				assert.True(t, ok)
+				assert.Equal(t, channels[1], channel)
+				_, ok = cfg.ChannelNamed("unknown")
+				assert.False(t, ok)
+			},
+		},
+		{
+			name: "invalid channels",
+			env: map[string]string{
+				"CONFIG_FILE":       invalidChannelsFile,
+				"SECRET_PROJECT_ID": "secret-project",
+				"LINE_API_ENDPOINT": "https://api.line.me/v2/bot/message/push",
+			},
+			expectedError: []string{
+				"channels[0].channelSecretName is required",
+				"channels[1].name is required",
+				"channels[1].destination Uproduction is already used",
+				"channels[1].secretName or channels[1].channelId is required",
+			},
+		},
+		{
+			name: "malformed duration",
+			env: map[string]string{
+				"TOKEN_REFRESH_INTERVAL": "hourly",
+			},
+			expectedError: []string{"TOKEN_REFRESH_INTERVAL must be a duration"},
+		},
+		{
+			name: "malformed bool",
+			env: map[string]string{
+				"STATION_NOTIFICATIONS": "yes please",
+			},
+			expectedError: []string{"STATION_NOTIFICATIONS must be true or false"},
+		},
+		{
+			name: "malformed number",
+			env: map[string]string{
+				"RESULT_COUNT": "three",
+			},
+			expectedError: []string{"RESULT_COUNT must be an integer"},
+		},
+		{
+			name: "env secret backend without Secret Manager project",
+			env: map[string]string{
+				"SECRET_BACKEND":      "env",
+				"SECRET_NAME":         "LINEBOT_ACCESS_TOKEN",
+				"CHANNEL_SECRET_NAME": "LINEBOT_CHANNEL_SECRET",
+				"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+			},
+			expected: func(t *testing.T, cfg *Config) {
+				assert.IsType(t, libs.EnvSecretProvider{}, cfg.SecretProvider())
+			},
+		},
+		{
+			name: "file secret backend requires a directory",
+			env: map[string]string{
+				"SECRET_BACKEND":      "file",
+				"SECRET_NAME":         "line-token",
+				"CHANNEL_SECRET_NAME": "LINEBOT_CHANNEL_SECRET",
+				"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+			},
+			expectedError: []string{"SECRET_DIR"},
+		},
+		{
+			name: "unknown secret backend",
+			env: map[string]string{
+				"SECRET_BACKEND":      "vault",
+				"SECRET_NAME":         "line-token",
+				"CHANNEL_SECRET_NAME": "LINEBOT_CHANNEL_SECRET",
+				"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+			},
+			expectedError: []string{"secretBackend must be secretmanager, env or file"},
+		},
+		{
+			name: "local map provider",
+			env: map[string]string{
+				"SECRET_BACKEND":      "env",
+				"SECRET_NAME":         "LINEBOT_ACCESS_TOKEN",
+				"CHANNEL_SECRET_NAME": "LINEBOT_CHANNEL_SECRET",
+				"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+				"MAP_PROVIDER":        "local",
+				"PUBLIC_URL":          "https://sogorro.example.com/",
+				"MAP_TILE_URL":        "https://tiles.example.com/{z}/{x}/{y}.png",
+			},
+			expected: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, libs.LocalStaticMap{BaseUrl: "https://sogorro.example.com"}, cfg.StaticMapProvider())
+			},
+		},
+		{
+			name: "local map provider requires an HTTPS public URL and the tiles",
+			env: map[string]string{
+				"SECRET_BACKEND":      "env",
+				"SECRET_NAME":         "LINEBOT_ACCESS_TOKEN",
+				"CHANNEL_SECRET_NAME": "LINEBOT_CHANNEL_SECRET",
+				"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+				"MAP_PROVIDER":        "local",
+				"PUBLIC_URL":          "http://localhost:8080",
+				"MAP_TILE_URL":        "https://tiles.example.com/tile.png",
+			},
+			expectedError: []string{"PUBLIC_URL", "MAP_TILE_URL"},
+		},
+		{
+			name: "google map provider requires a key",
+			env: map[string]string{
+				"SECRET_BACKEND":      "env",
+				"SECRET_NAME":         "LINEBOT_ACCESS_TOKEN",
+				"CHANNEL_SECRET_NAME": "LINEBOT_CHANNEL_SECRET",
+				"LINE_API_ENDPOINT":   "https://api.line.me/v2/bot/message/push",
+				"MAP_PROVIDER":        "google",
+			},
+			expectedError: []string{"GOOGLE_MAPS_KEY"},
+		},
+		{
+			name:          "unknown field in file",
+			env:           map[string]string{"CONFIG_FILE": unknownFile},
+			expectedError: []string{"unknown field"},
+		},
+		{
+			name:          "missing file",
+			env:           map[string]string{"CONFIG_FILE": filepath.Join(dir, "missing.json")},
+			expectedError: []string{"failed to read config file"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load(func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			})
+
+			if len(tt.expectedError) > 0 {
+				for _, expected := range tt.expectedError {
+					assert.ErrorContains(t, err, expected)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.expected(t, cfg)
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/config"
+	"ohohestudio/sogorro/libs"
+	"ohohestudio/sogorro/linefake"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStations is a stationFinder over stations kept in memory, keyed by ID.
+type memoryStations map[string]libs.GoStation
+
+func (m memoryStations) Nearby(ctx context.Context, latitude, longitude float64, limit int) ([]libs.GoStation, error) {
+	stations := []libs.GoStation{}
+	for _, station := range m {
+		if station.State != libs.ActiveState {
+			continue
+		}
+		station.Distance = libs.Haversine(station.Latitude, station.Longitude, latitude, longitude)
+		stations = append(stations, station)
+	}
+
+	sort.Slice(stations, func(j, k int) bool { return stations[j].Distance < stations[k].Distance })
+	if len(stations) > limit {
+		stations = stations[:limit]
+	}
+
+	return stations, nil
+}
+
+func (m memoryStations) Get(ctx context.Context, id string) (libs.GoStation, error) {
+	station, ok := m[id]
+	if !ok {
+		return libs.GoStation{}, libs.ErrStationNotFound
+	}
+
+	return station, nil
+}
+
+func (m memoryStations) All(ctx context.Context, filter libs.StationFilter) ([]libs.GoStation, error) {
+	stations := []libs.GoStation{}
+	for _, station := range m {
+		if filter.Match(station) {
+			stations = append(stations, station)
+		}
+	}
+
+	sort.Slice(stations, func(j, k int) bool { return stations[j].Id < stations[k].Id })
+	return stations, nil
+}
+
+// newConversation starts the bot against a fake LINE platform.
+func newConversation(t *testing.T) (*App, *linefake.Server, string) {
+	fake := linefake.New("access-token", "channel-secret")
+	t.Cleanup(fake.Close)
+
+	app := &App{
+		config: &config.Config{
+			LineAPIEndpoint:       fake.PushEndpoint(),
+			LineReplyEndpoint:     fake.ReplyEndpoint(),
+			LineMulticastEndpoint: fake.MulticastEndpoint(),
+			ResultCount:           config.DefaultResultCount,
+		},
+		stations:      memoryStations{},
+		subscriptions: libs.NewMemorySubscriptionStore(),
+		users:         libs.NewMemoryUserStore(),
+		dedupe:        libs.NewMemoryDedupeStore(time.Hour),
+		defaultChannel: &channel{
+			name:          "fake",
+			channelSecret: fake.ChannelSecret,
+			tokens:        libs.NewTokenManager(libs.StaticTokenSource(fake.AccessToken), 0),
+			branding:      config.Branding{}.WithDefaults(),
+		},
+		makeRequest: libs.MakeRequestContext,
+	}
+	app.queue = newEventQueue(1, 10, app.processEvent)
+
+	bot := httptest.NewServer(app.router())
+	t.Cleanup(bot.Close)
+
+	return app, fake, bot.URL + "/station"
+}
+
+func TestConversationWelcome(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+
+	event := fake.TextEvent("user-id", "你好")
+	resp, err := fake.Deliver(webhookURL, event)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// redelivery of the same event is not answered twice
+	event.DeliveryContext.IsRedelivery = true
+	resp, err = fake.Deliver(webhookURL, event)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.NoError(t, app.queue.drain(context.TODO()))
+	assert.Empty(t, fake.Rejections())
+
+	messages := fake.MessagesTo("user-id")
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "text", messages[0].Type)
+	assert.Contains(t, messages[0].Text, "歡迎使用 sogorro")
+	assert.NotNil(t, messages[0].Raw["quickReply"])
+}
+
+func TestConversationRejectsForgedWebhook(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+
+	fake.ChannelSecret = "forged-secret"
+	resp, err := fake.Deliver(webhookURL, fake.TextEvent("user-id", "你好"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	assert.NoError(t, app.queue.drain(context.TODO()))
+	assert.Empty(t, fake.Messages())
+}
+
+func TestConversationRepliesOverPushBudget(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+	fake.SetQuota(2)
+	ch := app.defaultChannel
+	ch.quota = libs.NewQuotaTracker(ch.name, fake.QuotaEndpoint(), ch.tokens, 0.5, time.Minute)
+
+	resp, err := fake.Deliver(webhookURL, fake.TextEvent("user-id", "你好"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	// the first push reaches half of the quota
+	assert.NoError(t, ch.quota.Refresh(context.TODO()))
+	assert.True(t, ch.quota.OverBudget())
+
+	app.queue = newEventQueue(1, 10, app.processEvent)
+	resp, err = fake.Deliver(webhookURL, fake.TextEvent("user-id", "再一次"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// events without a reply token can't be answered without pushing
+	event := fake.TextEvent("user-id", "沒有 reply token")
+	event.ReplyToken = ""
+	resp, err = fake.Deliver(webhookURL, event)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	assert.Empty(t, fake.Rejections())
+	messages := fake.MessagesTo("user-id")
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "push", messages[0].Endpoint)
+	assert.Equal(t, "reply", messages[1].Endpoint)
+}
+
+func TestConversationRecordsSearchCity(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+	app.stations = memoryStations{
+		"station-1": {Id: "station-1", Location: "台北101站", City: "臺北市", District: "信義區", Latitude: 25.033964, Longitude: 121.564468, State: libs.ActiveState},
+	}
+
+	resp, err := fake.Deliver(webhookURL, fake.LocationEvent("rider-a", 25.03, 121.56))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	users, err := app.users.UsersInCity(context.TODO(), "臺北市")
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "rider-a", users[0].UserId)
+	assert.Equal(t, "fake", users[0].Channel)
+	assert.Equal(t, "信義區", users[0].District)
+}
+
+func TestConversationStationSubscription(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+	station := libs.GoStation{Id: "station-1", Location: "台北101站", Latitude: 25.033964, Longitude: 121.564468, State: libs.ActiveState}
+	app.stations = memoryStations{station.Id: station}
+
+	deliver := func(events ...libs.WebhookEvent) {
+		resp, err := fake.Deliver(webhookURL, events...)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// the bubble of a nearby station carries the subscribe button
+	deliver(fake.LocationEvent("rider-a", 25.03, 121.56))
+	deliver(fake.PostbackEvent("rider-a", libs.StationPostback(libs.SubscribeAction, station.Id)))
+	deliver(fake.PostbackEvent("rider-b", libs.StationPostback(libs.SubscribeAction, station.Id)))
+	deliver(fake.PostbackEvent("rider-b", libs.StationPostback(libs.SubscribeAction, "missing")))
+	deliver(fake.PostbackEvent("rider-a", libs.FavouritesPostback))
+	deliver(fake.PostbackEvent("rider-c", libs.FavouritesPostback))
+	assert.NoError(t, app.queue.drain(context.TODO()))
+	assert.Empty(t, fake.Rejections())
+
+	messages := fake.MessagesTo("rider-a")
+	assert.Len(t, messages, 3)
+	assert.Equal(t, "flex", messages[0].Type)
+	assert.Contains(t, messages[1].Text, "已訂閱「台北101站」")
+	assert.Contains(t, messages[2].Text, "台北101站 (服務中)")
+	assert.NotNil(t, messages[2].Raw["quickReply"])
+	assert.Contains(t, fake.MessagesTo("rider-b")[1].Text, "找不到這個充電站")
+	assert.Contains(t, fake.MessagesTo("rider-c")[0].Text, "還沒有訂閱任何充電站")
+
+	// the multicast of the offline station is refused, the change is left to the next listener
+	fake.Reset()
+	fake.SetQuota(1)
+	offline := libs.StationChange{Station: station, UpdatedAt: time.Now()}
+	offline.Station.State = 2
+	app.notifyStationChange(context.TODO(), offline)
+	assert.Len(t, fake.Rejections(), 1)
+	assert.Empty(t, fake.Messages())
+
+	// the station goes offline, seen by two instances
+	fake.Reset()
+	fake.SetQuota(0)
+	app.notifyStationChange(context.TODO(), offline)
+	app.notifyStationChange(context.TODO(), offline)
+	assert.Len(t, fake.Messages(), 2)
+
+	// a listener starting over sees the station again, the subscribers already know of its state
+	offline.UpdatedAt = time.Now().Add(time.Minute)
+	app.notifyStationChange(context.TODO(), offline)
+
+	// a change between two offline states isn't notified
+	offline.Station.State = 3
+	offline.UpdatedAt = time.Now().Add(2 * time.Minute)
+	app.notifyStationChange(context.TODO(), offline)
+
+	assert.Empty(t, fake.Rejections())
+	assert.Len(t, fake.Messages(), 2)
+	for _, rider := range []string{"rider-a", "rider-b"} {
+		messages := fake.MessagesTo(rider)
+		assert.Len(t, messages, 1)
+		assert.Equal(t, "multicast", messages[0].Endpoint)
+		assert.Contains(t, messages[0].Text, "「台北101站」目前暫停服務")
+	}
+
+	// unsubscribed riders are no longer notified
+	app.queue = newEventQueue(1, 10, app.processEvent)
+	deliver(fake.PostbackEvent("rider-b", libs.StationPostback(libs.UnsubscribeAction, station.Id)))
+	assert.NoError(t, app.queue.drain(context.TODO()))
+	fake.Reset()
+	app.notifyStationChange(context.TODO(), libs.StationChange{Station: station, UpdatedAt: time.Now().Add(3 * time.Minute)})
+
+	assert.Len(t, fake.Messages(), 1)
+	assert.Contains(t, fake.MessagesTo("rider-a")[0].Text, "已恢復服務")
+
+	// a subscription without the state the user knows of starts from the current one
+	fake.Reset()
+	assert.NoError(t, app.subscriptions.Subscribe(context.TODO(), libs.Subscription{UserId: "rider-d", StationId: station.Id, Channel: "fake"}))
+	offline.UpdatedAt = time.Now().Add(4 * time.Minute)
+	app.notifyStationChange(context.TODO(), offline)
+	assert.Len(t, fake.Messages(), 1)
+	assert.Empty(t, fake.MessagesTo("rider-d"))
+
+	subscribers, err := app.subscriptions.Subscribers(context.TODO(), station.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), subscribers[1].NotifiedState)
+}
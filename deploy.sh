@@ -3,8 +3,17 @@ GOOGLE_REGION="asia-east1"
 CLOUD_RUN_SERVICE=""
 SECRET_PROJECT_ID=""
 SECRET_NAME=""
+CHANNEL_SECRET_NAME=""
 LINE_API_ENDPOINT="https://api.line.me/v2/bot/message/push"
+# serves the gRPC station search on the same public port, Cloud Run sends the requests over h2c:
+# the callers must send a Google ID token for GRPC_AUDIENCE of a service account in GRPC_CALLERS
+GRPC="false"
+GRPC_AUDIENCE=""
+GRPC_CALLERS=""
 
+# the webhook answers before the events are processed, and the token refresh, the quota polling and
+# the station listeners run in the background: the CPU must stay allocated between the requests and
+# an instance must keep running for the listeners
 docker build -t "$GOOGLE_REGION-docker.pkg.dev/$GOOGLE_CLOUD_PROJECT/api/$CLOUD_RUN_SERVICE" .
 
 docker push "$GOOGLE_REGION-docker.pkg.dev/$GOOGLE_CLOUD_PROJECT/api/$CLOUD_RUN_SERVICE"
@@ -12,5 +21,8 @@ docker push "$GOOGLE_REGION-docker.pkg.dev/$GOOGLE_CLOUD_PROJECT/api/$CLOUD_RUN_
 gcloud run deploy $CLOUD_RUN_SERVICE --image "$GOOGLE_REGION-docker.pkg.dev/$GOOGLE_CLOUD_PROJECT/api/$CLOUD_RUN_SERVICE" \
     --platform managed \
     --region $GOOGLE_REGION \
-    --update-env-vars LINE_API_ENDPOINT=$LINE_API_ENDPOINT,SECRET_PROJECT_ID=$SECRET_PROJECT_ID,SECRET_NAME=$SECRET_NAME \
+    --use-http2 \
+    --no-cpu-throttling \
+    --min-instances 1 \
+    --update-env-vars LINE_API_ENDPOINT=$LINE_API_ENDPOINT,SECRET_PROJECT_ID=$SECRET_PROJECT_ID,SECRET_NAME=$SECRET_NAME,CHANNEL_SECRET_NAME=$CHANNEL_SECRET_NAME,GRPC=$GRPC,GRPC_AUDIENCE=$GRPC_AUDIENCE,GRPC_CALLERS=$GRPC_CALLERS \
     --allow-unauthenticated
\ No newline at end of file
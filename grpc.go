@@ -0,0 +1,243 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative stationpb/stations.proto
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"ohohestudio/sogorro/libs"
+	"ohohestudio/sogorro/stationpb"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// stationService serves the station search to the backend services over gRPC, sharing the
+// stations of the HTTP server.
+type stationService struct {
+	stationpb.UnimplementedStationServiceServer
+	app *App
+}
+
+// newGrpcServer returns the gRPC server of the station search.
+func (a *App) newGrpcServer() *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(a.rpcTracing, a.rpcAuth))
+	stationpb.RegisterStationServiceServer(server, &stationService{app: a})
+	return server
+}
+
+// grpcCalls counts the gRPC calls served on the HTTP port. The h2c connections are taken over
+// from the HTTP server, which doesn't wait for them on shutdown.
+type grpcCalls struct {
+	mu       sync.Mutex
+	stopping bool
+	running  sync.WaitGroup
+}
+
+// withGrpc serves the gRPC calls, the HTTP/2 requests of the gRPC content type, and passes the
+// other requests to next.
+func (a *App) withGrpc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		a.grpcCalls.mu.Lock()
+		if a.grpcCalls.stopping {
+			a.grpcCalls.mu.Unlock()
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		a.grpcCalls.running.Add(1)
+		a.grpcCalls.mu.Unlock()
+		defer a.grpcCalls.running.Done()
+
+		a.grpcServer.ServeHTTP(w, r)
+	})
+}
+
+// stopGrpc refuses the new calls and waits for the running ones to finish, or cancels them when
+// ctx is done.
+func (a *App) stopGrpc(ctx context.Context) {
+	a.grpcCalls.mu.Lock()
+	a.grpcCalls.stopping = true
+	a.grpcCalls.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		a.grpcCalls.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+	}
+
+	// GracefulStop doesn't support the calls served over HTTP, they are all done or cancelled here
+	a.grpcServer.Stop()
+}
+
+// rpcTracing starts the root span of the call, continuing the trace propagated in the metadata, and attaches a logger carrying a request ID, like
+// requestTracing and requestLogger of the HTTP server.
+func (a *App) rpcTracing(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := grpcmetadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	ctx, span := libs.Tracer.Start(ctx, strings.TrimPrefix(info.FullMethod, "/"), trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	logger := a.logger
+	if logger == nil {
+		logger = libs.LoggerFromContext(ctx)
+	}
+	logger = logger.With("requestId", newRequestId(), "method", info.FullMethod).With(libs.SpanAttrs(ctx, a.projectId)...)
+	ctx = libs.WithLogger(ctx, logger)
+
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+	span.SetAttributes(attribute.String("rpc.method", info.FullMethod), attribute.String("rpc.grpc.status_code", code.String()))
+	if code == codes.Internal || code == codes.Unavailable {
+		span.SetStatus(otelcodes.Error, err.Error())
+		logger.Error("gRPC call failed", "code", code.String(), "error", err)
+	}
+
+	return resp, err
+}
+
+// rpcAuth only lets through the calls bearing a Google ID token issued for the gRPC audience to
+// one of the callers, the gRPC calls share the public port of the webhook.
+func (a *App) rpcAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	logger := libs.LoggerFromContext(ctx)
+
+	md, _ := grpcmetadata.FromIncomingContext(ctx)
+	token, ok := strings.CutPrefix(metadataCarrier(md).Get("authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	caller, err := a.verifyIDToken(ctx, token, a.config.GrpcAudience, a.config.GrpcCallers)
+	if errors.Is(err, errUnknownAccount) {
+		logger.Warn("rejected gRPC call of unknown account", "email", caller)
+		return nil, status.Error(codes.PermissionDenied, "account is not a gRPC caller")
+	}
+	if err != nil {
+		logger.Warn("rejected gRPC call with invalid ID token", "error", err)
+		return nil, status.Error(codes.Unauthenticated, "invalid ID token")
+	}
+
+	return handler(libs.WithLogger(ctx, logger.With("caller", caller)), req)
+}
+
+// metadataCarrier reads and writes the trace context in the metadata of a gRPC call.
+type metadataCarrier grpcmetadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := grpcmetadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	grpcmetadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (s *stationService) Nearest(ctx context.Context, req *stationpb.NearestRequest) (*stationpb.NearestResponse, error) {
+	if !validCoordinate(req.Latitude, 90) || !validCoordinate(req.Longitude, 180) {
+		return nil, status.Errorf(codes.InvalidArgument, "latitude and longitude must be a location, got %v,%v", req.Latitude, req.Longitude)
+	}
+	if req.Limit < 0 || req.Limit > maxSearchLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d, got %d", maxSearchLimit, req.Limit)
+	}
+	if req.VmType < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "vm_type must be a station type like 1 or 3, got %d", req.VmType)
+	}
+
+	query := stationQuery{Latitude: req.Latitude, Longitude: req.Longitude, Limit: int(req.Limit), VMType: req.VmType, Source: "grpc"}
+	if query.Limit == 0 {
+		query.Limit = s.app.config.ResultCount
+	}
+
+	stations, err := s.app.searchStations(ctx, query)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to search stations: %v", err)
+	}
+
+	return &stationpb.NearestResponse{Stations: stationMessages(stations)}, nil
+}
+
+func (s *stationService) Get(ctx context.Context, req *stationpb.GetRequest) (*stationpb.Station, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	station, err := s.app.stations.Get(ctx, req.Id)
+	if errors.Is(err, libs.ErrStationNotFound) {
+		return nil, status.Errorf(codes.NotFound, "station %s not found", req.Id)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get station: %v", err)
+	}
+
+	return stationMessage(station), nil
+}
+
+func (s *stationService) ListByDistrict(ctx context.Context, req *stationpb.ListByDistrictRequest) (*stationpb.ListByDistrictResponse, error) {
+	if req.District == "" {
+		return nil, status.Error(codes.InvalidArgument, "district is required")
+	}
+
+	stations, err := s.app.stations.All(ctx, libs.StationFilter{City: req.City, District: req.District})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list stations: %v", err)
+	}
+
+	return &stationpb.ListByDistrictResponse{Stations: stationMessages(stations)}, nil
+}
+
+func stationMessage(station libs.GoStation) *stationpb.Station {
+	return &stationpb.Station{
+		Id:             station.Id,
+		Location:       station.Location,
+		Address:        station.Address,
+		City:           station.City,
+		District:       station.District,
+		Latitude:       station.Latitude,
+		Longitude:      station.Longitude,
+		Distance:       station.Distance,
+		VmType:         station.VMType,
+		State:          station.State,
+		TravelDistance: station.TravelDistance,
+		TravelMinutes:  int32(station.TravelMinutes),
+	}
+}
+
+func stationMessages(stations []libs.GoStation) []*stationpb.Station {
+	messages := make([]*stationpb.Station, 0, len(stations))
+	for _, station := range stations {
+		messages = append(messages, stationMessage(station))
+	}
+
+	return messages
+}
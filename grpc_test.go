@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/libs"
+	"ohohestudio/sogorro/stationpb"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcmetadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcCaller is the ID token of the service account allowed to call the gRPC station search.
+const grpcCaller = "Bearer search-token"
+
+// withGrpcCallers accepts the ID tokens of the test callers for the gRPC station search of the app.
+func withGrpcCallers(app *App) *App {
+	app.config.GrpcAudience = "https://sogorro.example.com"
+	app.config.GrpcCallers = []string{"search@example.iam.gserviceaccount.com"}
+	app.validateIDToken = func(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+		if audience != app.config.GrpcAudience {
+			return nil, errors.New("audience mismatch")
+		}
+		switch token {
+		case "search-token":
+			return &idtoken.Payload{Claims: map[string]interface{}{"email": "search@example.iam.gserviceaccount.com", "email_verified": true}}, nil
+		case "rider-token":
+			return &idtoken.Payload{Claims: map[string]interface{}{"email": "rider@example.com", "email_verified": true}}, nil
+		}
+		return nil, errors.New("invalid token")
+	}
+
+	return app
+}
+
+// newStationClient serves the gRPC station search of the app in memory.
+func newStationClient(t *testing.T, app *App) stationpb.StationServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	app.grpcServer = withGrpcCallers(app).newGrpcServer()
+	go app.grpcServer.Serve(listener)
+	t.Cleanup(app.grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return stationpb.NewStationServiceClient(conn)
+}
+
+func TestStationService(t *testing.T) {
+	client := newStationClient(t, &App{
+		config: testConfig(),
+		stations: memoryStations{
+			"taipei-101": {Id: "taipei-101", Location: "台北101站", City: "臺北市", District: "信義區", Latitude: 25.033964, Longitude: 121.564468, VMType: 1, State: libs.ActiveState},
+			"city-hall":  {Id: "city-hall", Location: "市政府站", City: "臺北市", District: "信義區", Latitude: 25.0375, Longitude: 121.5637, VMType: 3, State: libs.ActiveState},
+			"xinyi":      {Id: "xinyi", Location: "信義站", City: "臺北市", District: "信義區", Latitude: 25.0330, Longitude: 121.5654, VMType: 1, State: 2},
+			"banqiao":    {Id: "banqiao", Location: "板橋站", City: "新北市", District: "板橋區", Latitude: 25.0143, Longitude: 121.4672, VMType: 1, State: libs.ActiveState},
+		},
+	})
+	ctx := grpcmetadata.AppendToOutgoingContext(context.TODO(), "authorization", grpcCaller)
+
+	tests := []struct {
+		name             string
+		call             func() ([]*stationpb.Station, error)
+		expectedStations []string
+		expectedCode     codes.Code
+	}{
+		{
+			name: "nearest stations",
+			call: func() ([]*stationpb.Station, error) {
+				resp, err := client.Nearest(ctx, &stationpb.NearestRequest{Latitude: 25.0339, Longitude: 121.5645, Limit: 2})
+				return resp.GetStations(), err
+			},
+			expectedStations: []string{"taipei-101", "city-hall"},
+		},
+		{
+			name: "nearest Super GoStations",
+			call: func() ([]*stationpb.Station, error) {
+				resp, err := client.Nearest(ctx, &stationpb.NearestRequest{Latitude: 25.0339, Longitude: 121.5645, VmType: 3})
+				return resp.GetStations(), err
+			},
+			expectedStations: []string{"city-hall"},
+		},
+		{
+			name: "invalid location",
+			call: func() ([]*stationpb.Station, error) {
+				resp, err := client.Nearest(ctx, &stationpb.NearestRequest{Latitude: 121.5645, Longitude: 25.0339, Limit: 100})
+				return resp.GetStations(), err
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "not a number location",
+			call: func() ([]*stationpb.Station, error) {
+				resp, err := client.Nearest(ctx, &stationpb.NearestRequest{Latitude: math.NaN(), Longitude: math.Inf(1)})
+				return resp.GetStations(), err
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "station by ID",
+			call: func() ([]*stationpb.Station, error) {
+				station, err := client.Get(ctx, &stationpb.GetRequest{Id: "xinyi"})
+				return []*stationpb.Station{station}, err
+			},
+			expectedStations: []string{"xinyi"},
+		},
+		{
+			name: "missing station",
+			call: func() ([]*stationpb.Station, error) {
+				_, err := client.Get(ctx, &stationpb.GetRequest{Id: "missing"})
+				return nil, err
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "stations of a district",
+			call: func() ([]*stationpb.Station, error) {
+				resp, err := client.ListByDistrict(ctx, &stationpb.ListByDistrictRequest{City: "臺北市", District: "信義區"})
+				return resp.GetStations(), err
+			},
+			expectedStations: []string{"city-hall", "taipei-101", "xinyi"},
+		},
+		{
+			name: "missing district",
+			call: func() ([]*stationpb.Station, error) {
+				resp, err := client.ListByDistrict(ctx, &stationpb.ListByDistrictRequest{City: "臺北市"})
+				return resp.GetStations(), err
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "missing ID token",
+			call: func() ([]*stationpb.Station, error) {
+				_, err := client.Get(context.TODO(), &stationpb.GetRequest{Id: "xinyi"})
+				return nil, err
+			},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name: "invalid ID token",
+			call: func() ([]*stationpb.Station, error) {
+				ctx := grpcmetadata.AppendToOutgoingContext(context.TODO(), "authorization", "Bearer forged-token")
+				_, err := client.Get(ctx, &stationpb.GetRequest{Id: "xinyi"})
+				return nil, err
+			},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name: "unknown caller",
+			call: func() ([]*stationpb.Station, error) {
+				ctx := grpcmetadata.AppendToOutgoingContext(context.TODO(), "authorization", "Bearer rider-token")
+				_, err := client.Get(ctx, &stationpb.GetRequest{Id: "xinyi"})
+				return nil, err
+			},
+			expectedCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stations, err := tt.call()
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode != codes.OK {
+				return
+			}
+
+			ids := []string{}
+			for _, station := range stations {
+				ids = append(ids, station.Id)
+			}
+			assert.Equal(t, tt.expectedStations, ids)
+		})
+	}
+}
+
+func TestStationServiceOnHTTPPort(t *testing.T) {
+	app := &App{
+		config: testConfig(),
+		stations: memoryStations{
+			"taipei-101": {Id: "taipei-101", Location: "台北101站", City: "臺北市", District: "信義區", Latitude: 25.033964, Longitude: 121.564468, VMType: 1, State: libs.ActiveState},
+		},
+	}
+	app.grpcServer = withGrpcCallers(app).newGrpcServer()
+	server := httptest.NewServer(h2c.NewHandler(app.router(), &http2.Server{}))
+	defer server.Close()
+
+	conn, err := grpc.NewClient("passthrough:///"+server.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// the trace of the caller is continued
+	ctx := grpcmetadata.AppendToOutgoingContext(context.TODO(), libs.CloudTraceHeader, "205445aa7843bc8bf206b12000100000/1;o=1", "authorization", grpcCaller)
+	station, err := stationpb.NewStationServiceClient(conn).Get(ctx, &stationpb.GetRequest{Id: "taipei-101"})
+	assert.NoError(t, err)
+	assert.Equal(t, "台北101站", station.GetLocation())
+
+	var root sdktrace.ReadOnlySpan
+	for _, span := range spanRecorder.Ended() {
+		if span.SpanContext().TraceID().String() == "205445aa7843bc8bf206b12000100000" && span.Name() == "sogorro.stations.v1.StationService/Get" {
+			root = span
+		}
+	}
+	if assert.NotNil(t, root) {
+		assert.Equal(t, "0000000000000001", root.Parent().SpanID().String())
+	}
+
+	// the other requests are still served by the router
+	resp, err := http.Get(server.URL + "/healthz")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the calls are refused once the app is shutting down
+	app.stopGrpc(context.TODO())
+	_, err = stationpb.NewStationServiceClient(conn).Get(ctx, &stationpb.GetRequest{Id: "taipei-101"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -3,18 +3,109 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"ohohestudio/sogorro/config"
 	"ohohestudio/sogorro/libs"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-func mockMakeRequest(method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+// metricReader and spanRecorder collect the metrics and spans recorded by the tests through the
+// global providers.
+var (
+	metricReader = sdkmetric.NewManualReader()
+	spanRecorder = tracetest.NewSpanRecorder()
+)
+
+func TestMain(m *testing.M) {
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader)))
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	otel.SetTextMapPropagator(libs.CloudTraceContext{})
+	os.Exit(m.Run())
+}
+
+// counterValue sums the data points of the counter having all the attributes.
+func counterValue(t *testing.T, name string, attrs ...attribute.KeyValue) int64 {
+	var rm metricdata.ResourceMetrics
+	assert.NoError(t, metricReader.Collect(context.TODO(), &rm))
+
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if m.Name != name || !ok {
+				continue
+			}
+
+			for _, dp := range sum.DataPoints {
+				matched := true
+				for _, attr := range attrs {
+					if value, ok := dp.Attributes.Value(attr.Key); !ok || value != attr.Value {
+						matched = false
+					}
+				}
+				if matched {
+					total += dp.Value
+				}
+			}
+		}
+	}
+
+	return total
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		LineAPIEndpoint:   "https://api.line.me/v2/bot/message/push",
+		LineReplyEndpoint: config.DefaultReplyEndpoint,
+		SearchRadius:      config.DefaultSearchRadius,
+		ResultCount:       config.DefaultResultCount,
+	}
+}
+
+// testChannelSecret signs the webhooks of the test channel.
+const testChannelSecret = "channel-secret"
+
+// testChannel is the channel of the test apps, it handles any webhook destination.
+func testChannel(channelSecret string) *channel {
+	return &channel{
+		name:          "test",
+		channelSecret: channelSecret,
+		tokens:        libs.NewTokenManager(libs.StaticTokenSource("access-token"), 0),
+		branding:      config.Branding{}.WithDefaults(),
+	}
+}
+
+// signedWebhook is a webhook request bearing the signature of body with channelSecret.
+func signedWebhook(channelSecret string, body []byte) *http.Request {
+	mac := hmac.New(sha256.New, []byte(channelSecret))
+	mac.Write(body)
+
+	req := httptest.NewRequest(http.MethodPost, "/station", bytes.NewReader(body))
+	req.Header.Set("X-Line-Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func mockMakeRequest(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
 	result := map[string]interface{}{
 		"sentMessages": []map[string]string{
 			{
@@ -56,19 +147,14 @@ func mockWebhookEvent() libs.WebhookEvent {
 }
 
 func TestFindStation(t *testing.T) {
-	os.Setenv("LINE_API_ENDPOINT", "https://api.line.me/v2/bot/message/push")
-	os.Setenv("LINEBOT_ACCESS_TOKEN", "mock-token")
-
-	app := &App{
-		ctx:         context.TODO(),
-		makeRequest: mockMakeRequest,
-	}
-
 	tests := []struct {
 		name           string
 		webhookPayload interface{}
+		channelSecret  string
+		signature      string
 		expectedStatus int
 		expectedBody   string
+		expectedPushes int
 	}{
 		{
 			name: "non-location message",
@@ -79,27 +165,77 @@ func TestFindStation(t *testing.T) {
 				Destination: "destination",
 				Events:      []libs.WebhookEvent{mockWebhookEvent()},
 			},
+			channelSecret:  testChannelSecret,
 			expectedStatus: 200,
-			expectedBody:   `{"sentMessages":[{"id":"message-id","quoteToken":"message-quote-token"}]}`,
+			expectedBody:   `{}`,
+			expectedPushes: 1,
+		},
+		{
+			name: "webhook verification without events",
+			webhookPayload: map[string]interface{}{
+				"destination": "destination",
+				"events":      []libs.WebhookEvent{},
+			},
+			channelSecret:  testChannelSecret,
+			expectedStatus: 200,
+			expectedBody:   `{}`,
 		},
 		{
 			name:           "invalid line message",
 			webhookPayload: `{"invalidKey":"invalidValue"}`,
+			channelSecret:  testChannelSecret,
 			expectedStatus: 500,
 			expectedBody:   "failed to decode JSON string",
 		},
+		{
+			name: "invalid signature",
+			webhookPayload: map[string]interface{}{
+				"destination": "destination",
+				"events":      []libs.WebhookEvent{mockWebhookEvent()},
+			},
+			channelSecret:  testChannelSecret,
+			signature:      "aW52YWxpZA==",
+			expectedStatus: 401,
+			expectedBody:   "invalid webhook signature",
+		},
+		{
+			name: "channel without secret",
+			webhookPayload: map[string]interface{}{
+				"destination": "destination",
+				"events":      []libs.WebhookEvent{mockWebhookEvent()},
+			},
+			expectedStatus: 401,
+			expectedBody:   "invalid webhook signature",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			pushed := 0
+			app := &App{
+				config:         testConfig(),
+				dedupe:         libs.NewMemoryDedupeStore(time.Hour),
+				defaultChannel: testChannel(tt.channelSecret),
+				makeRequest: func(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+					pushed++
+					return mockMakeRequest(ctx, method, url, headers, payload)
+				},
+			}
+			app.queue = newEventQueue(1, 10, app.processEvent)
+
 			body, _ := json.Marshal(tt.webhookPayload)
-			req := httptest.NewRequest(http.MethodPost, "/station", bytes.NewReader(body))
+			req := signedWebhook(testChannelSecret, body)
 			req.Header.Set("Content-Type", "application/json")
+			if tt.signature != "" {
+				req.Header.Set("X-Line-Signature", tt.signature)
+			}
 
 			w := httptest.NewRecorder()
 			app.findStation(w, req)
+			assert.NoError(t, app.queue.drain(context.TODO()))
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedPushes, pushed)
 			if tt.expectedStatus == http.StatusOK {
 				assert.Equal(t, tt.expectedBody, w.Body.String())
 			} else {
@@ -108,3 +244,322 @@ func TestFindStation(t *testing.T) {
 		})
 	}
 }
+
+func TestFindStationRoutesByDestination(t *testing.T) {
+	production := testChannel("production-secret")
+	production.name = "production"
+	production.destination = "Uproduction"
+	partner := testChannel("partner-secret")
+	partner.name = "partner"
+	partner.destination = "Upartner"
+	partner.tokens = libs.NewTokenManager(libs.StaticTokenSource("partner-token"), 0)
+	partner.branding = config.Branding{WelcomeText: "歡迎使用 partner"}.WithDefaults()
+
+	var pushes []map[string]string
+	var mu sync.Mutex
+	app := &App{
+		config:   testConfig(),
+		dedupe:   libs.NewMemoryDedupeStore(time.Hour),
+		channels: map[string]*channel{production.destination: production, partner.destination: partner},
+		makeRequest: func(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+			body, _ := json.Marshal(payload)
+			mu.Lock()
+			pushes = append(pushes, map[string]string{"authorization": headers["Authorization"], "payload": string(body)})
+			mu.Unlock()
+			return mockMakeRequest(ctx, method, url, headers, payload)
+		},
+	}
+	app.queue = newEventQueue(1, 10, app.processEvent)
+
+	tests := []struct {
+		name           string
+		destination    string
+		channelSecret  string
+		expectedStatus int
+	}{
+		{name: "production channel", destination: "Uproduction", channelSecret: "production-secret", expectedStatus: http.StatusOK},
+		{name: "partner channel", destination: "Upartner", channelSecret: "partner-secret", expectedStatus: http.StatusOK},
+		{name: "signed with the secret of another channel", destination: "Upartner", channelSecret: "production-secret", expectedStatus: http.StatusUnauthorized},
+		{name: "unknown destination", destination: "Uunknown", channelSecret: "production-secret", expectedStatus: http.StatusNotFound},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := mockWebhookEvent()
+			event.WebhookEventId = fmt.Sprintf("webhook-event-%d", i)
+			body, _ := json.Marshal(map[string]interface{}{
+				"destination": tt.destination,
+				"events":      []libs.WebhookEvent{event},
+			})
+			w := httptest.NewRecorder()
+			app.findStation(w, signedWebhook(tt.channelSecret, body))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	assert.Len(t, pushes, 2)
+	assert.Equal(t, "Bearer access-token", pushes[0]["authorization"])
+	assert.Contains(t, pushes[0]["payload"], "歡迎使用 sogorro")
+	assert.Equal(t, "Bearer partner-token", pushes[1]["authorization"])
+	assert.Contains(t, pushes[1]["payload"], "歡迎使用 partner")
+}
+
+func TestFindStationSkipsProcessedEvent(t *testing.T) {
+	pushed := 0
+	app := &App{
+		config:         testConfig(),
+		dedupe:         libs.NewMemoryDedupeStore(time.Hour),
+		defaultChannel: testChannel(testChannelSecret),
+		makeRequest: func(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+			pushed++
+			return mockMakeRequest(ctx, method, url, headers, payload)
+		},
+	}
+	app.queue = newEventQueue(1, 10, app.processEvent)
+
+	event := mockWebhookEvent()
+	redelivered := mockWebhookEvent()
+	redelivered.DeliveryContext.IsRedelivery = true
+
+	redeliveries := counterValue(t, libs.MetricWebhookEvents, attribute.Bool("redelivery", true))
+	duplicates := counterValue(t, libs.MetricWebhookDuplicates)
+	for _, e := range []libs.WebhookEvent{event, redelivered} {
+		body, _ := json.Marshal(map[string]interface{}{
+			"destination": "destination",
+			"events":      []libs.WebhookEvent{e},
+		})
+		req := signedWebhook(testChannelSecret, body)
+		w := httptest.NewRecorder()
+		app.findStation(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	assert.Equal(t, 1, pushed)
+	assert.Equal(t, redeliveries+1, counterValue(t, libs.MetricWebhookEvents, attribute.Bool("redelivery", true)))
+	assert.Equal(t, duplicates+1, counterValue(t, libs.MetricWebhookDuplicates))
+}
+
+func TestFindStationThrottlesUser(t *testing.T) {
+	var texts, endpoints []string
+	app := &App{
+		config:         testConfig(),
+		dedupe:         libs.NewMemoryDedupeStore(time.Hour),
+		defaultChannel: testChannel(testChannelSecret),
+		limiter:        libs.NewRateLimiter(0.001, 1, 100, 100),
+		makeRequest: func(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+			body, _ := json.Marshal(payload)
+			var pushed struct {
+				Messages []struct {
+					Text string `json:"text"`
+				} `json:"messages"`
+			}
+			json.Unmarshal(body, &pushed)
+			texts = append(texts, pushed.Messages[0].Text)
+			endpoints = append(endpoints, url)
+			return mockMakeRequest(ctx, method, url, headers, payload)
+		},
+	}
+	app.queue = newEventQueue(1, 10, app.processEvent)
+
+	throttled := counterValue(t, libs.MetricWebhookThrottled, attribute.String("scope", libs.UserRateLimit))
+	var events []libs.WebhookEvent
+	for i := 0; i < 3; i++ {
+		event := mockWebhookEvent()
+		event.WebhookEventId = fmt.Sprintf("webhook-event-%d", i)
+		events = append(events, event)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"destination": "destination",
+		"events":      events,
+	})
+	w := httptest.NewRecorder()
+	app.findStation(w, signedWebhook(testChannelSecret, body))
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{config.DefaultWelcomeText, config.DefaultSlowDownText}, texts)
+	// the user is asked to slow down with the reply token rather than a push
+	assert.Equal(t, []string{app.config.LineAPIEndpoint, app.config.LineReplyEndpoint}, endpoints)
+	assert.Equal(t, throttled+2, counterValue(t, libs.MetricWebhookThrottled, attribute.String("scope", libs.UserRateLimit)))
+}
+
+func TestFindStationQueueFull(t *testing.T) {
+	app := &App{
+		config:         testConfig(),
+		dedupe:         libs.NewMemoryDedupeStore(time.Hour),
+		defaultChannel: testChannel(testChannelSecret),
+		makeRequest:    mockMakeRequest,
+	}
+	// no workers, so the single slot fills up with the first event
+	app.queue = newEventQueue(0, 1, app.processEvent)
+
+	second := mockWebhookEvent()
+	second.WebhookEventId = "second-webhook-event-id"
+	body, _ := json.Marshal(map[string]interface{}{
+		"destination": "destination",
+		"events":      []libs.WebhookEvent{mockWebhookEvent(), second},
+	})
+	req := signedWebhook(testChannelSecret, body)
+	w := httptest.NewRecorder()
+	app.findStation(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestShutdownOnSignal(t *testing.T) {
+	var mu sync.Mutex
+	handled := 0
+	app := &App{Server: &http.Server{}, config: testConfig()}
+	app.queue = newEventQueue(1, 10, func(ctx context.Context, ch *channel, event libs.WebhookEvent) {
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		handled++
+		mu.Unlock()
+	})
+	for i := 0; i < 3; i++ {
+		assert.True(t, app.queue.enqueue(context.TODO(), testChannel(testChannelSecret), mockWebhookEvent()))
+	}
+
+	// Cloud Run stops the instances with SIGTERM
+	ctx, stop := signal.NotifyContext(context.TODO(), shutdownSignals...)
+	defer stop()
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("SIGTERM didn't stop the server")
+	}
+
+	// the events acknowledged before the signal are processed before the shutdown returns
+	assert.NoError(t, app.Shutdown(context.TODO()))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, handled)
+}
+
+func TestPushRefreshesRejectedToken(t *testing.T) {
+	t.Setenv("LINEBOT_ACCESS_TOKEN", "revoked-token")
+	tokens := libs.NewTokenManager(libs.SecretTokenSource{Secrets: libs.EnvSecretProvider{}, Name: "LINEBOT_ACCESS_TOKEN"}, 0)
+	tokens.Token(context.TODO())
+
+	// the token is rotated in the secret store while the app is running
+	t.Setenv("LINEBOT_ACCESS_TOKEN", "rotated-token")
+
+	var authorizations []string
+	ch := testChannel(testChannelSecret)
+	ch.tokens = tokens
+	app := &App{
+		config: testConfig(),
+		makeRequest: func(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+			authorizations = append(authorizations, headers["Authorization"])
+			if headers["Authorization"] != "Bearer rotated-token" {
+				return nil, &libs.HTTPError{StatusCode: http.StatusUnauthorized, Body: []byte(`{"message":"Authentication failed"}`)}
+			}
+			return mockMakeRequest(ctx, method, url, headers, payload)
+		},
+	}
+
+	_, err := app.handleEvent(context.TODO(), ch, mockWebhookEvent())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer revoked-token", "Bearer rotated-token"}, authorizations)
+
+	// a token still rejected after the refresh fails the push
+	t.Setenv("LINEBOT_ACCESS_TOKEN", "another-revoked-token")
+	tokens.Invalidate("rotated-token")
+	_, err = app.handleEvent(context.TODO(), ch, mockWebhookEvent())
+	assert.ErrorContains(t, err, "unexpected status 401")
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	app := &App{
+		config:         testConfig(),
+		logger:         libs.NewLogger(&buf, slog.LevelInfo),
+		projectId:      "project-id",
+		dedupe:         libs.NewMemoryDedupeStore(time.Hour),
+		defaultChannel: testChannel(testChannelSecret),
+		makeRequest:    mockMakeRequest,
+	}
+	app.queue = newEventQueue(1, 10, app.processEvent)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"destination": "destination",
+		"events":      []libs.WebhookEvent{mockWebhookEvent()},
+	})
+	req := signedWebhook(testChannelSecret, body)
+	req.Header.Set("X-Cloud-Trace-Context", "4bf92f3577b34da6a3ce929d0e0e4736/1;o=1")
+	w := httptest.NewRecorder()
+	app.router().ServeHTTP(w, req)
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "webhook event handled", entry["message"])
+	assert.Equal(t, "webhook-event-id", entry["webhookEventId"])
+	assert.Equal(t, libs.HashUserId("user-id"), entry["userHash"])
+	assert.Equal(t, "projects/project-id/traces/4bf92f3577b34da6a3ce929d0e0e4736", entry[libs.TraceKey])
+	assert.NotEmpty(t, entry["requestId"])
+}
+
+func TestRequestMetrics(t *testing.T) {
+	app := &App{
+		config:         testConfig(),
+		dedupe:         libs.NewMemoryDedupeStore(time.Hour),
+		defaultChannel: testChannel(testChannelSecret),
+		makeRequest:    mockMakeRequest,
+	}
+	app.queue = newEventQueue(1, 10, app.processEvent)
+
+	failed := counterValue(t, libs.MetricHTTPRequests, attribute.String("route", "/station"), attribute.Int("status", http.StatusInternalServerError))
+	req := httptest.NewRequest(http.MethodPost, "/station", bytes.NewReader([]byte("invalid")))
+	w := httptest.NewRecorder()
+	app.router().ServeHTTP(w, req)
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	assert.Equal(t, failed+1, counterValue(t, libs.MetricHTTPRequests, attribute.String("route", "/station"), attribute.Int("status", http.StatusInternalServerError)))
+}
+
+func TestRequestTracing(t *testing.T) {
+	app := &App{
+		config:         testConfig(),
+		dedupe:         libs.NewMemoryDedupeStore(time.Hour),
+		defaultChannel: testChannel(testChannelSecret),
+		makeRequest:    mockMakeRequest,
+	}
+	app.queue = newEventQueue(1, 10, app.processEvent)
+
+	event := mockWebhookEvent()
+	event.WebhookEventId = "traced-webhook-event-id"
+	body, _ := json.Marshal(map[string]interface{}{
+		"destination": "destination",
+		"events":      []libs.WebhookEvent{event},
+	})
+	req := signedWebhook(testChannelSecret, body)
+	req.Header.Set(libs.CloudTraceHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+	w := httptest.NewRecorder()
+	app.router().ServeHTTP(w, req)
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	var root, eventSpan sdktrace.ReadOnlySpan
+	for _, span := range spanRecorder.Ended() {
+		if span.SpanContext().TraceID().String() != "105445aa7843bc8bf206b12000100000" {
+			continue
+		}
+
+		switch span.Name() {
+		case "POST /station":
+			root = span
+		case "webhook.event":
+			eventSpan = span
+		}
+	}
+
+	if assert.NotNil(t, root) && assert.NotNil(t, eventSpan) {
+		assert.Equal(t, "0000000000000001", root.Parent().SpanID().String())
+		assert.Equal(t, root.SpanContext().SpanID(), eventSpan.Parent().SpanID())
+	}
+}
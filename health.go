@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"ohohestudio/sogorro/libs"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"google.golang.org/api/iterator"
+)
+
+// readinessTimeout bounds each readiness check, so a hanging dependency fails the probe instead of blocking it.
+const readinessTimeout = 3 * time.Second
+
+// The probe endpoints are served outside of the webhook, they don't require a LINE signature.
+
+// healthz reports the process is up, it doesn't check any dependency.
+func (a *App) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz runs the readiness checks and reports 503 when any of them fails.
+func (a *App) readyz(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(a.readinessChecks))
+	for name := range a.readinessChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := http.StatusOK
+	result := map[string]string{}
+	for _, name := range names {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		err := a.readinessChecks[name](ctx)
+		cancel()
+
+		if err != nil {
+			libs.LoggerFromContext(r.Context()).Warn("readiness check failed", "check", name, "error", err)
+			status = http.StatusServiceUnavailable
+			result[name] = err.Error()
+			continue
+		}
+		result[name] = "ok"
+	}
+
+	overall := "ok"
+	if status != http.StatusOK {
+		overall = "unavailable"
+	}
+	writeJSON(w, status, map[string]interface{}{"status": overall, "checks": result})
+}
+
+// version reports the build information of the binary and the region it runs in.
+func (a *App) version(w http.ResponseWriter, r *http.Request) {
+	result := map[string]string{"region": a.region}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		result["goVersion"] = info.GoVersion
+		result["version"] = info.Main.Version
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				result["revision"] = setting.Value
+			case "vcs.time":
+				result["buildTime"] = setting.Value
+			case "vcs.modified":
+				result["modified"] = setting.Value
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// pingFirestore checks Firestore is reachable by reading a single station.
+func (a *App) pingFirestore(ctx context.Context) error {
+	if a.fs == nil {
+		return fmt.Errorf("firestore client is not initialised")
+	}
+
+	iter := a.fs.Collection(libs.StationsCollection).Limit(1).Documents(ctx)
+	defer iter.Stop()
+	if _, err := iter.Next(); err != nil && err != iterator.Done {
+		return fmt.Errorf("failed to query firestore: %v", err)
+	}
+
+	return nil
+}
+
+// checkLineToken checks the cached LINE channel access token of every channel is loaded and not
+// expired. It doesn't fetch the tokens, the probes would issue a token on each call otherwise, the
+// background refresh of the channels replaces them.
+func (a *App) checkLineToken(ctx context.Context) error {
+	channels := a.allChannels()
+	if len(channels) == 0 {
+		return fmt.Errorf("LINE channel access token is not loaded")
+	}
+
+	for _, ch := range channels {
+		if err := ch.tokens.Valid(); err != nil {
+			return fmt.Errorf("channel %s: %v", ch.name, err)
+		}
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
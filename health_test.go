@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbes(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		checks         map[string]func(context.Context) error
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:           "liveness",
+			path:           "/healthz",
+			expectedStatus: http.StatusOK,
+			expectedBody:   map[string]interface{}{"status": "ok"},
+		},
+		{
+			name: "ready",
+			path: "/readyz",
+			checks: map[string]func(context.Context) error{
+				"firestore": func(ctx context.Context) error { return nil },
+				"lineToken": func(ctx context.Context) error { return nil },
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"status": "ok",
+				"checks": map[string]interface{}{"firestore": "ok", "lineToken": "ok"},
+			},
+		},
+		{
+			name: "firestore unreachable",
+			path: "/readyz",
+			checks: map[string]func(context.Context) error{
+				"firestore": func(ctx context.Context) error { return fmt.Errorf("connection refused") },
+				"lineToken": func(ctx context.Context) error { return nil },
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody: map[string]interface{}{
+				"status": "unavailable",
+				"checks": map[string]interface{}{"firestore": "connection refused", "lineToken": "ok"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{config: testConfig(), readinessChecks: tt.checks}
+
+			w := httptest.NewRecorder()
+			app.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func TestReadinessChecks(t *testing.T) {
+	app := &App{}
+	assert.ErrorContains(t, app.pingFirestore(context.TODO()), "firestore client is not initialised")
+	assert.ErrorContains(t, app.checkLineToken(context.TODO()), "access token is not loaded")
+
+	// the token isn't fetched by the check
+	app.defaultChannel = testChannel("")
+	assert.ErrorContains(t, app.checkLineToken(context.TODO()), "channel test: channel access token is not loaded")
+
+	app.defaultChannel.tokens.Token(context.TODO())
+	assert.NoError(t, app.checkLineToken(context.TODO()))
+}
+
+func TestVersion(t *testing.T) {
+	app := &App{config: testConfig(), region: "asia-east1"}
+
+	w := httptest.NewRecorder()
+	app.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "asia-east1", body["region"])
+	assert.NotEmpty(t, body["goVersion"])
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 
 	"cloud.google.com/go/firestore"
-	secretmanager "cloud.google.com/go/secretmanager/apiv1"
-	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	firebase "firebase.google.com/go"
 )
 
@@ -28,21 +26,7 @@ func GetFirebaseClient(ctx context.Context, projectId string) (*firestore.Client
 	return client, nil
 }
 
+// GetLineBotAccessToken reads the access token from Secret Manager.
 func GetLineBotAccessToken(ctx context.Context, projectId, secretName string) (string, error) {
-	client, err := secretmanager.NewClient(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to create secret manager client: %v", err)
-	}
-	defer client.Close()
-
-	req := &secretmanagerpb.AccessSecretVersionRequest{
-		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/latest", projectId, secretName),
-	}
-
-	result, err := client.AccessSecretVersion(ctx, req)
-	if err != nil {
-		return "", fmt.Errorf("failed to access secret version: %v", err)
-	}
-
-	return string(result.Payload.Data), nil
+	return NewSecretManagerProvider(projectId).Secret(ctx, secretName)
 }
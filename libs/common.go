@@ -2,11 +2,19 @@ package libs
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 計算距離
@@ -31,13 +39,34 @@ func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	return distance
 }
 
+// HTTPError is returned when the server answers with an error status, the body usually explains why.
+type HTTPError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
 func MakeRequest(method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("unable to encode object: %v", err)
+	return MakeRequestContext(context.Background(), method, url, headers, payload)
+}
+
+// MakeRequestContext is MakeRequest traced as a child span of the span carried by ctx. Error
+// statuses are returned as an *HTTPError along with the body.
+func MakeRequestContext(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+	// requests without a payload, like GET requests, are sent without a body
+	var reqBody io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode object: %v", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create request: %v", err)
 	}
@@ -46,17 +75,46 @@ func MakeRequest(method, url string, headers map[string]string, payload interfac
 		req.Header.Add(key, value)
 	}
 
+	ctx, span := Tracer.Start(ctx, fmt.Sprintf("%s %s", method, req.URL.Path), trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("server.address", req.URL.Host),
+		attribute.String("url.path", req.URL.Path),
+	)
+
 	client := &http.Client{}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
+		recordLineRequest(ctx, req, "error", start)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("unable to make request: %v", err)
 	}
 	defer resp.Body.Close()
+	recordLineRequest(ctx, req, strconv.Itoa(resp.StatusCode), start)
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to read response body: %v", err)
 	}
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		return body, &HTTPError{StatusCode: resp.StatusCode, Body: body}
+	}
+
 	return body, nil
 }
+
+func recordLineRequest(ctx context.Context, req *http.Request, status string, start time.Time) {
+	LineRequestDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("method", req.Method),
+		attribute.String("path", req.URL.Path),
+		attribute.String("status", status),
+	))
+}
@@ -68,6 +68,22 @@ func TestMakeRequest(t *testing.T) {
 			},
 			expectedError: "unable to read response body",
 		},
+		{
+			name:   "Unauthorized response",
+			method: http.MethodPost,
+			url:    "/",
+			headers: map[string]string{
+				"Authorization": "Bearer expired",
+			},
+			payload: MockPayload{
+				Message: "Hello world",
+			},
+			mockServerFunc: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"message":"Authentication failed"}`))
+			},
+			expectedError: "unexpected status 401",
+		},
 		{
 			name:          "encode payload error",
 			method:        http.MethodPost,
@@ -0,0 +1,106 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DedupeStore records which webhook events have already been processed, so a redelivered
+// event does not produce a second set of replies.
+type DedupeStore interface {
+	// Claim records the event ID and reports whether it was seen for the first time.
+	Claim(ctx context.Context, eventId string) (bool, error)
+	// Release forgets the event ID, so a later redelivery can be processed again.
+	Release(ctx context.Context, eventId string) error
+}
+
+// FirestoreDedupeStore keeps processed event IDs as documents in a Firestore collection.
+// Each document carries an expireAt field, configure a TTL policy on it to purge old records.
+type FirestoreDedupeStore struct {
+	client     *firestore.Client
+	collection string
+	ttl        time.Duration
+}
+
+func NewFirestoreDedupeStore(client *firestore.Client, collection string, ttl time.Duration) *FirestoreDedupeStore {
+	return &FirestoreDedupeStore{
+		client:     client,
+		collection: collection,
+		ttl:        ttl,
+	}
+}
+
+func (s *FirestoreDedupeStore) Claim(ctx context.Context, eventId string) (bool, error) {
+	now := time.Now()
+	_, err := s.client.Collection(s.collection).Doc(eventId).Create(ctx, map[string]interface{}{
+		"processedAt": now,
+		"expireAt":    now.Add(s.ttl),
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook event: %v", err)
+	}
+
+	return true, nil
+}
+
+func (s *FirestoreDedupeStore) Release(ctx context.Context, eventId string) error {
+	_, err := s.client.Collection(s.collection).Doc(eventId).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to release webhook event: %v", err)
+	}
+
+	return nil
+}
+
+// MemoryDedupeStore keeps processed event IDs in memory. It is meant for tests and local runs.
+type MemoryDedupeStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	events map[string]time.Time
+	now    func() time.Time
+}
+
+func NewMemoryDedupeStore(ttl time.Duration) *MemoryDedupeStore {
+	return &MemoryDedupeStore{
+		ttl:    ttl,
+		events: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+func (s *MemoryDedupeStore) Claim(ctx context.Context, eventId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, expireAt := range s.events {
+		if now.After(expireAt) {
+			delete(s.events, id)
+		}
+	}
+
+	if _, ok := s.events[eventId]; ok {
+		return false, nil
+	}
+	s.events[eventId] = now.Add(s.ttl)
+
+	return true, nil
+}
+
+func (s *MemoryDedupeStore) Release(ctx context.Context, eventId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.events, eventId)
+	return nil
+}
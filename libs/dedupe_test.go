@@ -0,0 +1,36 @@
+package libs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDedupeStore(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Now()
+	store := NewMemoryDedupeStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	first, err := store.Claim(ctx, "event-id")
+	assert.NoError(t, err)
+	assert.True(t, first)
+
+	first, err = store.Claim(ctx, "event-id")
+	assert.NoError(t, err)
+	assert.False(t, first)
+
+	// released events can be claimed again
+	assert.NoError(t, store.Release(ctx, "event-id"))
+	first, err = store.Claim(ctx, "event-id")
+	assert.NoError(t, err)
+	assert.True(t, first)
+
+	// expired events are forgotten
+	now = now.Add(2 * time.Minute)
+	first, err = store.Claim(ctx, "event-id")
+	assert.NoError(t, err)
+	assert.True(t, first)
+}
@@ -0,0 +1,183 @@
+package libs
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// StationStyle is how the stations of a vmType are drawn on a map.
+type StationStyle struct {
+	Id    string
+	Name  string
+	Color string
+}
+
+// stationStyles maps the vmType of the stations to their style, the unknown types are drawn grey.
+var stationStyles = map[int64]StationStyle{
+	1: {Id: "gostation", Name: "GoStation®", Color: "#00a0e9"},
+	3: {Id: "super-gostation", Name: "Super GoStation®", Color: "#e4007f"},
+}
+
+// StyleOf returns the style of the stations of the vmType.
+func StyleOf(vmType int64) StationStyle {
+	if style, ok := stationStyles[vmType]; ok {
+		return style
+	}
+
+	return StationStyle{Id: fmt.Sprintf("vmtype-%d", vmType), Name: fmt.Sprintf("vmType %d", vmType), Color: "#888888"}
+}
+
+// stationProperties are the fields of a station exported along its location.
+func stationProperties(station GoStation) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       station.Id,
+		"location": station.Location,
+		"address":  station.Address,
+		"city":     station.City,
+		"district": station.District,
+		"vmType":   station.VMType,
+		"state":    station.State,
+	}
+}
+
+type geoJSONGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Id         string                 `json:"id"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// WriteGeoJSON writes the stations as a GeoJSON FeatureCollection of points. The style of the
+// vmType is set in the style and marker-color properties, the latter read by most GIS tools.
+func WriteGeoJSON(w io.Writer, stations []GoStation) error {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for _, station := range stations {
+		style := StyleOf(station.VMType)
+		properties := stationProperties(station)
+		properties["style"] = style.Id
+		properties["marker-color"] = style.Color
+
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:       "Feature",
+			Id:         station.Id,
+			Geometry:   geoJSONGeometry{Type: "Point", Coordinates: [2]float64{station.Longitude, station.Latitude}},
+			Properties: properties,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(collection); err != nil {
+		return fmt.Errorf("failed to write GeoJSON: %v", err)
+	}
+
+	return nil
+}
+
+type kmlIcon struct {
+	Href string `xml:"href"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"`
+	Icon  kmlIcon `xml:"Icon"`
+}
+
+type kmlStyle struct {
+	Id        string       `xml:"id,attr"`
+	IconStyle kmlIconStyle `xml:"IconStyle"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPlacemark struct {
+	Id           string    `xml:"id,attr"`
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description"`
+	StyleUrl     string    `xml:"styleUrl"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Point        kmlPoint  `xml:"Point"`
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Styles     []kmlStyle     `xml:"Document>Style"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+// kmlIconHref is the pushpin icon of Google Earth, tinted with the color of the style.
+const kmlIconHref = "https://maps.google.com/mapfiles/kml/paddle/wht-blank.png"
+
+// WriteKML writes the stations as the placemarks of a KML document named name, each one using the
+// shared style of its vmType.
+func WriteKML(w io.Writer, name string, stations []GoStation) error {
+	document := kmlDocument{Xmlns: "http://www.opengis.net/kml/2.2", Name: name}
+
+	styled := map[string]bool{}
+	for _, station := range stations {
+		style := StyleOf(station.VMType)
+		if !styled[style.Id] {
+			styled[style.Id] = true
+			document.Styles = append(document.Styles, kmlStyle{
+				Id:        style.Id,
+				IconStyle: kmlIconStyle{Color: kmlColor(style.Color), Icon: kmlIcon{Href: kmlIconHref}},
+			})
+		}
+
+		placemark := kmlPlacemark{
+			Id:          station.Id,
+			Name:        station.Location,
+			Description: station.Address,
+			StyleUrl:    "#" + style.Id,
+			Point:       kmlPoint{Coordinates: fmt.Sprintf("%s,%s", strconv.FormatFloat(station.Longitude, 'f', -1, 64), strconv.FormatFloat(station.Latitude, 'f', -1, 64))},
+		}
+		properties := stationProperties(station)
+		for _, field := range []string{"id", "location", "address", "city", "district", "vmType", "state"} {
+			placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: field, Value: fmt.Sprint(properties[field])})
+		}
+		document.Placemarks = append(document.Placemarks, placemark)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write KML: %v", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("failed to write KML: %v", err)
+	}
+
+	return nil
+}
+
+// kmlColor converts a #rrggbb color to the opaque aabbggrr of KML.
+func kmlColor(color string) string {
+	if len(color) != 7 {
+		return "ff888888"
+	}
+
+	return "ff" + color[5:7] + color[3:5] + color[1:3]
+}
@@ -0,0 +1,84 @@
+package libs
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var exportStations = []GoStation{
+	{Id: "taipei-101", Location: "台北101站", Address: "信義路五段7號", City: "臺北市", District: "信義區", Latitude: 25.033964, Longitude: 121.564468, VMType: 1, State: ActiveState},
+	{Id: "city-hall", Location: "市政府站", Address: "市府路1號", City: "臺北市", District: "信義區", Latitude: 25.0375, Longitude: 121.5637, VMType: 3, State: 2},
+}
+
+func TestStyleOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		vmType     int64
+		expectedId string
+	}{
+		{name: "GoStation", vmType: 1, expectedId: "gostation"},
+		{name: "Super GoStation", vmType: 3, expectedId: "super-gostation"},
+		{name: "unknown type", vmType: 7, expectedId: "vmtype-7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedId, StyleOf(tt.vmType).Id)
+		})
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, WriteGeoJSON(&out, exportStations))
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Type     string `json:"type"`
+			Id       string `json:"id"`
+			Geometry struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &collection))
+	assert.Equal(t, "FeatureCollection", collection.Type)
+	assert.Len(t, collection.Features, 2)
+
+	feature := collection.Features[1]
+	assert.Equal(t, "city-hall", feature.Id)
+	assert.Equal(t, "Point", feature.Geometry.Type)
+	assert.Equal(t, []float64{121.5637, 25.0375}, feature.Geometry.Coordinates)
+	assert.Equal(t, "市政府站", feature.Properties["location"])
+	assert.Equal(t, float64(2), feature.Properties["state"])
+	assert.Equal(t, "super-gostation", feature.Properties["style"])
+	assert.Equal(t, "#e4007f", feature.Properties["marker-color"])
+
+	out.Reset()
+	assert.NoError(t, WriteGeoJSON(&out, nil))
+	assert.Contains(t, out.String(), `"features": []`)
+}
+
+func TestWriteKML(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, WriteKML(&out, "sogorro stations", exportStations))
+
+	var document kmlDocument
+	assert.NoError(t, xml.Unmarshal(out.Bytes(), &document))
+	assert.Equal(t, "sogorro stations", document.Name)
+	assert.Len(t, document.Styles, 2)
+	assert.Equal(t, "ff7f00e4", document.Styles[1].IconStyle.Color)
+
+	placemark := document.Placemarks[0]
+	assert.Equal(t, "台北101站", placemark.Name)
+	assert.Equal(t, "#gostation", placemark.StyleUrl)
+	assert.Equal(t, "121.564468,25.033964", placemark.Point.Coordinates)
+	assert.Contains(t, placemark.ExtendedData, kmlData{Name: "city", Value: "臺北市"})
+}
@@ -1,8 +1,15 @@
 package libs
 
-import "fmt"
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+)
 
 type GoStation struct {
+	Id        string  `json:"id"`
 	Address   string  `json:"address"`
 	City      string  `json:"city"`
 	Distance  float64 `json:"distance"`
@@ -11,6 +18,11 @@ type GoStation struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	VMType    int64   `json:"vmType"`
+	State     int64   `json:"state"`
+	// TravelDistance in kilometers and TravelMinutes are estimated along the roads by the routing
+	// provider, 0 when the search has none.
+	TravelDistance float64 `json:"travelDistance,omitempty"`
+	TravelMinutes  int     `json:"travelMinutes,omitempty"`
 }
 
 // Line Webhook
@@ -31,14 +43,51 @@ type WebhookEvent struct {
 	WebhookEventId  string         `json:"webhookEventId"`
 	DeliveryContext struct {
 		IsRedelivery bool `json:"isRedelivery"`
-	}
+	} `json:"deliveryContext"`
 	Timestamp int64 `json:"timestamp"`
 	Source    struct {
 		Type   string `json:"type"`
 		UserId string `json:"userId"`
-	}
+	} `json:"source"`
 	ReplyToken string `json:"replyToken"`
-	Mode       string `json:"active"`
+	Mode       string `json:"mode"`
+	Postback   struct {
+		Data string `json:"data"`
+		// Params is the value picked by a datetimepicker action.
+		Params struct {
+			Time string `json:"time"`
+		} `json:"params"`
+	} `json:"postback"`
+}
+
+// ValidateSignature reports whether signature, the X-Line-Signature header of a webhook request,
+// matches the HMAC-SHA256 digest of body signed with the channel secret.
+func ValidateSignature(channelSecret, signature string, body []byte) bool {
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(channelSecret))
+	mac.Write(body)
+	return hmac.Equal(decoded, mac.Sum(nil))
+}
+
+// MaxMulticastRecipients is the number of users a single multicast request can be sent to.
+const MaxMulticastRecipients = 500
+
+// ChunkRecipients splits the user IDs into the batches of a multicast.
+func ChunkRecipients(userIds []string) [][]string {
+	var chunks [][]string
+	for len(userIds) > MaxMulticastRecipients {
+		chunks = append(chunks, userIds[:MaxMulticastRecipients])
+		userIds = userIds[MaxMulticastRecipients:]
+	}
+	if len(userIds) > 0 {
+		chunks = append(chunks, userIds)
+	}
+
+	return chunks
 }
 
 // Flex message template
@@ -71,6 +120,59 @@ const (
 	SeparatorElement ElementType = "separator"
 )
 
+// Postback data sent by the rich menu
+const (
+	FavouritesPostback = "action=favourites"
+	SettingsPostback   = "action=settings"
+)
+
+// NavigationAction is the postback action choosing the navigation app, the app name is sent along.
+const NavigationAction = "navigation"
+
+// NavigationPostback builds the postback data choosing the navigation app of the name.
+func NavigationPostback(name string) string {
+	return url.Values{"action": {NavigationAction}, "app": {name}}.Encode()
+}
+
+// ReminderAction is the postback action saving a reminder of the stations around the location
+// sent along. The start of the window is picked by the user first, then its end.
+const ReminderAction = "reminder"
+
+// ReminderPostback builds the postback data of a reminder at the location, start and end are
+// left out until they are picked.
+func ReminderPostback(location MapPoint, start, end string) string {
+	values := url.Values{"action": {ReminderAction}, "at": {location.String()}}
+	if start != "" {
+		values.Set("start", start)
+	}
+	if end != "" {
+		values.Set("end", end)
+	}
+
+	return values.Encode()
+}
+
+// Postback actions of the station subscription buttons, the station ID is sent along.
+const (
+	SubscribeAction   = "subscribe"
+	UnsubscribeAction = "unsubscribe"
+)
+
+// StationPostback builds the postback data of a station button.
+func StationPostback(action, stationId string) string {
+	return url.Values{"action": {action}, "station": {stationId}}.Encode()
+}
+
+// ParsePostback returns the action and the station ID of postback data.
+func ParsePostback(data string) (action, stationId string) {
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return "", ""
+	}
+
+	return values.Get("action"), values.Get("station")
+}
+
 type BoxTemplate struct {
 	Type     ElementType   `json:"type"`
 	Layout   LayoutType    `json:"layout"`
@@ -91,10 +193,15 @@ type TextTemplate struct {
 }
 
 type ActionTemplate struct {
-	Type  ActionType `json:"type"`
-	Label string     `json:"label"`
-	URI   string     `json:"uri,omitempty"`
-	Text  string     `json:"text,omitempty"`
+	Type        ActionType `json:"type"`
+	Label       string     `json:"label"`
+	URI         string     `json:"uri,omitempty"`
+	Text        string     `json:"text,omitempty"`
+	Data        string     `json:"data,omitempty"`
+	DisplayText string     `json:"displayText,omitempty"`
+	// Mode and Initial of a datetimepicker action, like time and 08:00.
+	Mode    string `json:"mode,omitempty"`
+	Initial string `json:"initial,omitempty"`
 }
 
 type ButtonTemplate struct {
@@ -112,29 +219,74 @@ type ContentTemplate struct {
 	Spacing  string        `json:"spacing,omitempty"`
 }
 
+type ImageTemplate struct {
+	Type        ElementType `json:"type"`
+	Url         string      `json:"url"`
+	Size        string      `json:"size,omitempty"`
+	AspectRatio string      `json:"aspectRatio,omitempty"`
+	AspectMode  string      `json:"aspectMode,omitempty"`
+}
+
 type BubbleTemplate struct {
 	Type   string          `json:"type"`
+	Hero   *ImageTemplate  `json:"hero,omitempty"`
 	Body   ContentTemplate `json:"body"`
 	Footer ContentTemplate `json:"footer"`
 }
 
+// HeroImage is the full width image on top of a bubble, like the map of a station.
+func HeroImage(url string) *ImageTemplate {
+	return &ImageTemplate{
+		Type:        ImageElement,
+		Url:         url,
+		Size:        "full",
+		AspectRatio: fmt.Sprintf("%d:%d", MapWidth/40, MapHeight/40),
+		AspectMode:  "cover",
+	}
+}
+
 type QuickReplyItemTemplate struct {
 	Type     string         `json:"type"`
-	ImageUrl string         `json:"imageUrl"`
+	ImageUrl string         `json:"imageUrl,omitempty"`
 	Action   ActionTemplate `json:"action"`
 }
 
 type BubbleMessageTemplate struct {
-	Type     string         `json:"type"`
-	AltText  string         `json:"altText"`
-	Contents BubbleTemplate `json:"contents"`
+	Type       string              `json:"type"`
+	AltText    string              `json:"altText"`
+	Contents   BubbleTemplate      `json:"contents"`
+	QuickReply *QuickReplyTemplate `json:"quickReply,omitempty"`
+}
+
+type CarouselTemplate struct {
+	Type     string           `json:"type"`
+	Contents []BubbleTemplate `json:"contents"`
+}
+
+type CarouselMessageTemplate struct {
+	Type     string           `json:"type"`
+	AltText  string           `json:"altText"`
+	Contents CarouselTemplate `json:"contents"`
 }
 
 type QuickReplyTemplate struct {
 	Items []QuickReplyItemTemplate `json:"items"`
 }
 
-func BubbleMessage(station GoStation) BubbleMessageTemplate {
+// BubbleOptions personalize the bubble of a station for the user.
+type BubbleOptions struct {
+	// Navigation opens the directions of the 立即前往 button, Google Maps when nil.
+	Navigation NavigationProvider
+	// Origin is the location of the user, the directions start from the current location when nil.
+	Origin *MapPoint
+}
+
+func BubbleMessage(station GoStation, options BubbleOptions) BubbleMessageTemplate {
+	navigation := options.Navigation
+	if navigation == nil {
+		navigation = NavigationProviders[0]
+	}
+
 	message := BubbleMessageTemplate{
 		Type:    "flex",
 		AltText: "sogorro",
@@ -182,7 +334,13 @@ func BubbleMessage(station GoStation) BubbleMessageTemplate {
 		},
 	})
 
-	message.Contents.Body.Contents = append(message.Contents.Body.Contents, BoxTemplate{
+	// the distance along the roads when the travel is estimated, the straight line otherwise
+	distance := station.Distance
+	if station.TravelDistance > 0 {
+		distance = station.TravelDistance
+	}
+
+	details := BoxTemplate{
 		Type:    BoxElement,
 		Layout:  VerticalLayout,
 		Margin:  "lg",
@@ -224,7 +382,7 @@ func BubbleMessage(station GoStation) BubbleMessageTemplate {
 					},
 					TextTemplate{
 						Type:  TextElement,
-						Text:  fmt.Sprintf("%.2f 公里", station.Distance),
+						Text:  fmt.Sprintf("%.2f 公里", distance),
 						Color: "#666666",
 						Size:  "sm",
 						Flex:  5,
@@ -232,7 +390,31 @@ func BubbleMessage(station GoStation) BubbleMessageTemplate {
 				},
 			},
 		},
-	})
+	}
+	if station.TravelMinutes > 0 {
+		details.Contents = append(details.Contents, BoxTemplate{
+			Type:    BoxElement,
+			Layout:  BaselineLayout,
+			Spacing: "sm",
+			Contents: []interface{}{
+				TextTemplate{
+					Type:  TextElement,
+					Text:  "時間",
+					Color: "#aaaaaa",
+					Size:  "sm",
+					Flex:  1,
+				},
+				TextTemplate{
+					Type:  TextElement,
+					Text:  fmt.Sprintf("約 %d 分鐘", station.TravelMinutes),
+					Color: "#666666",
+					Size:  "sm",
+					Flex:  5,
+				},
+			},
+		})
+	}
+	message.Contents.Body.Contents = append(message.Contents.Body.Contents, details)
 
 	message.Contents.Footer.Contents = append(message.Contents.Footer.Contents, ButtonTemplate{
 		Type:   ButtonElement,
@@ -241,10 +423,46 @@ func BubbleMessage(station GoStation) BubbleMessageTemplate {
 		Action: ActionTemplate{
 			Type:  URIAction,
 			Label: "立即前往",
-			URI:   fmt.Sprintf("https://www.google.com.tw/maps/dir//%f,%f", station.Latitude, station.Longitude),
+			URI:   navigation.DirectionsUri(options.Origin, MapPoint{Latitude: station.Latitude, Longitude: station.Longitude}),
 		},
 	})
 
+	message.Contents.Footer.Contents = append(message.Contents.Footer.Contents, ButtonTemplate{
+		Type:   ButtonElement,
+		Style:  SecondaryButton,
+		Height: "sm",
+		Action: ActionTemplate{
+			Type:        PostbackAction,
+			Label:       "訂閱狀態通知",
+			Data:        StationPostback(SubscribeAction, station.Id),
+			DisplayText: "訂閱狀態通知",
+		},
+	})
+
+	return message
+}
+
+// MaxCarouselBubbles is the number of bubbles LINE accepts in a carousel.
+const MaxCarouselBubbles = 12
+
+// CarouselMessage sends the bubbles of the stations in a single message, the stations past
+// MaxCarouselBubbles are left out.
+func CarouselMessage(stations []GoStation, options BubbleOptions) CarouselMessageTemplate {
+	message := CarouselMessageTemplate{
+		Type:    "flex",
+		AltText: "sogorro",
+		Contents: CarouselTemplate{
+			Type: "carousel",
+		},
+	}
+
+	for i, station := range stations {
+		if i == MaxCarouselBubbles {
+			break
+		}
+		message.Contents.Contents = append(message.Contents.Contents, BubbleMessage(station, options).Contents)
+	}
+
 	return message
 }
 
@@ -1,6 +1,9 @@
 package libs
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"testing"
 
@@ -9,12 +12,15 @@ import (
 
 func TestBubbleMessage(t *testing.T) {
 	tests := []struct {
-		name    string
-		station GoStation
+		name        string
+		station     GoStation
+		options     BubbleOptions
+		expectedURI string
 	}{
 		{
 			name: "GoStation message",
 			station: GoStation{
+				Id:        "station-ermita",
 				Location:  "Station Ermita",
 				Address:   "Calle 3 Pantitlan",
 				VMType:    1,
@@ -22,10 +28,12 @@ func TestBubbleMessage(t *testing.T) {
 				Latitude:  19.427050,
 				Longitude: -99.127571,
 			},
+			expectedURI: "https://www.google.com/maps/dir/?api=1&destination=19.427050%2C-99.127571&travelmode=two-wheeler",
 		},
 		{
 			name: "SuperGoStation message",
 			station: GoStation{
+				Id:        "station-pantitlan",
 				Location:  "Station Pantitlan",
 				Address:   "Rojo Gomes",
 				VMType:    3,
@@ -33,12 +41,14 @@ func TestBubbleMessage(t *testing.T) {
 				Latitude:  20.673590,
 				Longitude: -103.343803,
 			},
+			options:     BubbleOptions{Navigation: AppleMaps{}, Origin: &MapPoint{Latitude: 20.67, Longitude: -103.34}},
+			expectedURI: "https://maps.apple.com/?daddr=20.673590%2C-103.343803&dirflg=d&saddr=20.670000%2C-103.340000",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := BubbleMessage(tt.station)
+			result := BubbleMessage(tt.station, tt.options)
 			fmt.Println((result))
 
 			assert.Equal(t, "flex", result.Type)
@@ -51,7 +61,123 @@ func TestBubbleMessage(t *testing.T) {
 			}
 			assert.Equal(t, tt.station.Address, result.Contents.Body.Contents[2].(BoxTemplate).Contents[0].(BoxTemplate).Contents[1].(TextTemplate).Text)
 			assert.Equal(t, fmt.Sprintf("%.2f 公里", tt.station.Distance), result.Contents.Body.Contents[2].(BoxTemplate).Contents[1].(BoxTemplate).Contents[1].(TextTemplate).Text)
-			assert.Equal(t, fmt.Sprintf("https://www.google.com.tw/maps/dir//%f,%f", tt.station.Latitude, tt.station.Longitude), result.Contents.Footer.Contents[0].(ButtonTemplate).Action.URI)
+			assert.Equal(t, tt.expectedURI, result.Contents.Footer.Contents[0].(ButtonTemplate).Action.URI)
+
+			subscribe := result.Contents.Footer.Contents[1].(ButtonTemplate).Action
+			assert.Equal(t, PostbackAction, subscribe.Type)
+			action, stationId := ParsePostback(subscribe.Data)
+			assert.Equal(t, SubscribeAction, action)
+			assert.Equal(t, tt.station.Id, stationId)
+		})
+	}
+}
+
+func TestBubbleMessageTravel(t *testing.T) {
+	details := func(station GoStation) []interface{} {
+		return BubbleMessage(station, BubbleOptions{}).Contents.Body.Contents[2].(BoxTemplate).Contents
+	}
+	text := func(row interface{}) string {
+		return row.(BoxTemplate).Contents[1].(TextTemplate).Text
+	}
+
+	station := GoStation{Id: "station-1", Location: "台北101站", Distance: 0.91}
+	assert.Len(t, details(station), 2)
+	assert.Equal(t, "0.91 公里", text(details(station)[1]))
+
+	// the estimated travel replaces the straight line
+	station.TravelDistance = 1.42
+	station.TravelMinutes = 4
+	assert.Len(t, details(station), 3)
+	assert.Equal(t, "1.42 公里", text(details(station)[1]))
+	assert.Equal(t, "約 4 分鐘", text(details(station)[2]))
+}
+
+func TestCarouselMessage(t *testing.T) {
+	tests := []struct {
+		name            string
+		stations        int
+		expectedBubbles int
+	}{
+		{name: "one station", stations: 1, expectedBubbles: 1},
+		{name: "too many stations", stations: MaxCarouselBubbles + 3, expectedBubbles: MaxCarouselBubbles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stations := make([]GoStation, tt.stations)
+			for i := range stations {
+				stations[i] = GoStation{Id: fmt.Sprintf("station-%d", i), Location: fmt.Sprintf("Station %d", i)}
+			}
+
+			result := CarouselMessage(stations, BubbleOptions{})
+
+			assert.Equal(t, "flex", result.Type)
+			assert.Equal(t, "carousel", result.Contents.Type)
+			assert.Len(t, result.Contents.Contents, tt.expectedBubbles)
+			assert.Equal(t, "bubble", result.Contents.Contents[0].Type)
+			assert.Equal(t, "Station 0", result.Contents.Contents[0].Body.Contents[0].(TextTemplate).Text)
 		})
 	}
 }
+
+func TestParsePostback(t *testing.T) {
+	tests := []struct {
+		name              string
+		data              string
+		expectedAction    string
+		expectedStationId string
+	}{
+		{name: "station button", data: StationPostback(UnsubscribeAction, "station/1"), expectedAction: UnsubscribeAction, expectedStationId: "station/1"},
+		{name: "rich menu", data: FavouritesPostback, expectedAction: "favourites"},
+		{name: "malformed", data: "%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, stationId := ParsePostback(tt.data)
+			assert.Equal(t, tt.expectedAction, action)
+			assert.Equal(t, tt.expectedStationId, stationId)
+		})
+	}
+}
+
+func TestValidateSignature(t *testing.T) {
+	body := []byte(`{"destination":"destination","events":[]}`)
+	mac := hmac.New(sha256.New, []byte("channel-secret"))
+	mac.Write(body)
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		expected  bool
+	}{
+		{name: "valid signature", secret: "channel-secret", signature: signature, expected: true},
+		{name: "wrong secret", secret: "other-secret", signature: signature, expected: false},
+		{name: "malformed signature", secret: "channel-secret", signature: "not base64!", expected: false},
+		{name: "missing signature", secret: "channel-secret", signature: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ValidateSignature(tt.secret, tt.signature, body))
+		})
+	}
+}
+
+func TestChunkRecipients(t *testing.T) {
+	userIds := make([]string, 1201)
+	for i := range userIds {
+		userIds[i] = fmt.Sprintf("user-%d", i)
+	}
+
+	chunks := ChunkRecipients(userIds)
+	assert.Len(t, chunks, 3)
+	assert.Len(t, chunks[0], MaxMulticastRecipients)
+	assert.Len(t, chunks[1], MaxMulticastRecipients)
+	assert.Equal(t, []string{"user-1000"}, chunks[2][:1])
+	assert.Len(t, chunks[2], 201)
+
+	assert.Empty(t, ChunkRecipients(nil))
+}
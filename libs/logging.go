@@ -0,0 +1,109 @@
+package libs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Special fields of structured logs recognised by Cloud Logging.
+const (
+	TraceKey  = "logging.googleapis.com/trace"
+	SpanIdKey = "logging.googleapis.com/spanId"
+)
+
+type loggerKey struct{}
+
+// NewLogger returns a logger writing JSON lines in the format Cloud Logging parses: severity,
+// message and time are mapped to the fields of a LogEntry.
+func NewLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+
+			switch a.Key {
+			case slog.LevelKey:
+				a.Key = "severity"
+				a.Value = slog.StringValue(severity(a.Value.Any().(slog.Level)))
+			case slog.MessageKey:
+				a.Key = "message"
+			}
+
+			return a
+		},
+	}))
+}
+
+// ParseLogLevel parses debug, info, warn or error, an empty level means info.
+func ParseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("invalid log level %q: %v", level, err)
+	}
+
+	return l, nil
+}
+
+func severity(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "ERROR"
+	case level >= slog.LevelWarn:
+		return "WARNING"
+	case level >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// TraceAttrs returns the trace and span fields for the X-Cloud-Trace-Context header, which is
+// formatted as TRACE_ID/SPAN_ID;o=OPTIONS. It returns nil when the header is missing or invalid.
+func TraceAttrs(projectId, header string) []any {
+	traceId, rest, _ := strings.Cut(header, "/")
+	if traceId == "" || projectId == "" {
+		return nil
+	}
+
+	attrs := []any{slog.String(TraceKey, fmt.Sprintf("projects/%s/traces/%s", projectId, traceId))}
+	// the header carries a decimal span ID, Cloud Logging expects 16 hex characters
+	spanId, _, _ := strings.Cut(rest, ";")
+	if id, err := strconv.ParseUint(spanId, 10, 64); err == nil {
+		attrs = append(attrs, slog.String(SpanIdKey, fmt.Sprintf("%016x", id)))
+	}
+
+	return attrs
+}
+
+// HashUserId returns a stable pseudonym of the LINE user ID, so logs can be correlated per user
+// without recording the ID itself.
+func HashUserId(userId string) string {
+	sum := sha256.Sum256([]byte(userId))
+	return hex.EncodeToString(sum[:8])
+}
+
+// WithLogger returns a copy of ctx carrying the logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or the default logger.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
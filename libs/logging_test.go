@@ -0,0 +1,69 @@
+package libs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, slog.LevelInfo)
+
+	logger.Debug("hidden")
+	logger.With(TraceAttrs("project-id", "105445aa7843bc8bf206b12000100000/1;o=1")...).Warn("slow down", "userHash", HashUserId("user-id"))
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "WARNING", entry["severity"])
+	assert.Equal(t, "slow down", entry["message"])
+	assert.Equal(t, "projects/project-id/traces/105445aa7843bc8bf206b12000100000", entry[TraceKey])
+	assert.Equal(t, "0000000000000001", entry[SpanIdKey])
+	assert.Equal(t, HashUserId("user-id"), entry["userHash"])
+	assert.NotContains(t, buf.String(), "user-id")
+	assert.NotContains(t, buf.String(), "hidden")
+}
+
+func TestTraceAttrs(t *testing.T) {
+	tests := []struct {
+		name      string
+		projectId string
+		header    string
+		expected  int
+	}{
+		{name: "trace and span", projectId: "project-id", header: "trace-id/123;o=1", expected: 2},
+		{name: "trace only", projectId: "project-id", header: "trace-id", expected: 1},
+		{name: "missing header", projectId: "project-id", header: "", expected: 0},
+		{name: "missing project", projectId: "", header: "trace-id/123;o=1", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, TraceAttrs(tt.projectId, tt.header), tt.expected)
+		})
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, err := ParseLogLevel("")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+
+	level, err = ParseLogLevel("debug")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	_, err = ParseLogLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	assert.Equal(t, slog.Default(), LoggerFromContext(context.TODO()))
+
+	logger := NewLogger(&bytes.Buffer{}, slog.LevelInfo)
+	assert.Equal(t, logger, LoggerFromContext(WithLogger(context.TODO(), logger)))
+}
@@ -0,0 +1,102 @@
+package libs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tileSize is the width and height of the raster tiles in pixels.
+const tileSize = 256
+
+// maxCachedTiles bounds the tiles kept in memory, a few tens of KB each.
+const maxCachedTiles = 256
+
+// MapTiles fetches the raster tiles RenderMap draws the streets with from a tile server, like
+// https://tile.openstreetmap.org/{z}/{x}/{y}.png. The fetched tiles are kept in memory, the same
+// stations are drawn again and again.
+type MapTiles struct {
+	UrlTemplate string
+	Client      *http.Client
+
+	mu    sync.Mutex
+	tiles map[string][]byte
+}
+
+// NewMapTiles returns the tiles of the server of the URL template, the {z}, {x} and {y}
+// placeholders are replaced by the zoom and the coordinates of each tile.
+func NewMapTiles(urlTemplate string) *MapTiles {
+	return &MapTiles{
+		UrlTemplate: urlTemplate,
+		Client:      &http.Client{Timeout: 3 * time.Second},
+		tiles:       map[string][]byte{},
+	}
+}
+
+// Tile returns the decoded tile of the zoom and coordinates.
+func (t *MapTiles) Tile(ctx context.Context, zoom, x, y int) (image.Image, error) {
+	tileUrl := strings.NewReplacer("{z}", strconv.Itoa(zoom), "{x}", strconv.Itoa(x), "{y}", strconv.Itoa(y)).Replace(t.UrlTemplate)
+
+	t.mu.Lock()
+	data, ok := t.tiles[tileUrl]
+	t.mu.Unlock()
+
+	if !ok {
+		var err error
+		if data, err = t.fetch(ctx, tileUrl); err != nil {
+			return nil, err
+		}
+
+		t.mu.Lock()
+		if len(t.tiles) >= maxCachedTiles {
+			// any tile makes room, the maps drawn often fetch theirs again soon
+			for key := range t.tiles {
+				delete(t.tiles, key)
+				break
+			}
+		}
+		t.tiles[tileUrl] = data
+		t.mu.Unlock()
+	}
+
+	tile, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tile %d/%d/%d: %v", zoom, x, y, err)
+	}
+
+	return tile, nil
+}
+
+func (t *MapTiles) fetch(ctx context.Context, tileUrl string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tileUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tile request: %v", err)
+	}
+	// tile servers like the OpenStreetMap ones refuse the requests of unidentified clients
+	req.Header.Set("User-Agent", "sogorro")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tile: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch tile %s: status %d", tileUrl, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tile: %v", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,108 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Metrics of the service. Prometheus names replace the dots with underscores and append the unit,
+// e.g. sogorro.station.query.duration is exposed as sogorro_station_query_duration_seconds.
+const (
+	// MetricHTTPRequests counts handled HTTP requests by route, method and status code.
+	MetricHTTPRequests = "sogorro.http.requests"
+	// MetricHTTPRequestDuration is the latency of HTTP requests by route, method and status code.
+	MetricHTTPRequestDuration = "sogorro.http.request.duration"
+	// MetricWebhookEvents counts received webhook events by event type and whether they were redelivered.
+	MetricWebhookEvents = "sogorro.webhook.events"
+	// MetricWebhookDuplicates counts webhook events skipped because they were already processed.
+	MetricWebhookDuplicates = "sogorro.webhook.duplicates"
+	// MetricWebhookThrottled counts webhook events dropped by the rate limiter by scope: user or global.
+	MetricWebhookThrottled = "sogorro.webhook.throttled"
+	// MetricStationSearches counts location searches by result: found, not_found or error, and by
+	// source: bot, reminder or api.
+	MetricStationSearches = "sogorro.station.searches"
+	// MetricStationNotifications counts users notified of a station change by state: offline or online.
+	MetricStationNotifications = "sogorro.station.notifications"
+	// MetricReminders counts the due reminders by result: sent, skipped or error.
+	MetricReminders = "sogorro.reminders"
+	// MetricRoutings counts the travel estimations of the searches by provider: primary or fallback.
+	MetricRoutings = "sogorro.routings"
+	// MetricStationQueryDuration is the latency of the Firestore stations query.
+	MetricStationQueryDuration = "sogorro.station.query.duration"
+	// MetricLineRequestDuration is the latency of LINE API calls by path and status code.
+	MetricLineRequestDuration = "sogorro.line.request.duration"
+	// MetricLineQuotaLimit is the monthly push message quota by channel, unset without a quota.
+	MetricLineQuotaLimit = "sogorro.line.quota.limit"
+	// MetricLineQuotaUsage is the number of push messages sent this month by channel.
+	MetricLineQuotaUsage = "sogorro.line.quota.usage"
+	// MetricLineQuotaOverBudget is 1 while a channel is over its push budget and only replies.
+	MetricLineQuotaOverBudget = "sogorro.line.quota.over_budget"
+	// MetricLinePushesBlocked counts pushes skipped because the channel is over its budget, by channel.
+	MetricLinePushesBlocked = "sogorro.line.pushes.blocked"
+)
+
+// meter creates the instruments from the global MeterProvider, they start recording once
+// SetupMetrics installs it.
+var meter = otel.Meter("ohohestudio/sogorro")
+
+var (
+	HTTPRequests, _         = meter.Int64Counter(MetricHTTPRequests, metric.WithDescription("Handled HTTP requests."))
+	HTTPRequestDuration, _  = meter.Float64Histogram(MetricHTTPRequestDuration, metric.WithDescription("Latency of HTTP requests."), metric.WithUnit("s"))
+	WebhookEvents, _        = meter.Int64Counter(MetricWebhookEvents, metric.WithDescription("Received webhook events."))
+	WebhookDuplicates, _    = meter.Int64Counter(MetricWebhookDuplicates, metric.WithDescription("Webhook events skipped as already processed."))
+	WebhookThrottled, _     = meter.Int64Counter(MetricWebhookThrottled, metric.WithDescription("Webhook events dropped by the rate limiter."))
+	StationSearches, _      = meter.Int64Counter(MetricStationSearches, metric.WithDescription("Location searches by result."))
+	StationNotifications, _ = meter.Int64Counter(MetricStationNotifications, metric.WithDescription("Users notified of a station change."))
+	Reminders, _            = meter.Int64Counter(MetricReminders, metric.WithDescription("Due reminders by result."))
+	Routings, _             = meter.Int64Counter(MetricRoutings, metric.WithDescription("Travel estimations by provider."))
+	StationQueryDuration, _ = meter.Float64Histogram(MetricStationQueryDuration, metric.WithDescription("Latency of the Firestore stations query."), metric.WithUnit("s"))
+	LineRequestDuration, _  = meter.Float64Histogram(MetricLineRequestDuration, metric.WithDescription("Latency of LINE API calls."), metric.WithUnit("s"))
+	LineQuotaLimit, _       = meter.Int64Gauge(MetricLineQuotaLimit, metric.WithDescription("Monthly push message quota."))
+	LineQuotaUsage, _       = meter.Int64Gauge(MetricLineQuotaUsage, metric.WithDescription("Push messages sent this month."))
+	LineQuotaOverBudget, _  = meter.Int64Gauge(MetricLineQuotaOverBudget, metric.WithDescription("Whether the channel is over its push budget."))
+	LinePushesBlocked, _    = meter.Int64Counter(MetricLinePushesBlocked, metric.WithDescription("Pushes skipped because the channel is over its push budget."))
+)
+
+// NewMeterProvider returns a MeterProvider exposing the metrics on the returned Prometheus handler.
+// The metrics are also pushed over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_METRICS_ENDPOINT is set.
+func NewMeterProvider(ctx context.Context) (*sdkmetric.MeterProvider, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	promExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create Prometheus exporter: %v", err)
+	}
+
+	options := []sdkmetric.Option{sdkmetric.WithReader(promExporter)}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT") != "" {
+		otlpExporter, err := otlpmetrichttp.New(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP metric exporter: %v", err)
+		}
+		options = append(options, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(otlpExporter)))
+	}
+
+	provider := sdkmetric.NewMeterProvider(options...)
+	return provider, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
+
+// SetupMetrics installs a MeterProvider from NewMeterProvider as the global one.
+func SetupMetrics(ctx context.Context) (*sdkmetric.MeterProvider, http.Handler, error) {
+	provider, handler, err := NewMeterProvider(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	otel.SetMeterProvider(provider)
+	return provider, handler, nil
+}
@@ -0,0 +1,29 @@
+package libs
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMeterProvider(t *testing.T) {
+	provider, handler, err := NewMeterProvider(context.TODO())
+	assert.NoError(t, err)
+	defer provider.Shutdown(context.TODO())
+
+	meter := provider.Meter("test")
+	searches, _ := meter.Int64Counter(MetricStationSearches)
+	searches.Add(context.TODO(), 2)
+	duration, _ := meter.Float64Histogram(MetricStationQueryDuration)
+	duration.Record(context.TODO(), 0.25)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+
+	assert.Contains(t, string(body), "sogorro_station_searches_total")
+	assert.Contains(t, string(body), "sogorro_station_query_duration_bucket")
+}
@@ -0,0 +1,90 @@
+package libs
+
+import (
+	"net/url"
+)
+
+// Names of the navigation apps saved in the user preferences.
+const (
+	GoogleMapsNavigation = "google"
+	AppleMapsNavigation  = "apple"
+	WazeNavigation       = "waze"
+)
+
+// NavigationProvider builds the URI of the 立即前往 button, opening the directions to a station in
+// a navigation app.
+type NavigationProvider interface {
+	// Name is saved in the user preferences.
+	Name() string
+	// Label is shown to the user choosing the app.
+	Label() string
+	// DirectionsUri starts from the origin, or the current location of the phone when nil.
+	DirectionsUri(origin *MapPoint, destination MapPoint) string
+}
+
+// GoogleMaps navigates by scooter, the two-wheeler travel mode of Google Maps.
+type GoogleMaps struct{}
+
+func (GoogleMaps) Name() string  { return GoogleMapsNavigation }
+func (GoogleMaps) Label() string { return "Google 地圖" }
+
+func (GoogleMaps) DirectionsUri(origin *MapPoint, destination MapPoint) string {
+	query := url.Values{
+		"api":         {"1"},
+		"destination": {destination.String()},
+		"travelmode":  {"two-wheeler"},
+	}
+	if origin != nil {
+		query.Set("origin", origin.String())
+	}
+
+	return "https://www.google.com/maps/dir/?" + query.Encode()
+}
+
+// AppleMaps navigates by car, Apple Maps has no mode for scooters.
+type AppleMaps struct{}
+
+func (AppleMaps) Name() string  { return AppleMapsNavigation }
+func (AppleMaps) Label() string { return "Apple 地圖" }
+
+func (AppleMaps) DirectionsUri(origin *MapPoint, destination MapPoint) string {
+	query := url.Values{
+		"daddr":  {destination.String()},
+		"dirflg": {"d"},
+	}
+	if origin != nil {
+		query.Set("saddr", origin.String())
+	}
+
+	return "https://maps.apple.com/?" + query.Encode()
+}
+
+// Waze always starts from the current location of the phone, the origin is ignored.
+type Waze struct{}
+
+func (Waze) Name() string  { return WazeNavigation }
+func (Waze) Label() string { return "Waze" }
+
+func (Waze) DirectionsUri(origin *MapPoint, destination MapPoint) string {
+	query := url.Values{
+		"ll":       {destination.String()},
+		"navigate": {"yes"},
+	}
+
+	return "https://waze.com/ul?" + query.Encode()
+}
+
+// NavigationProviders are the navigation apps a user can choose, the first one is the default.
+var NavigationProviders = []NavigationProvider{GoogleMaps{}, AppleMaps{}, Waze{}}
+
+// NavigationProviderOf returns the navigation app of the name, ok is false and the default app is
+// returned for an unknown name.
+func NavigationProviderOf(name string) (provider NavigationProvider, ok bool) {
+	for _, provider := range NavigationProviders {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+
+	return NavigationProviders[0], false
+}
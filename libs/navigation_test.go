@@ -0,0 +1,50 @@
+package libs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNavigationProviders(t *testing.T) {
+	origin := &MapPoint{Latitude: 25.03, Longitude: 121.56}
+	destination := MapPoint{Latitude: 25.033964, Longitude: 121.564468}
+
+	tests := []struct {
+		name        string
+		provider    string
+		origin      *MapPoint
+		expectedURI string
+	}{
+		{
+			name:        "Google Maps by scooter",
+			provider:    GoogleMapsNavigation,
+			origin:      origin,
+			expectedURI: "https://www.google.com/maps/dir/?api=1&destination=25.033964%2C121.564468&origin=25.030000%2C121.560000&travelmode=two-wheeler",
+		},
+		{
+			name:        "Apple Maps from the current location",
+			provider:    AppleMapsNavigation,
+			expectedURI: "https://maps.apple.com/?daddr=25.033964%2C121.564468&dirflg=d",
+		},
+		{
+			name:        "Waze ignores the origin",
+			provider:    WazeNavigation,
+			origin:      origin,
+			expectedURI: "https://waze.com/ul?ll=25.033964%2C121.564468&navigate=yes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, ok := NavigationProviderOf(tt.provider)
+			assert.True(t, ok)
+			assert.Equal(t, tt.provider, provider.Name())
+			assert.Equal(t, tt.expectedURI, provider.DirectionsUri(tt.origin, destination))
+		})
+	}
+
+	provider, ok := NavigationProviderOf("here")
+	assert.False(t, ok)
+	assert.Equal(t, GoogleMapsNavigation, provider.Name())
+}
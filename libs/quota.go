@@ -0,0 +1,166 @@
+package libs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Quota is the monthly push message quota of a channel and the number of messages already sent.
+type Quota struct {
+	// Limited is false when the plan of the channel has no quota.
+	Limited bool
+	Limit   int64
+	Used    int64
+}
+
+// QuotaTracker polls the quota and the consumption of a channel, and reports the channel as
+// over budget once the consumption crosses budget, a fraction of the quota.
+type QuotaTracker struct {
+	channel  string
+	endpoint string
+	tokens   *TokenManager
+	budget   float64
+	interval time.Duration
+
+	mu         sync.RWMutex
+	quota      Quota
+	overBudget bool
+}
+
+// NewQuotaTracker polls endpoint, the URL of the quota API, its consumption is read from
+// endpoint + "/consumption".
+func NewQuotaTracker(channel, endpoint string, tokens *TokenManager, budget float64, interval time.Duration) *QuotaTracker {
+	return &QuotaTracker{
+		channel:  channel,
+		endpoint: endpoint,
+		tokens:   tokens,
+		budget:   budget,
+		interval: interval,
+	}
+}
+
+// Quota returns the quota read by the last refresh.
+func (t *QuotaTracker) Quota() Quota {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.quota
+}
+
+// OverBudget tells whether the consumption crossed the budget at the last refresh.
+func (t *QuotaTracker) OverBudget() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.overBudget
+}
+
+// Reserve counts n push messages against the budget before they are sent, so the consumption is
+// known until the next refresh. It returns false and reserves nothing when they would cross the
+// budget, channels without a quota always have room.
+func (t *QuotaTracker) Reserve(n int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.quota.Limited {
+		return true
+	}
+	if float64(t.quota.Used+n) > t.budget*float64(t.quota.Limit) {
+		return false
+	}
+
+	t.quota.Used += n
+	t.overBudget = float64(t.quota.Used) >= t.budget*float64(t.quota.Limit)
+	return true
+}
+
+// Refresh reads the quota and the consumption, and records them as metrics.
+func (t *QuotaTracker) Refresh(ctx context.Context) error {
+	var quota struct {
+		Type  string `json:"type"`
+		Value int64  `json:"value"`
+	}
+	if err := t.get(ctx, t.endpoint, &quota); err != nil {
+		return fmt.Errorf("failed to get message quota: %v", err)
+	}
+
+	var consumption struct {
+		TotalUsage int64 `json:"totalUsage"`
+	}
+	if err := t.get(ctx, t.endpoint+"/consumption", &consumption); err != nil {
+		return fmt.Errorf("failed to get message quota consumption: %v", err)
+	}
+
+	current := Quota{Limited: quota.Type == "limited", Limit: quota.Value, Used: consumption.TotalUsage}
+	overBudget := current.Limited && float64(current.Used) >= t.budget*float64(current.Limit)
+
+	t.mu.Lock()
+	changed := overBudget != t.overBudget
+	t.quota, t.overBudget = current, overBudget
+	t.mu.Unlock()
+
+	attrs := metric.WithAttributes(attribute.String("channel", t.channel))
+	LineQuotaUsage.Record(ctx, current.Used, attrs)
+	if current.Limited {
+		LineQuotaLimit.Record(ctx, current.Limit, attrs)
+	}
+	var replyOnly int64
+	if overBudget {
+		replyOnly = 1
+	}
+	LineQuotaOverBudget.Record(ctx, replyOnly, attrs)
+
+	if changed {
+		LoggerFromContext(ctx).Warn("push message budget changed", "channel", t.channel, "overBudget", overBudget, "used", current.Used, "limit", current.Limit)
+	}
+
+	return nil
+}
+
+// Run refreshes the quota every interval until ctx is done.
+func (t *QuotaTracker) Run(ctx context.Context) {
+	if t.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Refresh(ctx); err != nil {
+			LoggerFromContext(ctx).Error("failed to refresh message quota", "channel", t.channel, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// get reads the JSON result of url, with a new token when LINE rejected the cached one.
+func (t *QuotaTracker) get(ctx context.Context, url string, result interface{}) error {
+	var body []byte
+	err := t.tokens.Authorized(ctx, func(token string) error {
+		var err error
+		body, err = MakeRequestContext(ctx, http.MethodGet, url, map[string]string{"Authorization": fmt.Sprintf("Bearer %s", token)}, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("unable to decode response: %v", err)
+	}
+
+	return nil
+}
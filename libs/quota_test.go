@@ -0,0 +1,119 @@
+package libs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaTracker(t *testing.T) {
+	tests := []struct {
+		name               string
+		quota              string
+		consumption        string
+		expectedQuota      Quota
+		expectedOverBudget bool
+		expectedError      string
+	}{
+		{
+			name:          "under budget",
+			quota:         `{"type":"limited","value":1000}`,
+			consumption:   `{"totalUsage":899}`,
+			expectedQuota: Quota{Limited: true, Limit: 1000, Used: 899},
+		},
+		{
+			name:               "over budget",
+			quota:              `{"type":"limited","value":1000}`,
+			consumption:        `{"totalUsage":900}`,
+			expectedQuota:      Quota{Limited: true, Limit: 1000, Used: 900},
+			expectedOverBudget: true,
+		},
+		{
+			name:          "no quota",
+			quota:         `{"type":"none"}`,
+			consumption:   `{"totalUsage":100000}`,
+			expectedQuota: Quota{Used: 100000},
+		},
+		{
+			name:          "consumption error",
+			quota:         `{"type":"limited","value":1000}`,
+			expectedError: "failed to get message quota consumption",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mux.NewRouter()
+			r.HandleFunc("/v2/bot/message/quota", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer access-token", r.Header.Get("Authorization"))
+				w.Write([]byte(tt.quota))
+			})
+			r.HandleFunc("/v2/bot/message/quota/consumption", func(w http.ResponseWriter, r *http.Request) {
+				if tt.consumption == "" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Write([]byte(tt.consumption))
+			})
+			server := httptest.NewServer(r)
+			defer server.Close()
+
+			tokens := NewTokenManager(StaticTokenSource("access-token"), 0)
+			tracker := NewQuotaTracker("test", server.URL+"/v2/bot/message/quota", tokens, 0.9, time.Minute)
+
+			err := tracker.Refresh(context.TODO())
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				assert.False(t, tracker.OverBudget())
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedQuota, tracker.Quota())
+			assert.Equal(t, tt.expectedOverBudget, tracker.OverBudget())
+		})
+	}
+}
+
+func TestQuotaTrackerReserve(t *testing.T) {
+	tracker := NewQuotaTracker("test", "", nil, 0.9, 0)
+	assert.True(t, tracker.Reserve(1000), "no quota")
+
+	tracker.quota = Quota{Limited: true, Limit: 1000, Used: 800}
+	assert.False(t, tracker.Reserve(101))
+	assert.Equal(t, int64(800), tracker.Quota().Used)
+
+	assert.True(t, tracker.Reserve(100))
+	assert.Equal(t, int64(900), tracker.Quota().Used)
+	assert.True(t, tracker.OverBudget())
+	assert.False(t, tracker.Reserve(1))
+}
+
+func TestQuotaTrackerRotatedToken(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/v2/bot/message/quota", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"type":"limited","value":1000}`))
+	})
+	r.HandleFunc("/v2/bot/message/quota/consumption", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"totalUsage":10}`))
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	source := &countingTokenSource{}
+	tracker := NewQuotaTracker("test", server.URL+"/v2/bot/message/quota", NewTokenManager(source, 0), 0.9, time.Minute)
+
+	// the rejected token is replaced once, and the new one is kept for the consumption
+	assert.NoError(t, tracker.Refresh(context.TODO()))
+	assert.Equal(t, Quota{Limited: true, Limit: 1000, Used: 10}, tracker.Quota())
+	assert.Equal(t, 2, source.fetches)
+}
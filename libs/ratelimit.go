@@ -0,0 +1,118 @@
+package libs
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Scopes of a throttled webhook event.
+const (
+	UserRateLimit   = "user"
+	GlobalRateLimit = "global"
+)
+
+// RateDecision tells whether a webhook event may be processed. Notify is set for the first event
+// throttled since the user was last allowed, so the user is asked to slow down only once.
+type RateDecision struct {
+	Allowed bool
+	Scope   string
+	Notify  bool
+}
+
+// RateLimiter throttles webhook events with a token bucket per user and a global one, protecting
+// the Firestore reads and the LINE quota from a single spamming user or a misbehaving client.
+type RateLimiter struct {
+	global    *rate.Limiter
+	userRate  rate.Limit
+	userBurst int
+
+	mu        sync.Mutex
+	users     map[string]*userLimiter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type userLimiter struct {
+	limiter   *rate.Limiter
+	lastSeen  time.Time
+	throttled bool
+}
+
+// NewRateLimiter allows each user userRate events per second with bursts of userBurst, and all
+// the users together globalRate events per second with bursts of globalBurst.
+func NewRateLimiter(userRate float64, userBurst int, globalRate float64, globalBurst int) *RateLimiter {
+	return &RateLimiter{
+		global:    rate.NewLimiter(rate.Limit(globalRate), globalBurst),
+		userRate:  rate.Limit(userRate),
+		userBurst: userBurst,
+		users:     map[string]*userLimiter{},
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token for an event of the user, events without a user only count globally.
+func (l *RateLimiter) Allow(userId string) RateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	user := l.users[userId]
+	if user == nil {
+		user = &userLimiter{limiter: rate.NewLimiter(l.userRate, l.userBurst)}
+		if userId != "" {
+			l.users[userId] = user
+		}
+	}
+	user.lastSeen = now
+
+	decision := RateDecision{Allowed: true}
+	if userId != "" {
+		reservation := user.limiter.ReserveN(now, 1)
+		if !reservation.OK() || reservation.DelayFrom(now) > 0 {
+			reservation.CancelAt(now)
+			decision = RateDecision{Scope: UserRateLimit}
+		} else if global := l.global.ReserveN(now, 1); !global.OK() || global.DelayFrom(now) > 0 {
+			// the event isn't processed, so it doesn't count against the user either
+			global.CancelAt(now)
+			reservation.CancelAt(now)
+			decision = RateDecision{Scope: GlobalRateLimit}
+		}
+	} else if !l.global.AllowN(now, 1) {
+		decision = RateDecision{Scope: GlobalRateLimit}
+	}
+
+	if !decision.Allowed {
+		decision.Notify = !user.throttled && userId != ""
+		user.throttled = true
+		return decision
+	}
+
+	user.throttled = false
+	return decision
+}
+
+// sweep forgets the users idle long enough for their bucket to be full again, at most once per
+// refill period.
+func (l *RateLimiter) sweep(now time.Time) {
+	idle := time.Minute
+	if l.userRate > 0 {
+		if refill := time.Duration(float64(l.userBurst) / float64(l.userRate) * float64(time.Second)); refill > idle {
+			idle = refill
+		}
+	}
+
+	if now.Sub(l.lastSweep) < idle {
+		return
+	}
+	l.lastSweep = now
+
+	for userId, user := range l.users {
+		if now.Sub(user.lastSeen) >= idle {
+			delete(l.users, userId)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package libs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("per user", func(t *testing.T) {
+		limiter := NewRateLimiter(1, 2, 100, 100)
+		limiter.now = func() time.Time { return now }
+
+		assert.Equal(t, RateDecision{Allowed: true}, limiter.Allow("user-a"))
+		assert.Equal(t, RateDecision{Allowed: true}, limiter.Allow("user-a"))
+		assert.Equal(t, RateDecision{Scope: UserRateLimit, Notify: true}, limiter.Allow("user-a"))
+		// the user is asked to slow down only once
+		assert.Equal(t, RateDecision{Scope: UserRateLimit}, limiter.Allow("user-a"))
+
+		// other users have their own bucket
+		assert.Equal(t, RateDecision{Allowed: true}, limiter.Allow("user-b"))
+
+		// the bucket refills over time
+		limiter.now = func() time.Time { return now.Add(time.Second) }
+		assert.Equal(t, RateDecision{Allowed: true}, limiter.Allow("user-a"))
+		assert.Equal(t, RateDecision{Scope: UserRateLimit, Notify: true}, limiter.Allow("user-a"))
+	})
+
+	t.Run("global", func(t *testing.T) {
+		limiter := NewRateLimiter(10, 10, 1, 2)
+		limiter.now = func() time.Time { return now }
+
+		assert.True(t, limiter.Allow("user-a").Allowed)
+		assert.True(t, limiter.Allow("user-b").Allowed)
+		assert.Equal(t, RateDecision{Scope: GlobalRateLimit, Notify: true}, limiter.Allow("user-c"))
+		assert.Equal(t, RateDecision{Scope: GlobalRateLimit}, limiter.Allow(""))
+
+		// events throttled globally don't use the tokens of the user
+		limiter.now = func() time.Time { return now.Add(time.Second) }
+		assert.True(t, limiter.Allow("user-c").Allowed)
+	})
+
+	t.Run("idle users are forgotten", func(t *testing.T) {
+		limiter := NewRateLimiter(1, 2, 100, 100)
+		limiter.now = func() time.Time { return now }
+		limiter.Allow("user-a")
+		assert.Len(t, limiter.users, 1)
+
+		limiter.now = func() time.Time { return now.Add(2 * time.Minute) }
+		limiter.Allow("user-b")
+		assert.Len(t, limiter.users, 1)
+		assert.Contains(t, limiter.users, "user-b")
+	})
+}
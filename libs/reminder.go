@@ -0,0 +1,195 @@
+package libs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// Reminder pushes the nearest open stations around a saved location, like the workplace of a
+// rider, once a day within a window of time.
+type Reminder struct {
+	Id        string  `firestore:"-"`
+	UserId    string  `firestore:"userId"`
+	Channel   string  `firestore:"channel"`
+	Label     string  `firestore:"label"`
+	Latitude  float64 `firestore:"latitude"`
+	Longitude float64 `firestore:"longitude"`
+	// StartMinute and EndMinute bound the local time the reminder is sent at, in minutes since
+	// midnight and both included. The first run of the scheduler in the window sends it.
+	StartMinute int `firestore:"startMinute"`
+	EndMinute   int `firestore:"endMinute"`
+	// Weekdays the reminder is sent, 0 is Sunday. Empty means every day.
+	Weekdays []int `firestore:"weekdays"`
+}
+
+// OnWeekday tells whether the reminder is sent on the day.
+func (r Reminder) OnWeekday(weekday int) bool {
+	if len(r.Weekdays) == 0 {
+		return true
+	}
+
+	for _, day := range r.Weekdays {
+		if day == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InWindow tells whether the minute of the day is in the window of the reminder.
+func (r Reminder) InWindow(minute int) bool {
+	return minute >= r.StartMinute && minute <= r.EndMinute
+}
+
+// Validate checks the window is within a day, a window doesn't span midnight, and lasts at most
+// MaxReminderWindow.
+func (r Reminder) Validate() error {
+	if r.StartMinute < 0 || r.EndMinute >= minutesPerDay || r.StartMinute > r.EndMinute {
+		return fmt.Errorf("reminder window %s-%s must be within a day", FormatTimeOfDay(r.StartMinute), FormatTimeOfDay(r.EndMinute))
+	}
+	if r.EndMinute-r.StartMinute > maxWindowMinutes {
+		return fmt.Errorf("reminder window %s-%s must last at most %d minutes", FormatTimeOfDay(r.StartMinute), FormatTimeOfDay(r.EndMinute), maxWindowMinutes)
+	}
+
+	return nil
+}
+
+const minutesPerDay = 24 * 60
+
+// MaxReminderWindow is the longest window of a reminder, it bounds the reminders the scheduler
+// reads on each run.
+const MaxReminderWindow = 2 * time.Hour
+
+const maxWindowMinutes = int(MaxReminderWindow / time.Minute)
+
+// ParseTimeOfDay parses a time like 08:15, as picked in LINE, to minutes since midnight.
+func ParseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.New("time of day must be like 08:15")
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatTimeOfDay formats minutes since midnight like 08:15.
+func FormatTimeOfDay(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// ReminderStore keeps the reminders of the users.
+type ReminderStore interface {
+	Save(ctx context.Context, reminder Reminder) (Reminder, error)
+	// Due returns the reminders whose window includes the minute of the day.
+	Due(ctx context.Context, minute int) ([]Reminder, error)
+}
+
+// FirestoreReminderStore keeps a document per reminder, indexed on startMinute.
+type FirestoreReminderStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+func NewFirestoreReminderStore(client *firestore.Client, collection string) *FirestoreReminderStore {
+	return &FirestoreReminderStore{
+		client:     client,
+		collection: collection,
+	}
+}
+
+func (s *FirestoreReminderStore) Save(ctx context.Context, reminder Reminder) (Reminder, error) {
+	ref := s.client.Collection(s.collection).NewDoc()
+	if reminder.Id != "" {
+		ref = s.client.Collection(s.collection).Doc(reminder.Id)
+	}
+
+	if _, err := ref.Set(ctx, reminder); err != nil {
+		return reminder, fmt.Errorf("failed to save reminder: %v", err)
+	}
+	reminder.Id = ref.ID
+
+	return reminder, nil
+}
+
+// Due queries the reminders started by the minute, the ended ones are filtered out as Firestore
+// only filters a range on a single field.
+func (s *FirestoreReminderStore) Due(ctx context.Context, minute int) ([]Reminder, error) {
+	// the windows last at most MaxReminderWindow, the reminders starting earlier are over
+	iter := s.client.Collection(s.collection).
+		Where("startMinute", ">=", minute-maxWindowMinutes).
+		Where("startMinute", "<=", minute).
+		Documents(ctx)
+	defer iter.Stop()
+
+	reminders := []Reminder{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate reminders: %v", err)
+		}
+
+		var reminder Reminder
+		if err := doc.DataTo(&reminder); err != nil {
+			LoggerFromContext(ctx).Warn("skip invalid reminder", "reminderId", doc.Ref.ID, "error", err)
+			continue
+		}
+		reminder.Id = doc.Ref.ID
+		if reminder.InWindow(minute) {
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	return reminders, nil
+}
+
+// MemoryReminderStore keeps the reminders in memory. It is meant for tests and local runs.
+type MemoryReminderStore struct {
+	mu        sync.Mutex
+	reminders map[string]Reminder
+}
+
+func NewMemoryReminderStore() *MemoryReminderStore {
+	return &MemoryReminderStore{
+		reminders: make(map[string]Reminder),
+	}
+}
+
+func (s *MemoryReminderStore) Save(ctx context.Context, reminder Reminder) (Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reminder.Id == "" {
+		reminder.Id = fmt.Sprintf("reminder-%d", len(s.reminders)+1)
+	}
+	s.reminders[reminder.Id] = reminder
+
+	return reminder, nil
+}
+
+func (s *MemoryReminderStore) Due(ctx context.Context, minute int) ([]Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reminders := []Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.InWindow(minute) {
+			reminders = append(reminders, reminder)
+		}
+	}
+
+	sort.Slice(reminders, func(j, k int) bool { return reminders[j].Id < reminders[k].Id })
+
+	return reminders, nil
+}
@@ -0,0 +1,89 @@
+package libs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReminderOnWeekday(t *testing.T) {
+	tests := []struct {
+		name     string
+		weekdays []int
+		weekday  time.Weekday
+		expected bool
+	}{
+		{name: "every day", weekdays: nil, weekday: time.Sunday, expected: true},
+		{name: "working day", weekdays: []int{1, 2, 3, 4, 5}, weekday: time.Wednesday, expected: true},
+		{name: "weekend", weekdays: []int{1, 2, 3, 4, 5}, weekday: time.Saturday, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Reminder{Weekdays: tt.weekdays}.OnWeekday(int(tt.weekday)))
+		})
+	}
+}
+
+func TestReminderValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		start, end    int
+		expectedError string
+	}{
+		{name: "morning", start: 8 * 60, end: 8*60 + 30},
+		{name: "single minute", start: 8 * 60, end: 8 * 60},
+		{name: "end of the day", start: 23 * 60, end: 24*60 - 1},
+		{name: "reversed", start: 9 * 60, end: 8 * 60, expectedError: "reminder window 09:00-08:00 must be within a day"},
+		{name: "past midnight", start: 23 * 60, end: 24 * 60, expectedError: "must be within a day"},
+		{name: "longest window", start: 8 * 60, end: 10 * 60},
+		{name: "too long", start: 8 * 60, end: 10*60 + 1, expectedError: "reminder window 08:00-10:01 must last at most 120 minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Reminder{StartMinute: tt.start, EndMinute: tt.end}.Validate()
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	minute, err := ParseTimeOfDay("08:15")
+	assert.NoError(t, err)
+	assert.Equal(t, 8*60+15, minute)
+	assert.Equal(t, "08:15", FormatTimeOfDay(minute))
+
+	for _, value := range []string{"", "8", "24:00", "08:60", "morning"} {
+		_, err := ParseTimeOfDay(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestMemoryReminderStore(t *testing.T) {
+	store := NewMemoryReminderStore()
+	ctx := context.TODO()
+
+	morning, err := store.Save(ctx, Reminder{UserId: "user-a", StartMinute: 8*60 + 15, EndMinute: 8*60 + 45})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, morning.Id)
+	store.Save(ctx, Reminder{UserId: "user-b", StartMinute: 8*60 + 30, EndMinute: 9 * 60})
+	store.Save(ctx, Reminder{UserId: "user-a", StartMinute: 18 * 60, EndMinute: 19 * 60})
+
+	due, err := store.Due(ctx, 8*60+20)
+	assert.NoError(t, err)
+	assert.Equal(t, []Reminder{morning}, due)
+
+	due, _ = store.Due(ctx, 8*60+45)
+	assert.Len(t, due, 2)
+
+	due, _ = store.Due(ctx, 60)
+	assert.Empty(t, due)
+}
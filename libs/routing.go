@@ -0,0 +1,169 @@
+package libs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Route is the estimated travel to a station.
+type Route struct {
+	// Distance along the roads in kilometers.
+	Distance float64
+	Duration time.Duration
+}
+
+// Minutes is the duration of the route rounded up to the minute, at least 1.
+func (r Route) Minutes() int {
+	minutes := int((r.Duration + time.Minute - 1) / time.Minute)
+	if minutes < 1 {
+		return 1
+	}
+
+	return minutes
+}
+
+// RoutingProvider estimates the travel from the user to the stations.
+type RoutingProvider interface {
+	// Routes returns the route from the origin to each destination, in the order of the destinations.
+	Routes(ctx context.Context, origin MapPoint, destinations []MapPoint) ([]Route, error)
+}
+
+// OSRMRouting is a RoutingProvider of the table service of an OSRM server, like
+// https://router.project-osrm.org with the driving profile.
+type OSRMRouting struct {
+	BaseUrl string
+	Profile string
+	Client  *http.Client
+}
+
+func NewOSRMRouting(baseUrl, profile string, timeout time.Duration) *OSRMRouting {
+	return &OSRMRouting{
+		BaseUrl: strings.TrimSuffix(baseUrl, "/"),
+		Profile: profile,
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (o *OSRMRouting) Routes(ctx context.Context, origin MapPoint, destinations []MapPoint) ([]Route, error) {
+	if len(destinations) == 0 {
+		return nil, nil
+	}
+
+	ctx, span := Tracer.Start(ctx, "osrm.table", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attribute.Int("osrm.destinations", len(destinations))))
+	defer span.End()
+
+	routes, err := o.table(ctx, origin, destinations)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return routes, err
+}
+
+func (o *OSRMRouting) table(ctx context.Context, origin MapPoint, destinations []MapPoint) ([]Route, error) {
+	// OSRM takes the coordinates as lng,lat, the origin is the first one
+	coordinates := []string{osrmCoordinate(origin)}
+	for _, destination := range destinations {
+		coordinates = append(coordinates, osrmCoordinate(destination))
+	}
+	query := url.Values{"sources": {"0"}, "annotations": {"duration,distance"}}
+	endpoint := fmt.Sprintf("%s/table/v1/%s/%s?%s", o.BaseUrl, o.Profile, strings.Join(coordinates, ";"), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create OSRM request: %v", err)
+	}
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request OSRM: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var table struct {
+		Code      string       `json:"code"`
+		Message   string       `json:"message"`
+		Durations [][]*float64 `json:"durations"`
+		Distances [][]*float64 `json:"distances"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&table); err != nil {
+		return nil, fmt.Errorf("unable to decode OSRM response (%d): %v", resp.StatusCode, err)
+	}
+	if table.Code != "Ok" {
+		return nil, fmt.Errorf("OSRM table failed (%d): %s %s", resp.StatusCode, table.Code, table.Message)
+	}
+	if len(table.Durations) != 1 || len(table.Durations[0]) != len(coordinates) || len(table.Distances) != 1 || len(table.Distances[0]) != len(coordinates) {
+		return nil, fmt.Errorf("OSRM table has %d rows, expected 1 row of %d durations and distances", len(table.Durations), len(coordinates))
+	}
+
+	routes := make([]Route, 0, len(destinations))
+	for i := 1; i < len(coordinates); i++ {
+		duration, distance := table.Durations[0][i], table.Distances[0][i]
+		if duration == nil || distance == nil {
+			return nil, fmt.Errorf("OSRM found no route to destination %d", i-1)
+		}
+		routes = append(routes, Route{
+			Distance: *distance / 1000,
+			Duration: time.Duration(*duration * float64(time.Second)),
+		})
+	}
+
+	return routes, nil
+}
+
+func osrmCoordinate(point MapPoint) string {
+	return strconv.FormatFloat(point.Longitude, 'f', 6, 64) + "," + strconv.FormatFloat(point.Latitude, 'f', 6, 64)
+}
+
+// HaversineRouting estimates the routes from the straight-line distance, lengthened by Detour for
+// the roads and travelled at Speed in km/h.
+type HaversineRouting struct {
+	Detour float64
+	Speed  float64
+}
+
+// DefaultHaversineRouting is a scooter riding at 25 km/h on roads 30% longer than the straight line.
+var DefaultHaversineRouting = HaversineRouting{Detour: 1.3, Speed: 25}
+
+func (h HaversineRouting) Routes(ctx context.Context, origin MapPoint, destinations []MapPoint) ([]Route, error) {
+	routes := make([]Route, 0, len(destinations))
+	for _, destination := range destinations {
+		distance := Haversine(origin.Latitude, origin.Longitude, destination.Latitude, destination.Longitude) * h.Detour
+		routes = append(routes, Route{
+			Distance: distance,
+			Duration: time.Duration(distance / h.Speed * float64(time.Hour)),
+		})
+	}
+
+	return routes, nil
+}
+
+// FallbackRouting asks the Primary provider, and the Fallback one when it fails.
+type FallbackRouting struct {
+	Primary  RoutingProvider
+	Fallback RoutingProvider
+}
+
+func (f FallbackRouting) Routes(ctx context.Context, origin MapPoint, destinations []MapPoint) ([]Route, error) {
+	routes, err := f.Primary.Routes(ctx, origin, destinations)
+	if err == nil {
+		Routings.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", "primary")))
+		return routes, nil
+	}
+
+	LoggerFromContext(ctx).Warn("routing failed, falling back", "error", err)
+	Routings.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", "fallback")))
+	return f.Fallback.Routes(ctx, origin, destinations)
+}
@@ -0,0 +1,106 @@
+package libs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOSRMRouting(t *testing.T) {
+	origin := MapPoint{Latitude: 25.03, Longitude: 121.56}
+	destinations := []MapPoint{{Latitude: 25.033964, Longitude: 121.564468}, {Latitude: 25.0375, Longitude: 121.5637}}
+
+	tests := []struct {
+		name           string
+		response       string
+		expectedRoutes []Route
+		expectedError  string
+	}{
+		{
+			name:           "routes",
+			response:       `{"code":"Ok","durations":[[0,95.5,420]],"distances":[[0,812.3,2400]]}`,
+			expectedRoutes: []Route{{Distance: 0.8123, Duration: 95500 * time.Millisecond}, {Distance: 2.4, Duration: 7 * time.Minute}},
+		},
+		{
+			name:          "unreachable station",
+			response:      `{"code":"Ok","durations":[[0,95.5,null]],"distances":[[0,812.3,null]]}`,
+			expectedError: "no route to destination 1",
+		},
+		{
+			name:          "invalid coordinates",
+			response:      `{"code":"InvalidQuery","message":"Query string malformed close to position 28"}`,
+			expectedError: "InvalidQuery",
+		},
+		{
+			name:          "missing durations",
+			response:      `{"code":"Ok","durations":[[0]],"distances":[[0]]}`,
+			expectedError: "expected 1 row of 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/table/v1/driving/121.560000,25.030000;121.564468,25.033964;121.563700,25.037500", r.URL.Path)
+				assert.Equal(t, "0", r.URL.Query().Get("sources"))
+				assert.Equal(t, "duration,distance", r.URL.Query().Get("annotations"))
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			routes, err := NewOSRMRouting(server.URL+"/", "driving", time.Second).Routes(context.TODO(), origin, destinations)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.InDeltaSlice(t, []float64{tt.expectedRoutes[0].Distance, tt.expectedRoutes[1].Distance}, []float64{routes[0].Distance, routes[1].Distance}, 1e-9)
+			assert.Equal(t, tt.expectedRoutes[0].Duration, routes[0].Duration)
+			assert.Equal(t, tt.expectedRoutes[1].Duration, routes[1].Duration)
+		})
+	}
+}
+
+// failingRouting is a RoutingProvider always failing, like an unreachable OSRM server.
+type failingRouting struct{}
+
+func (failingRouting) Routes(ctx context.Context, origin MapPoint, destinations []MapPoint) ([]Route, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestFallbackRouting(t *testing.T) {
+	origin := MapPoint{Latitude: 25.03, Longitude: 121.56}
+	destinations := []MapPoint{{Latitude: 25.0375, Longitude: 121.5637}}
+
+	routes, err := FallbackRouting{Primary: failingRouting{}, Fallback: DefaultHaversineRouting}.Routes(context.TODO(), origin, destinations)
+	assert.NoError(t, err)
+	assert.Len(t, routes, 1)
+
+	straight := Haversine(origin.Latitude, origin.Longitude, destinations[0].Latitude, destinations[0].Longitude)
+	assert.InDelta(t, straight*1.3, routes[0].Distance, 1e-9)
+	assert.Equal(t, 3, routes[0].Minutes())
+}
+
+func TestRouteMinutes(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected int
+	}{
+		{duration: 0, expected: 1},
+		{duration: 30 * time.Second, expected: 1},
+		{duration: 4 * time.Minute, expected: 4},
+		{duration: 4*time.Minute + time.Second, expected: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.duration.String(), func(t *testing.T) {
+			assert.Equal(t, tt.expected, Route{Duration: tt.duration}.Minutes())
+		})
+	}
+}
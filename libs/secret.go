@@ -0,0 +1,85 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// SecretProvider resolves secrets, like the LINE channel access token, by name.
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// SecretManagerProvider reads the latest version of secrets stored in Secret Manager.
+type SecretManagerProvider struct {
+	projectId string
+}
+
+func NewSecretManagerProvider(projectId string) *SecretManagerProvider {
+	return &SecretManagerProvider{projectId: projectId}
+}
+
+func (p *SecretManagerProvider) Secret(ctx context.Context, name string) (string, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create secret manager client: %v", err)
+	}
+	defer client.Close()
+
+	req := &secretmanagerpb.AccessSecretVersionRequest{
+		Name: fmt.Sprintf("projects/%s/secrets/%s/versions/latest", p.projectId, name),
+	}
+
+	result, err := client.AccessSecretVersion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret version: %v", err)
+	}
+
+	return string(result.Payload.Data), nil
+}
+
+// EnvSecretProvider reads secrets from the environment variable named after the secret.
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+// FileSecretProvider reads secrets from files named after the secret in a directory, like secrets
+// mounted as volumes. The trailing newline is trimmed.
+type FileSecretProvider struct {
+	dir string
+}
+
+func NewFileSecretProvider(dir string) *FileSecretProvider {
+	return &FileSecretProvider{dir: dir}
+}
+
+func (p *FileSecretProvider) Secret(ctx context.Context, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %v", err)
+	}
+
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", name)
+	}
+
+	return value, nil
+}
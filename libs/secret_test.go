@@ -0,0 +1,53 @@
+package libs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvSecretProvider(t *testing.T) {
+	t.Setenv("LINEBOT_ACCESS_TOKEN", "mock-token")
+
+	secret, err := EnvSecretProvider{}.Secret(context.TODO(), "LINEBOT_ACCESS_TOKEN")
+	assert.NoError(t, err)
+	assert.Equal(t, "mock-token", secret)
+
+	_, err = EnvSecretProvider{}.Secret(context.TODO(), "MISSING_ACCESS_TOKEN")
+	assert.ErrorContains(t, err, "environment variable MISSING_ACCESS_TOKEN is not set")
+}
+
+func TestFileSecretProvider(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "line-token"), []byte("mock-token\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "empty"), []byte("\n"), 0600)
+	provider := NewFileSecretProvider(dir)
+
+	tests := []struct {
+		name          string
+		secretName    string
+		expected      string
+		expectedError string
+	}{
+		{name: "trailing newline is trimmed", secretName: "line-token", expected: "mock-token"},
+		{name: "missing file", secretName: "missing", expectedError: "failed to read secret file"},
+		{name: "empty file", secretName: "empty", expectedError: "secret file empty is empty"},
+		{name: "path traversal", secretName: "../line-token", expectedError: "invalid secret name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := provider.Secret(context.TODO(), tt.secretName)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, secret)
+		})
+	}
+}
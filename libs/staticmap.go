@@ -0,0 +1,241 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// Size of the map images, the 20:13 hero of a bubble.
+const (
+	MapWidth  = 800
+	MapHeight = 520
+)
+
+// MapPoint is a location shown on a map.
+type MapPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+func (p MapPoint) String() string {
+	return strconv.FormatFloat(p.Latitude, 'f', 6, 64) + "," + strconv.FormatFloat(p.Longitude, 'f', 6, 64)
+}
+
+// MapArea is a rectangle of latitudes and longitudes.
+type MapArea struct {
+	South, West, North, East float64
+}
+
+// Contains tells whether the point is inside the area.
+func (a MapArea) Contains(point MapPoint) bool {
+	return point.Latitude >= a.South && point.Latitude <= a.North && point.Longitude >= a.West && point.Longitude <= a.East
+}
+
+// ServiceArea covers the stations, Taiwan with Penghu, Kinmen and Matsu.
+var ServiceArea = MapArea{South: 21.5, West: 118, North: 26.5, East: 122.5}
+
+// StaticMapProvider returns the URL of a map image showing the user and a station, used as the hero
+// image of the station bubble. The URL is empty when the provider can't draw the points.
+type StaticMapProvider interface {
+	MapUrl(user, station MapPoint) string
+}
+
+// GoogleStaticMap is a StaticMapProvider of the Google Maps Static API.
+type GoogleStaticMap struct {
+	Key string
+}
+
+func (g GoogleStaticMap) MapUrl(user, station MapPoint) string {
+	query := url.Values{
+		"size":    {fmt.Sprintf("%dx%d", MapWidth/2, MapHeight/2)},
+		"scale":   {"2"},
+		"markers": {"color:blue|" + user.String(), "color:red|" + station.String()},
+		"key":     {g.Key},
+	}
+
+	return "https://maps.googleapis.com/maps/api/staticmap?" + query.Encode()
+}
+
+// LocalStaticMap is a StaticMapProvider of the maps drawn by RenderMap, served by the bot at
+// {BaseUrl}/map/{lat},{lng}.png?from={lat},{lng}. Only the points of the ServiceArea are drawn.
+type LocalStaticMap struct {
+	BaseUrl string
+}
+
+func (l LocalStaticMap) MapUrl(user, station MapPoint) string {
+	if !ServiceArea.Contains(user) || !ServiceArea.Contains(station) {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/map/%s.png?%s", l.BaseUrl, station, url.Values{"from": {user.String()}}.Encode())
+}
+
+// ParseMapPoint parses a "lat,lng" location.
+func ParseMapPoint(value string) (MapPoint, error) {
+	var point MapPoint
+	if _, err := fmt.Sscanf(value, "%g,%g", &point.Latitude, &point.Longitude); err != nil {
+		return point, fmt.Errorf("failed to parse location %q: %v", value, err)
+	}
+	if point.Latitude < -85 || point.Latitude > 85 || point.Longitude < -180 || point.Longitude > 180 {
+		return point, fmt.Errorf("location %q is out of the map", value)
+	}
+
+	return point, nil
+}
+
+var (
+	mapRoute   = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	mapUser    = color.RGBA{0x00, 0xa0, 0xe9, 0xff}
+	mapStation = color.RGBA{0xe4, 0x00, 0x7f, 0xff}
+)
+
+// mapMaxZoom keeps the markers of a user standing at the station apart.
+const mapMaxZoom = 17
+
+// mercator projects a point to the pixels of the Web Mercator world at the zoom.
+func mercator(point MapPoint, zoom float64) (x, y float64) {
+	size := 256 * math.Pow(2, zoom)
+	sin := math.Sin(point.Latitude * math.Pi / 180)
+	x = (point.Longitude + 180) / 360 * size
+	y = (0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)) * size
+	return x, y
+}
+
+// RenderMap draws a PNG of the station and, when set, the user over the streets of the tiles,
+// fitted in the image with the distance between them to scale.
+func RenderMap(ctx context.Context, w io.Writer, tiles *MapTiles, station MapPoint, user *MapPoint) error {
+	points := []MapPoint{station}
+	if user != nil {
+		points = append(points, *user)
+	}
+
+	// the highest zoom showing both points inside the margins
+	const margin = 60
+	zoom := mapMaxZoom
+	for ; zoom > 0; zoom-- {
+		x0, y0 := mercator(points[0], float64(zoom))
+		x1, y1 := mercator(points[len(points)-1], float64(zoom))
+		if math.Abs(x1-x0) <= MapWidth-2*margin && math.Abs(y1-y0) <= MapHeight-2*margin {
+			break
+		}
+	}
+
+	// the world pixel of the top left corner of the image
+	var centerX, centerY float64
+	for _, point := range points {
+		x, y := mercator(point, float64(zoom))
+		centerX += x / float64(len(points))
+		centerY += y / float64(len(points))
+	}
+	left, top := int(math.Round(centerX))-MapWidth/2, int(math.Round(centerY))-MapHeight/2
+	pixel := func(point MapPoint) (int, int) {
+		x, y := mercator(point, float64(zoom))
+		return int(math.Round(x)) - left, int(math.Round(y)) - top
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, MapWidth, MapHeight))
+	if err := drawTiles(ctx, img, tiles, zoom, left, top); err != nil {
+		return err
+	}
+
+	stationX, stationY := pixel(station)
+	if user != nil {
+		userX, userY := pixel(*user)
+		drawLine(img, userX, userY, stationX, stationY, mapRoute)
+		drawMarker(img, userX, userY, 12, mapUser)
+	}
+	drawMarker(img, stationX, stationY, 16, mapStation)
+
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("failed to encode map: %v", err)
+	}
+
+	return nil
+}
+
+// drawTiles draws the tiles covering the image, whose top left corner is at the world pixel
+// left,top of the zoom. The tiles are fetched concurrently.
+func drawTiles(ctx context.Context, img *image.RGBA, tiles *MapTiles, zoom, left, top int) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for tileY := floorDiv(top, tileSize); tileY*tileSize < top+MapHeight; tileY++ {
+		for tileX := floorDiv(left, tileSize); tileX*tileSize < left+MapWidth; tileX++ {
+			wg.Add(1)
+			go func(tileX, tileY int) {
+				defer wg.Done()
+
+				tile, err := tiles.Tile(ctx, zoom, tileX, tileY)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					return
+				}
+				at := image.Pt(tileX*tileSize-left, tileY*tileSize-top)
+				draw.Draw(img, image.Rectangle{Min: at, Max: at.Add(image.Pt(tileSize, tileSize))}, tile, tile.Bounds().Min, draw.Src)
+			}(tileX, tileY)
+		}
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// floorDiv divides rounding toward negative infinity.
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return (a - b + 1) / b
+	}
+
+	return a / b
+}
+
+// drawLine draws a dashed line between two pixels, as 3x3 squares along the line.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	src := image.NewUniform(c)
+	steps := int(math.Max(math.Abs(float64(x1-x0)), math.Abs(float64(y1-y0))))
+	for i := 0; i <= steps; i++ {
+		if (i/8)%2 == 1 {
+			continue
+		}
+		t := float64(i) / math.Max(float64(steps), 1)
+		x := int(float64(x0) + t*float64(x1-x0))
+		y := int(float64(y0) + t*float64(y1-y0))
+		draw.Draw(img, image.Rect(x-1, y-1, x+2, y+2), src, image.Point{}, draw.Src)
+	}
+}
+
+// drawMarker draws a disc outlined in white centered on a pixel, row by row.
+func drawMarker(img *image.RGBA, cx, cy, radius int, c color.RGBA) {
+	fill, white := image.NewUniform(c), image.NewUniform(color.White)
+	outline := radius + 3
+	for dy := -outline; dy <= outline; dy++ {
+		// the half widths of the disc and of its outline on the row
+		inner := 0
+		if dy*dy <= radius*radius {
+			inner = int(math.Sqrt(float64(radius*radius-dy*dy))) + 1
+		}
+		outer := int(math.Sqrt(float64(outline*outline-dy*dy))) + 1
+
+		y := cy + dy
+		draw.Draw(img, image.Rect(cx-outer+1, y, cx+outer, y+1), white, image.Point{}, draw.Src)
+		if inner > 0 {
+			draw.Draw(img, image.Rect(cx-inner+1, y, cx+inner, y+1), fill, image.Point{}, draw.Src)
+		}
+	}
+}
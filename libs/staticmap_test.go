@@ -0,0 +1,150 @@
+package libs
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticMapProviders(t *testing.T) {
+	user := MapPoint{Latitude: 25.03, Longitude: 121.56}
+	station := MapPoint{Latitude: 25.033964, Longitude: 121.564468}
+
+	local := LocalStaticMap{BaseUrl: "https://sogorro.example.com"}.MapUrl(user, station)
+	assert.Equal(t, "https://sogorro.example.com/map/25.033964,121.564468.png?from=25.030000%2C121.560000", local)
+	// the local maps are only drawn in the service area
+	assert.Empty(t, LocalStaticMap{BaseUrl: "https://sogorro.example.com"}.MapUrl(MapPoint{Latitude: 35.681236, Longitude: 139.767125}, station))
+
+	google, err := url.Parse(GoogleStaticMap{Key: "maps-key"}.MapUrl(user, station))
+	assert.NoError(t, err)
+	assert.Equal(t, "maps.googleapis.com", google.Host)
+	assert.Equal(t, []string{"color:blue|25.030000,121.560000", "color:red|25.033964,121.564468"}, google.Query()["markers"])
+	assert.Equal(t, "maps-key", google.Query().Get("key"))
+}
+
+func TestParseMapPoint(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      MapPoint
+		expectedError string
+	}{
+		{name: "location", value: "25.033964,121.564468", expected: MapPoint{Latitude: 25.033964, Longitude: 121.564468}},
+		{name: "negative", value: "-33.8688,-151.2093", expected: MapPoint{Latitude: -33.8688, Longitude: -151.2093}},
+		{name: "not a location", value: "taipei", expectedError: "failed to parse location"},
+		{name: "out of the map", value: "89,121.5", expectedError: "out of the map"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			point, err := ParseMapPoint(tt.value)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, point)
+		})
+	}
+}
+
+// tileColor fills the tiles of newTileServer.
+var tileColor = color.RGBA{0xf2, 0xef, 0xe9, 0xff}
+
+// newTileServer serves the same plain tile for every coordinate but those under /missing, and
+// counts the tiles fetched.
+func newTileServer(t *testing.T) (string, *atomic.Int64) {
+	tile := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	draw.Draw(tile, tile.Bounds(), image.NewUniform(tileColor), image.Point{}, draw.Src)
+	var encoded bytes.Buffer
+	assert.NoError(t, png.Encode(&encoded, tile))
+
+	fetched := &atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/missing/") {
+			http.NotFound(w, r)
+			return
+		}
+		fetched.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(encoded.Bytes())
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, fetched
+}
+
+func TestRenderMap(t *testing.T) {
+	station := MapPoint{Latitude: 25.033964, Longitude: 121.564468}
+	user := MapPoint{Latitude: 25.0375, Longitude: 121.5637}
+	tileServer, fetched := newTileServer(t)
+	tiles := NewMapTiles(tileServer + "/{z}/{x}/{y}.png")
+
+	tests := []struct {
+		name string
+		user *MapPoint
+	}{
+		{name: "station alone", user: nil},
+		{name: "user and station", user: &user},
+		{name: "user at the station", user: &station},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, RenderMap(context.TODO(), &out, tiles, station, tt.user))
+
+			img, err := png.Decode(&out)
+			assert.NoError(t, err)
+			assert.Equal(t, MapWidth, img.Bounds().Dx())
+			assert.Equal(t, MapHeight, img.Bounds().Dy())
+
+			// the markers are drawn inside the image, over the tiles covering it
+			colors := map[color.RGBA]bool{}
+			for y := 0; y < MapHeight; y++ {
+				for x := 0; x < MapWidth; x++ {
+					colors[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)] = true
+				}
+			}
+			assert.True(t, colors[mapStation])
+			if tt.user != nil && *tt.user != station {
+				assert.True(t, colors[mapUser])
+			}
+			assert.Equal(t, tileColor, color.RGBAModel.Convert(img.At(0, 0)))
+			assert.Equal(t, tileColor, color.RGBAModel.Convert(img.At(MapWidth-1, MapHeight-1)))
+			assert.False(t, colors[color.RGBA{}], "part of the image isn't covered by the tiles")
+		})
+	}
+
+	// the tiles are fetched once
+	count := fetched.Load()
+	assert.NoError(t, RenderMap(context.TODO(), io.Discard, tiles, station, nil))
+	assert.Equal(t, count, fetched.Load())
+
+	// a map missing a tile isn't drawn
+	err := RenderMap(context.TODO(), io.Discard, NewMapTiles(tileServer+"/missing/{z}/{x}/{y}.png"), station, nil)
+	assert.ErrorContains(t, err, "status 404")
+}
+
+func TestHeroImage(t *testing.T) {
+	bubble := BubbleMessage(GoStation{Id: "station-1", Location: "台北101站"}, BubbleOptions{})
+	assert.Nil(t, bubble.Contents.Hero)
+
+	bubble.Contents.Hero = HeroImage("https://sogorro.example.com/map/25.033964,121.564468.png")
+	assert.Equal(t, ImageElement, bubble.Contents.Hero.Type)
+	assert.Equal(t, "20:13", bubble.Contents.Hero.AspectRatio)
+	assert.True(t, strings.HasPrefix(bubble.Contents.Hero.Url, "https://"))
+}
@@ -0,0 +1,351 @@
+package libs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/iterator"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrStationNotFound is returned when no station is stored under the requested ID.
+var ErrStationNotFound = errors.New("station not found")
+
+const (
+	StationsCollection = "stations"
+	// StationAuditsCollection keeps a document per change of a station made through the admin API.
+	StationAuditsCollection = "stationAudits"
+	// DefaultSearchRadius is half the side of the box searched around a location, in degrees (about 3.5 km).
+	DefaultSearchRadius = 0.035
+	// ActiveState is the state of a station in service.
+	ActiveState = 1
+)
+
+// StationRepository looks up GoStations stored in the Firestore stations collection.
+type StationRepository struct {
+	client *firestore.Client
+	radius float64
+}
+
+func NewStationRepository(client *firestore.Client, radius float64) *StationRepository {
+	return &StationRepository{
+		client: client,
+		radius: radius,
+	}
+}
+
+// Nearby returns up to limit active stations found in the box around the location, nearest first.
+func (r *StationRepository) Nearby(ctx context.Context, latitude, longitude float64, limit int) ([]GoStation, error) {
+	query := r.client.Collection(StationsCollection).Where("latitude", ">=", latitude-r.radius).
+		Where("latitude", "<=", latitude+r.radius).
+		Where("longitude", ">=", longitude-r.radius).
+		Where("longitude", "<=", longitude+r.radius).
+		Where("state", "==", ActiveState)
+	ctx, span := Tracer.Start(ctx, "stations.Nearby", trace.WithAttributes(
+		attribute.Float64("search.latitude", latitude),
+		attribute.Float64("search.longitude", longitude),
+		attribute.Int("search.limit", limit),
+	))
+	defer span.End()
+
+	start := time.Now()
+	defer func() {
+		StationQueryDuration.Record(ctx, time.Since(start).Seconds())
+	}()
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	stations := []GoStation{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("failed to iterate document: %v", err)
+		}
+
+		// a malformed station is left out of the search instead of failing it
+		station, err := stationFromDocument(doc)
+		if err != nil {
+			LoggerFromContext(ctx).Warn("skip invalid station", "stationId", doc.Ref.ID, "error", err)
+			continue
+		}
+		station.Distance = Haversine(station.Latitude, station.Longitude, latitude, longitude)
+		stations = append(stations, station)
+	}
+
+	sort.Slice(stations, func(j, k int) bool {
+		return stations[j].Distance < stations[k].Distance
+	})
+
+	span.SetAttributes(attribute.Int("search.found", len(stations)))
+	if len(stations) > limit {
+		stations = stations[:limit]
+	}
+
+	return stations, nil
+}
+
+// Get returns the station stored under id, or ErrStationNotFound.
+func (r *StationRepository) Get(ctx context.Context, id string) (GoStation, error) {
+	ctx, span := Tracer.Start(ctx, "stations.Get", trace.WithAttributes(attribute.String("station.id", id)))
+	defer span.End()
+
+	doc, err := r.client.Collection(StationsCollection).Doc(id).Get(ctx)
+	if status.Code(err) == grpccodes.NotFound {
+		return GoStation{}, ErrStationNotFound
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return GoStation{}, fmt.Errorf("failed to get station %s: %v", id, err)
+	}
+
+	return stationFromDocument(doc)
+}
+
+// StationFilter selects the stations of an export, the empty fields match any station.
+type StationFilter struct {
+	City     string
+	District string
+	VMType   int64
+	// State matches the stations of the state when set, like ActiveState.
+	State *int64
+}
+
+// Match reports whether the station is selected by the filter.
+func (f StationFilter) Match(station GoStation) bool {
+	return (f.City == "" || station.City == f.City) &&
+		(f.District == "" || station.District == f.District) &&
+		(f.VMType == 0 || station.VMType == f.VMType) &&
+		(f.State == nil || station.State == *f.State)
+}
+
+// All returns the stations selected by the filter, ordered by ID.
+func (r *StationRepository) All(ctx context.Context, filter StationFilter) ([]GoStation, error) {
+	ctx, span := Tracer.Start(ctx, "stations.All")
+	defer span.End()
+
+	query := r.client.Collection(StationsCollection).Query
+	if filter.City != "" {
+		query = query.Where("city", "==", filter.City)
+	}
+	if filter.District != "" {
+		query = query.Where("district", "==", filter.District)
+	}
+	if filter.VMType != 0 {
+		query = query.Where("vmType", "==", filter.VMType)
+	}
+	if filter.State != nil {
+		query = query.Where("state", "==", *filter.State)
+	}
+
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	stations := []GoStation{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("failed to iterate document: %v", err)
+		}
+
+		station, err := stationFromDocument(doc)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		stations = append(stations, station)
+	}
+
+	sort.Slice(stations, func(j, k int) bool { return stations[j].Id < stations[k].Id })
+	span.SetAttributes(attribute.Int("export.found", len(stations)))
+
+	return stations, nil
+}
+
+// StationAudit records who changed a station and how, Before is nil for a created station and
+// After is nil for a deleted one.
+type StationAudit struct {
+	Action string
+	Actor  string
+	Before *GoStation
+	After  *GoStation
+	At     time.Time
+}
+
+// Update changes the station along with the audit record of the change, in a single transaction.
+// change receives the stored station, nil when there is none, and returns the new station, nil to
+// delete it. An error of change, like ErrStationNotFound, is returned as is and nothing is written.
+// The audit is returned with the station before and after the change.
+func (r *StationRepository) Update(ctx context.Context, id string, audit StationAudit, change func(before *GoStation) (*GoStation, error)) (StationAudit, error) {
+	ctx, span := Tracer.Start(ctx, "stations.Update", trace.WithAttributes(attribute.String("station.id", id), attribute.String("station.action", audit.Action)))
+	defer span.End()
+
+	var changeErr error
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		// the transaction may run again on contention, the audit is read again along
+		audit.Before, audit.After, changeErr = nil, nil, nil
+
+		ref := r.client.Collection(StationsCollection).Doc(id)
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != grpccodes.NotFound {
+			return err
+		}
+		if err == nil {
+			before, err := stationFromDocument(doc)
+			if err != nil {
+				return err
+			}
+			audit.Before = &before
+		}
+
+		if audit.After, changeErr = change(audit.Before); changeErr != nil {
+			return changeErr
+		}
+		if audit.After == nil {
+			err = tx.Delete(ref)
+		} else {
+			err = tx.Set(ref, stationData(*audit.After))
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(r.client.Collection(StationAuditsCollection).NewDoc(), auditData(id, audit))
+	})
+	if changeErr != nil {
+		return audit, changeErr
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return audit, fmt.Errorf("failed to %s station %s: %v", audit.Action, id, err)
+	}
+
+	return audit, nil
+}
+
+// auditData is the document of the audit record of a change of the station.
+func auditData(id string, audit StationAudit) map[string]interface{} {
+	data := map[string]interface{}{
+		"stationId": id,
+		"action":    audit.Action,
+		"actor":     audit.Actor,
+		"at":        audit.At,
+	}
+	if audit.Before != nil {
+		data["before"] = stationData(*audit.Before)
+	}
+	if audit.After != nil {
+		data["after"] = stationData(*audit.After)
+	}
+
+	return data
+}
+
+// Validate reports every invalid field of a station written through the admin API.
+func (s GoStation) Validate() error {
+	var errs []error
+
+	if s.Id == "" || strings.Contains(s.Id, "/") {
+		errs = append(errs, fmt.Errorf("id must be set and must not contain /, got %q", s.Id))
+	}
+	for name, value := range map[string]string{"location": s.Location, "address": s.Address, "city": s.City, "district": s.District} {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+	if s.Latitude < -90 || s.Latitude > 90 || s.Longitude < -180 || s.Longitude > 180 || (s.Latitude == 0 && s.Longitude == 0) {
+		errs = append(errs, fmt.Errorf("latitude and longitude must be a location, got %v,%v", s.Latitude, s.Longitude))
+	}
+	if s.VMType < 1 {
+		errs = append(errs, fmt.Errorf("vmType must be at least 1, got %d", s.VMType))
+	}
+	if s.State < 0 {
+		errs = append(errs, fmt.Errorf("state must not be negative, got %d", s.State))
+	}
+
+	return errors.Join(errs...)
+}
+
+// stationData is the document of a station, the reverse of stationFromDocument.
+func stationData(station GoStation) map[string]interface{} {
+	return map[string]interface{}{
+		"address":   station.Address,
+		"city":      station.City,
+		"district":  station.District,
+		"location":  station.Location,
+		"latitude":  station.Latitude,
+		"longitude": station.Longitude,
+		"vmType":    station.VMType,
+		"state":     station.State,
+	}
+}
+
+func stationFromDocument(doc *firestore.DocumentSnapshot) (GoStation, error) {
+	data := doc.Data()
+	station := GoStation{Id: doc.Ref.ID}
+
+	var ok bool
+	if station.Address, ok = data["address"].(string); !ok {
+		return station, fmt.Errorf("station %s has an invalid address", doc.Ref.ID)
+	}
+	if station.City, ok = data["city"].(string); !ok {
+		return station, fmt.Errorf("station %s has an invalid city", doc.Ref.ID)
+	}
+	if station.District, ok = data["district"].(string); !ok {
+		return station, fmt.Errorf("station %s has an invalid district", doc.Ref.ID)
+	}
+	if station.Location, ok = data["location"].(string); !ok {
+		return station, fmt.Errorf("station %s has an invalid location", doc.Ref.ID)
+	}
+	if station.Latitude, ok = toFloat(data["latitude"]); !ok {
+		return station, fmt.Errorf("station %s has an invalid latitude", doc.Ref.ID)
+	}
+	if station.Longitude, ok = toFloat(data["longitude"]); !ok {
+		return station, fmt.Errorf("station %s has an invalid longitude", doc.Ref.ID)
+	}
+	if station.VMType, ok = data["vmType"].(int64); !ok {
+		return station, fmt.Errorf("station %s has an invalid vmType", doc.Ref.ID)
+	}
+	if station.State, ok = data["state"].(int64); !ok {
+		return station, fmt.Errorf("station %s has an invalid state", doc.Ref.ID)
+	}
+
+	return station, nil
+}
+
+// toFloat reads a Firestore number, whole numbers are stored as integers.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+
+	return 0, false
+}
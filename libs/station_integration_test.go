@@ -0,0 +1,253 @@
+//go:build integration
+
+// Integration tests against the Firestore emulator, start it and run:
+//
+//	gcloud emulators firestore start --host-port=localhost:8200
+//	FIRESTORE_EMULATOR_HOST=localhost:8200 go test -tags integration ./libs/...
+package libs
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/iterator"
+)
+
+// Taipei 101
+const (
+	centerLatitude  = 25.033964
+	centerLongitude = 121.564468
+)
+
+func newEmulatorClient(t *testing.T) *firestore.Client {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "sogorro-integration")
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+// seedStations replaces the content of the stations collection with the fixtures.
+func seedStations(t *testing.T, client *firestore.Client, fixtures map[string]map[string]interface{}) {
+	ctx := context.Background()
+	iter := client.Collection(StationsCollection).Documents(ctx)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		require.NoError(t, err)
+		_, err = doc.Ref.Delete(ctx)
+		require.NoError(t, err)
+	}
+
+	for id, data := range fixtures {
+		_, err := client.Collection(StationsCollection).Doc(id).Set(ctx, data)
+		require.NoError(t, err)
+	}
+}
+
+func fixtureStation(name string, latitude, longitude float64, state int64) map[string]interface{} {
+	return map[string]interface{}{
+		"address":   name + " address",
+		"city":      "臺北市",
+		"district":  "信義區",
+		"location":  name,
+		"latitude":  latitude,
+		"longitude": longitude,
+		"state":     state,
+		"vmType":    int64(1),
+	}
+}
+
+func TestStationRepositoryNearby(t *testing.T) {
+	client := newEmulatorClient(t)
+	repository := NewStationRepository(client, DefaultSearchRadius)
+
+	tests := []struct {
+		name        string
+		fixtures    map[string]map[string]interface{}
+		limit       int
+		expectedIds []string
+	}{
+		{
+			name: "nearest stations first",
+			fixtures: map[string]map[string]interface{}{
+				"far":     fixtureStation("far", centerLatitude+0.03, centerLongitude, ActiveState),
+				"nearest": fixtureStation("nearest", centerLatitude+0.001, centerLongitude, ActiveState),
+				"middle":  fixtureStation("middle", centerLatitude, centerLongitude-0.01, ActiveState),
+				"fourth":  fixtureStation("fourth", centerLatitude-0.031, centerLongitude-0.031, ActiveState),
+			},
+			limit:       3,
+			expectedIds: []string{"nearest", "middle", "far"},
+		},
+		{
+			name: "inactive stations are excluded",
+			fixtures: map[string]map[string]interface{}{
+				"closed": fixtureStation("closed", centerLatitude, centerLongitude, 0),
+				"open":   fixtureStation("open", centerLatitude+0.01, centerLongitude, ActiveState),
+			},
+			limit:       3,
+			expectedIds: []string{"open"},
+		},
+		{
+			name: "fewer stations than the limit",
+			fixtures: map[string]map[string]interface{}{
+				"only": fixtureStation("only", centerLatitude, centerLongitude+0.02, ActiveState),
+			},
+			limit:       3,
+			expectedIds: []string{"only"},
+		},
+		{
+			name:        "no station around",
+			fixtures:    map[string]map[string]interface{}{},
+			limit:       3,
+			expectedIds: []string{},
+		},
+		{
+			name: "stations on the box boundaries are included",
+			fixtures: map[string]map[string]interface{}{
+				"north": fixtureStation("north", centerLatitude+DefaultSearchRadius, centerLongitude, ActiveState),
+				"south": fixtureStation("south", centerLatitude-DefaultSearchRadius, centerLongitude, ActiveState),
+				"east":  fixtureStation("east", centerLatitude, centerLongitude+DefaultSearchRadius, ActiveState),
+				"west":  fixtureStation("west", centerLatitude, centerLongitude-DefaultSearchRadius, ActiveState),
+			},
+			limit:       4,
+			expectedIds: []string{"east", "west", "north", "south"},
+		},
+		{
+			name: "stations outside the box are excluded",
+			fixtures: map[string]map[string]interface{}{
+				"north": fixtureStation("north", centerLatitude+DefaultSearchRadius+0.0001, centerLongitude, ActiveState),
+				"east":  fixtureStation("east", centerLatitude, centerLongitude+DefaultSearchRadius+0.0001, ActiveState),
+				// the corner of the box is further than the radius, but still inside the box
+				"corner": fixtureStation("corner", centerLatitude+DefaultSearchRadius, centerLongitude+DefaultSearchRadius, ActiveState),
+			},
+			limit:       3,
+			expectedIds: []string{"corner"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seedStations(t, client, tt.fixtures)
+
+			stations, err := repository.Nearby(context.Background(), centerLatitude, centerLongitude, tt.limit)
+			require.NoError(t, err)
+
+			ids := []string{}
+			for _, station := range stations {
+				ids = append(ids, station.Id)
+				assert.InDelta(t, Haversine(station.Latitude, station.Longitude, centerLatitude, centerLongitude), station.Distance, 1e-9)
+			}
+			assert.ElementsMatch(t, tt.expectedIds, ids)
+			assert.True(t, sort.SliceIsSorted(stations, func(j, k int) bool {
+				return stations[j].Distance < stations[k].Distance
+			}))
+		})
+	}
+}
+
+func TestStationRepositoryNearbyInvalidDocument(t *testing.T) {
+	client := newEmulatorClient(t)
+	repository := NewStationRepository(client, DefaultSearchRadius)
+
+	broken := fixtureStation("broken", centerLatitude, centerLongitude, ActiveState)
+	delete(broken, "address")
+	seedStations(t, client, map[string]map[string]interface{}{
+		"broken": broken,
+		"valid":  fixtureStation("valid", centerLatitude+0.001, centerLongitude, ActiveState),
+	})
+
+	// the malformed station is left out instead of failing the search
+	stations, err := repository.Nearby(context.Background(), centerLatitude, centerLongitude, 3)
+	assert.NoError(t, err)
+	if assert.Len(t, stations, 1) {
+		assert.Equal(t, "valid", stations[0].Id)
+	}
+}
+
+func TestStationRepositoryGet(t *testing.T) {
+	client := newEmulatorClient(t)
+	repository := NewStationRepository(client, DefaultSearchRadius)
+	seedStations(t, client, map[string]map[string]interface{}{
+		"maintenance": fixtureStation("maintenance", centerLatitude, centerLongitude, 2),
+	})
+
+	station, err := repository.Get(context.Background(), "maintenance")
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance", station.Location)
+	assert.Equal(t, int64(2), station.State)
+
+	_, err = repository.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrStationNotFound)
+}
+
+func TestStationRepositoryUpdate(t *testing.T) {
+	client := newEmulatorClient(t)
+	repository := NewStationRepository(client, DefaultSearchRadius)
+	seedStations(t, client, map[string]map[string]interface{}{})
+	ctx := context.Background()
+
+	station := GoStation{Id: "new", Location: "new", Address: "new address", City: "臺北市", District: "信義區", Latitude: centerLatitude, Longitude: centerLongitude, VMType: 1, State: ActiveState}
+	audit, err := repository.Update(ctx, "new", StationAudit{Action: "put", Actor: "admin@example.com", At: time.Now()}, func(before *GoStation) (*GoStation, error) {
+		assert.Nil(t, before)
+		return &station, nil
+	})
+	require.NoError(t, err)
+	assert.Nil(t, audit.Before)
+	assert.Equal(t, &station, audit.After)
+
+	saved, err := repository.Get(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, station, saved)
+
+	// an error of the change writes nothing
+	_, err = repository.Update(ctx, "new", StationAudit{Action: "patch"}, func(before *GoStation) (*GoStation, error) {
+		return nil, ErrStationNotFound
+	})
+	assert.ErrorIs(t, err, ErrStationNotFound)
+
+	audit, err = repository.Update(ctx, "new", StationAudit{Action: "delete", Actor: "admin@example.com", At: time.Now()}, func(before *GoStation) (*GoStation, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &station, audit.Before)
+	_, err = repository.Get(ctx, "new")
+	assert.ErrorIs(t, err, ErrStationNotFound)
+
+	audits, err := client.Collection(StationAuditsCollection).Where("stationId", "==", "new").Documents(ctx).GetAll()
+	require.NoError(t, err)
+	assert.Len(t, audits, 2)
+}
+
+func TestStationRepositoryAll(t *testing.T) {
+	client := newEmulatorClient(t)
+	repository := NewStationRepository(client, DefaultSearchRadius)
+	seedStations(t, client, map[string]map[string]interface{}{
+		"b-active":      fixtureStation("b-active", centerLatitude, centerLongitude, ActiveState),
+		"a-active":      fixtureStation("a-active", centerLatitude, centerLongitude, ActiveState),
+		"c-maintenance": fixtureStation("c-maintenance", centerLatitude, centerLongitude, 2),
+	})
+
+	stations, err := repository.All(context.Background(), StationFilter{})
+	require.NoError(t, err)
+	assert.Len(t, stations, 3)
+	assert.Equal(t, "a-active", stations[0].Id)
+
+	active := int64(ActiveState)
+	stations, err = repository.All(context.Background(), StationFilter{City: "臺北市", State: &active})
+	require.NoError(t, err)
+	assert.Len(t, stations, 2)
+}
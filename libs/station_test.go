@@ -0,0 +1,90 @@
+package libs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoStationValidate(t *testing.T) {
+	valid := GoStation{
+		Id:        "station-1",
+		Location:  "台北101站",
+		Address:   "台北市信義區信義路五段7號",
+		City:      "臺北市",
+		District:  "信義區",
+		Latitude:  25.033964,
+		Longitude: 121.564468,
+		VMType:    1,
+		State:     ActiveState,
+	}
+
+	tests := []struct {
+		name          string
+		change        func(s *GoStation)
+		expectedError []string
+	}{
+		{
+			name:   "valid station",
+			change: func(s *GoStation) {},
+		},
+		{
+			name: "missing fields",
+			change: func(s *GoStation) {
+				s.Id = "stations/station-1"
+				s.Address = " "
+				s.City = ""
+			},
+			expectedError: []string{"id must be set", "address is required", "city is required"},
+		},
+		{
+			name: "invalid values",
+			change: func(s *GoStation) {
+				s.Latitude = 121.5
+				s.VMType = 0
+				s.State = -1
+			},
+			expectedError: []string{"latitude and longitude", "vmType", "state"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			station := valid
+			tt.change(&station)
+
+			err := station.Validate()
+			if len(tt.expectedError) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			for _, expected := range tt.expectedError {
+				assert.ErrorContains(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestStationFilterMatch(t *testing.T) {
+	station := GoStation{City: "臺北市", District: "信義區", VMType: 3, State: ActiveState}
+	active, offline := int64(ActiveState), int64(2)
+
+	tests := []struct {
+		name     string
+		filter   StationFilter
+		expected bool
+	}{
+		{name: "no filter", filter: StationFilter{}, expected: true},
+		{name: "same city and type", filter: StationFilter{City: "臺北市", VMType: 3}, expected: true},
+		{name: "other district", filter: StationFilter{District: "大安區"}, expected: false},
+		{name: "active", filter: StationFilter{State: &active}, expected: true},
+		{name: "offline", filter: StationFilter{State: &offline}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.Match(station))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package libs
+
+import (
+	"sync"
+	"time"
+)
+
+// StationCache keeps the stations read by ID for ttl, the stations changed through the admin API
+// or seen changing by the stations listener are invalidated right away.
+type StationCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	stations map[string]cachedStation
+}
+
+type cachedStation struct {
+	station   GoStation
+	expiresAt time.Time
+}
+
+func NewStationCache(ttl time.Duration) *StationCache {
+	return &StationCache{
+		ttl:      ttl,
+		now:      time.Now,
+		stations: make(map[string]cachedStation),
+	}
+}
+
+// Get returns the cached station, unless it expired.
+func (c *StationCache) Get(id string) (GoStation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.stations[id]
+	if !ok {
+		return GoStation{}, false
+	}
+
+	if !c.now().Before(cached.expiresAt) {
+		delete(c.stations, id)
+		return GoStation{}, false
+	}
+
+	return cached.station, true
+}
+
+func (c *StationCache) Put(station GoStation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stations[station.Id] = cachedStation{station: station, expiresAt: c.now().Add(c.ttl)}
+}
+
+func (c *StationCache) Invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.stations, id)
+}
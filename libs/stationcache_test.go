@@ -0,0 +1,31 @@
+package libs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationCache(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	cache := NewStationCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	_, ok := cache.Get("station-1")
+	assert.False(t, ok)
+
+	cache.Put(GoStation{Id: "station-1", Location: "Station 1"})
+	station, ok := cache.Get("station-1")
+	assert.True(t, ok)
+	assert.Equal(t, "Station 1", station.Location)
+
+	cache.Invalidate("station-1")
+	_, ok = cache.Get("station-1")
+	assert.False(t, ok)
+
+	cache.Put(GoStation{Id: "station-2"})
+	now = now.Add(time.Minute)
+	_, ok = cache.Get("station-2")
+	assert.False(t, ok, "expired station")
+}
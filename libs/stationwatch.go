@@ -0,0 +1,218 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// maxInValues is the number of values Firestore accepts in an "in" filter.
+const maxInValues = 30
+
+// StationChange is an update of a subscribed station, like a station going offline for maintenance.
+// The stations of the first snapshot of a listener are changes too, whether the subscribers
+// already know of their state is up to the handler.
+type StationChange struct {
+	Station   GoStation
+	UpdatedAt time.Time
+}
+
+// WatchSubscribedStations listens to the stations having subscribers and calls handle for each of
+// their updates, until ctx is done or a listener fails. The subscriptions collection is listened
+// to as well, the stations are listened to in batches and only the batches of the stations
+// subscribed or unsubscribed to start or stop.
+func WatchSubscribedStations(ctx context.Context, client *firestore.Client, subscriptions string, handle func(context.Context, StationChange)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error)
+	fail := func(err error) {
+		select {
+		case errs <- err:
+		case <-ctx.Done():
+		}
+	}
+	subscribed := make(chan []string)
+	go func() {
+		fail(watchSubscribedIds(ctx, client, subscriptions, subscribed))
+	}()
+
+	listen := func(batch []string) context.CancelFunc {
+		refs := make([]*firestore.DocumentRef, 0, len(batch))
+		for _, id := range batch {
+			refs = append(refs, client.Collection(StationsCollection).Doc(id))
+		}
+		query := client.Collection(StationsCollection).Where(firestore.DocumentID, "in", refs)
+
+		listenCtx, stop := context.WithCancel(ctx)
+		go func() {
+			if err := watchStationQuery(listenCtx, query, handle); err != nil {
+				fail(err)
+			}
+		}()
+		return stop
+	}
+
+	// the listeners stop along with ctx
+	var listeners []stationListener
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case ids := <-subscribed:
+			listeners = updateListeners(listeners, ids, listen)
+		}
+	}
+}
+
+// stationListener listens to a batch of the subscribed stations.
+type stationListener struct {
+	ids  []string
+	stop context.CancelFunc
+}
+
+// updateListeners stops the listeners having an unsubscribed station and starts listeners for the
+// subscribed stations left without one, in batches of the "in" filter. The other listeners keep
+// running.
+func updateListeners(listeners []stationListener, ids []string, listen func(batch []string) context.CancelFunc) []stationListener {
+	subscribed := map[string]bool{}
+	for _, id := range ids {
+		subscribed[id] = true
+	}
+
+	listened := map[string]bool{}
+	kept := listeners[:0]
+	for _, listener := range listeners {
+		current := true
+		for _, id := range listener.ids {
+			if !subscribed[id] {
+				current = false
+				break
+			}
+		}
+		if !current {
+			listener.stop()
+			continue
+		}
+
+		for _, id := range listener.ids {
+			listened[id] = true
+		}
+		kept = append(kept, listener)
+	}
+
+	var unlistened []string
+	for _, id := range ids {
+		if !listened[id] {
+			unlistened = append(unlistened, id)
+		}
+	}
+	for _, batch := range chunkIds(unlistened, maxInValues) {
+		kept = append(kept, stationListener{ids: batch, stop: listen(batch)})
+	}
+
+	return kept
+}
+
+// watchSubscribedIds sends the IDs of the subscribed stations each time they change.
+func watchSubscribedIds(ctx context.Context, client *firestore.Client, subscriptions string, subscribed chan<- []string) error {
+	snapshots := client.Collection(subscriptions).Snapshots(ctx)
+	defer snapshots.Stop()
+
+	var last []string
+	for {
+		snapshot, err := snapshots.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to listen to subscriptions: %v", err)
+		}
+
+		docs, err := snapshot.Documents.GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to read subscriptions: %v", err)
+		}
+
+		seen := map[string]bool{}
+		ids := []string{}
+		for _, doc := range docs {
+			id, _ := doc.Data()["stationId"].(string)
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+
+		if last != nil && equalIds(last, ids) {
+			continue
+		}
+		last = ids
+
+		select {
+		case subscribed <- ids:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func watchStationQuery(ctx context.Context, query firestore.Query, handle func(context.Context, StationChange)) error {
+	snapshots := query.Snapshots(ctx)
+	defer snapshots.Stop()
+
+	for {
+		snapshot, err := snapshots.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to listen to stations: %v", err)
+		}
+
+		for _, change := range snapshot.Changes {
+			if change.Kind == firestore.DocumentRemoved {
+				continue
+			}
+
+			station, err := stationFromDocument(change.Doc)
+			if err != nil {
+				LoggerFromContext(ctx).Warn("skip invalid station", "error", err)
+				continue
+			}
+
+			handle(ctx, StationChange{Station: station, UpdatedAt: change.Doc.UpdateTime})
+		}
+	}
+}
+
+// chunkIds splits the IDs into batches of at most size IDs.
+func chunkIds(ids []string, size int) [][]string {
+	var chunks [][]string
+	for len(ids) > size {
+		chunks = append(chunks, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+
+	return chunks
+}
+
+func equalIds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
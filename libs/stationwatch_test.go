@@ -0,0 +1,79 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkIds(t *testing.T) {
+	ids := make([]string, 65)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("station-%d", i)
+	}
+
+	tests := []struct {
+		name          string
+		ids           []string
+		expectedSizes []int
+	}{
+		{name: "no station", ids: []string{}},
+		{name: "a single batch", ids: ids[:maxInValues], expectedSizes: []int{maxInValues}},
+		{name: "several batches", ids: ids, expectedSizes: []int{maxInValues, maxInValues, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizes := []int{}
+			for _, chunk := range chunkIds(tt.ids, maxInValues) {
+				sizes = append(sizes, len(chunk))
+			}
+			if tt.expectedSizes == nil {
+				tt.expectedSizes = []int{}
+			}
+			assert.Equal(t, tt.expectedSizes, sizes)
+		})
+	}
+}
+
+func TestUpdateListeners(t *testing.T) {
+	var started [][]string
+	stopped := map[string]bool{}
+	listen := func(batch []string) context.CancelFunc {
+		started = append(started, batch)
+		key := strings.Join(batch, ",")
+		return func() { stopped[key] = true }
+	}
+
+	ids := make([]string, 35)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("station-%02d", i)
+	}
+
+	listeners := updateListeners(nil, ids[:32], listen)
+	assert.Equal(t, [][]string{ids[:30], ids[30:32]}, started)
+
+	// a new subscribed station gets a listener, the others keep listening
+	started = nil
+	listeners = updateListeners(listeners, ids[:33], listen)
+	assert.Equal(t, [][]string{ids[32:33]}, started)
+	assert.Empty(t, stopped)
+
+	// the listener of an unsubscribed station starts over with the stations still subscribed
+	started = nil
+	listeners = updateListeners(listeners, append([]string{ids[0]}, ids[2:33]...), listen)
+	assert.Equal(t, map[string]bool{strings.Join(ids[:30], ","): true}, stopped)
+	assert.Equal(t, [][]string{append([]string{ids[0]}, ids[2:30]...)}, started)
+	assert.Len(t, listeners, 3)
+
+	// the listeners of the stations without subscribers stop
+	stopped = map[string]bool{}
+	started = nil
+	listeners = updateListeners(listeners, []string{}, listen)
+	assert.Len(t, stopped, 3)
+	assert.Empty(t, started)
+	assert.Empty(t, listeners)
+}
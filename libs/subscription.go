@@ -0,0 +1,195 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Subscription is a user subscribed to the state changes of a station, through a channel.
+type Subscription struct {
+	UserId    string
+	StationId string
+	Channel   string
+	// NotifiedState is the state of the station the user knows of, from the subscription or the
+	// last notification. 0 when it isn't known yet.
+	NotifiedState int64
+}
+
+// SubscriptionStore keeps the stations users subscribed to.
+type SubscriptionStore interface {
+	Subscribe(ctx context.Context, subscription Subscription) error
+	Unsubscribe(ctx context.Context, userId, stationId string) error
+	// Subscribers returns the subscriptions to the station.
+	Subscribers(ctx context.Context, stationId string) ([]Subscription, error)
+	// Subscriptions returns the subscriptions of the user.
+	Subscriptions(ctx context.Context, userId string) ([]Subscription, error)
+	// Notified records the state of the station the user was told of.
+	Notified(ctx context.Context, userId, stationId string, state int64) error
+}
+
+// FirestoreSubscriptionStore keeps a document per subscription, its ID combines the station and
+// the user so subscribing twice keeps a single document.
+type FirestoreSubscriptionStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+func NewFirestoreSubscriptionStore(client *firestore.Client, collection string) *FirestoreSubscriptionStore {
+	return &FirestoreSubscriptionStore{
+		client:     client,
+		collection: collection,
+	}
+}
+
+func (s *FirestoreSubscriptionStore) Subscribe(ctx context.Context, subscription Subscription) error {
+	_, err := s.client.Collection(s.collection).Doc(subscriptionId(subscription.UserId, subscription.StationId)).Set(ctx, map[string]interface{}{
+		"userId":        subscription.UserId,
+		"stationId":     subscription.StationId,
+		"channel":       subscription.Channel,
+		"notifiedState": subscription.NotifiedState,
+		"createdAt":     time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %v", err)
+	}
+
+	return nil
+}
+
+func (s *FirestoreSubscriptionStore) Unsubscribe(ctx context.Context, userId, stationId string) error {
+	_, err := s.client.Collection(s.collection).Doc(subscriptionId(userId, stationId)).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %v", err)
+	}
+
+	return nil
+}
+
+// Notified updates the subscription, the user may have unsubscribed meanwhile.
+func (s *FirestoreSubscriptionStore) Notified(ctx context.Context, userId, stationId string, state int64) error {
+	_, err := s.client.Collection(s.collection).Doc(subscriptionId(userId, stationId)).Update(ctx, []firestore.Update{
+		{Path: "notifiedState", Value: state},
+	})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to update subscription: %v", err)
+	}
+
+	return nil
+}
+
+func (s *FirestoreSubscriptionStore) Subscribers(ctx context.Context, stationId string) ([]Subscription, error) {
+	return s.query(ctx, s.client.Collection(s.collection).Where("stationId", "==", stationId))
+}
+
+func (s *FirestoreSubscriptionStore) Subscriptions(ctx context.Context, userId string) ([]Subscription, error) {
+	return s.query(ctx, s.client.Collection(s.collection).Where("userId", "==", userId))
+}
+
+func (s *FirestoreSubscriptionStore) query(ctx context.Context, query firestore.Query) ([]Subscription, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	subscriptions := []Subscription{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate subscriptions: %v", err)
+		}
+
+		var subscription struct {
+			UserId        string `firestore:"userId"`
+			StationId     string `firestore:"stationId"`
+			Channel       string `firestore:"channel"`
+			NotifiedState int64  `firestore:"notifiedState"`
+		}
+		if err := doc.DataTo(&subscription); err != nil {
+			return nil, fmt.Errorf("subscription %s is invalid: %v", doc.Ref.ID, err)
+		}
+		subscriptions = append(subscriptions, Subscription(subscription))
+	}
+
+	return subscriptions, nil
+}
+
+// subscriptionId is the document ID of a subscription, Firestore IDs can't contain slashes.
+func subscriptionId(userId, stationId string) string {
+	return fmt.Sprintf("%s_%s", stationId, userId)
+}
+
+// MemorySubscriptionStore keeps the subscriptions in memory. It is meant for tests and local runs.
+type MemorySubscriptionStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+}
+
+func NewMemorySubscriptionStore() *MemorySubscriptionStore {
+	return &MemorySubscriptionStore{
+		subscriptions: make(map[string]Subscription),
+	}
+}
+
+func (s *MemorySubscriptionStore) Subscribe(ctx context.Context, subscription Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriptions[subscriptionId(subscription.UserId, subscription.StationId)] = subscription
+	return nil
+}
+
+func (s *MemorySubscriptionStore) Unsubscribe(ctx context.Context, userId, stationId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscriptions, subscriptionId(userId, stationId))
+	return nil
+}
+
+func (s *MemorySubscriptionStore) Notified(ctx context.Context, userId, stationId string, state int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := subscriptionId(userId, stationId)
+	if subscription, ok := s.subscriptions[id]; ok {
+		subscription.NotifiedState = state
+		s.subscriptions[id] = subscription
+	}
+	return nil
+}
+
+func (s *MemorySubscriptionStore) Subscribers(ctx context.Context, stationId string) ([]Subscription, error) {
+	return s.filter(func(subscription Subscription) bool { return subscription.StationId == stationId }), nil
+}
+
+func (s *MemorySubscriptionStore) Subscriptions(ctx context.Context, userId string) ([]Subscription, error) {
+	return s.filter(func(subscription Subscription) bool { return subscription.UserId == userId }), nil
+}
+
+func (s *MemorySubscriptionStore) filter(match func(Subscription) bool) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscriptions := []Subscription{}
+	for _, subscription := range s.subscriptions {
+		if match(subscription) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+
+	sort.Slice(subscriptions, func(j, k int) bool {
+		return subscriptionId(subscriptions[j].UserId, subscriptions[j].StationId) < subscriptionId(subscriptions[k].UserId, subscriptions[k].StationId)
+	})
+
+	return subscriptions
+}
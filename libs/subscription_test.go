@@ -0,0 +1,35 @@
+package libs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemorySubscriptionStore(t *testing.T) {
+	store := NewMemorySubscriptionStore()
+	ctx := context.TODO()
+
+	assert.NoError(t, store.Subscribe(ctx, Subscription{UserId: "user-a", StationId: "station-1", Channel: "production"}))
+	assert.NoError(t, store.Subscribe(ctx, Subscription{UserId: "user-a", StationId: "station-1", Channel: "production"}))
+	assert.NoError(t, store.Subscribe(ctx, Subscription{UserId: "user-b", StationId: "station-1", Channel: "partner"}))
+	assert.NoError(t, store.Subscribe(ctx, Subscription{UserId: "user-a", StationId: "station-2", Channel: "production"}))
+
+	subscribers, _ := store.Subscribers(ctx, "station-1")
+	assert.Equal(t, []Subscription{
+		{UserId: "user-a", StationId: "station-1", Channel: "production"},
+		{UserId: "user-b", StationId: "station-1", Channel: "partner"},
+	}, subscribers)
+
+	// the notified state is kept per subscriber, an unsubscribed user is left alone
+	assert.NoError(t, store.Notified(ctx, "user-b", "station-1", 2))
+	assert.NoError(t, store.Notified(ctx, "user-c", "station-1", 2))
+	subscribers, _ = store.Subscribers(ctx, "station-1")
+	assert.Equal(t, []int64{0, 2}, []int64{subscribers[0].NotifiedState, subscribers[1].NotifiedState})
+	assert.Len(t, subscribers, 2)
+
+	assert.NoError(t, store.Unsubscribe(ctx, "user-a", "station-1"))
+	subscriptions, _ := store.Subscriptions(ctx, "user-a")
+	assert.Equal(t, []Subscription{{UserId: "user-a", StationId: "station-2", Channel: "production"}}, subscriptions)
+}
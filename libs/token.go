@@ -0,0 +1,239 @@
+package libs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry a short-lived token is replaced.
+const tokenRefreshMargin = 24 * time.Hour
+
+// TokenSource fetches a channel access token. The expiry is zero when the token doesn't expire.
+type TokenSource interface {
+	Token(ctx context.Context) (token string, expiresAt time.Time, err error)
+}
+
+// SecretTokenSource reads the token from a secret, so a token rotated in the secret store is
+// picked up on the next refresh.
+type SecretTokenSource struct {
+	Secrets SecretProvider
+	Name    string
+}
+
+func (s SecretTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	token, err := s.Secrets.Secret(ctx, s.Name)
+	return token, time.Time{}, err
+}
+
+// StaticTokenSource always returns the same token.
+type StaticTokenSource string
+
+func (s StaticTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	return string(s), time.Time{}, nil
+}
+
+// ChannelTokenSource issues short-lived channel access tokens from the LINE OAuth endpoint,
+// using the channel ID and secret.
+type ChannelTokenSource struct {
+	Endpoint      string
+	ChannelId     string
+	ChannelSecret string
+	Client        *http.Client
+}
+
+func (s ChannelTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.ChannelId},
+		"client_secret": {s.ChannelSecret},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to issue channel access token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, &HTTPError{StatusCode: resp.StatusCode, Body: body}
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", time.Time{}, fmt.Errorf("unable to decode channel access token: %v", err)
+	}
+
+	return result.AccessToken, time.Now().Add(time.Duration(result.ExpiresIn) * time.Second), nil
+}
+
+// TokenManager caches the channel access token for the handlers, and replaces it periodically,
+// when it's about to expire, or when LINE rejects it.
+type TokenManager struct {
+	source   TokenSource
+	interval time.Duration
+
+	mu        sync.RWMutex
+	token     string
+	expiresAt time.Time
+	// refreshing serialises the fetches, so concurrent handlers don't issue several tokens
+	refreshing sync.Mutex
+	now        func() time.Time
+}
+
+func NewTokenManager(source TokenSource, interval time.Duration) *TokenManager {
+	return &TokenManager{
+		source:   source,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Token returns the cached token, fetching a new one when there is none or it has expired.
+func (m *TokenManager) Token(ctx context.Context) (string, error) {
+	m.mu.RLock()
+	token, expiresAt := m.token, m.expiresAt
+	m.mu.RUnlock()
+
+	if token != "" && (expiresAt.IsZero() || m.now().Before(expiresAt)) {
+		return token, nil
+	}
+
+	return m.refresh(ctx, token)
+}
+
+// Valid checks the cached token without fetching one, it fails when there is none, like after LINE
+// rejected it, or it has expired. The probes can call it as often as they like, unlike Token it
+// never issues a token.
+func (m *TokenManager) Valid() error {
+	m.mu.RLock()
+	token, expiresAt := m.token, m.expiresAt
+	m.mu.RUnlock()
+
+	if token == "" {
+		return errors.New("channel access token is not loaded")
+	}
+	if !expiresAt.IsZero() && !m.now().Before(expiresAt) {
+		return fmt.Errorf("channel access token expired at %s", expiresAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Invalidate drops the token after LINE rejected it, the next call to Token fetches a new one.
+// A token already replaced by another handler is left alone.
+func (m *TokenManager) Invalidate(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token == token {
+		m.token = ""
+	}
+}
+
+// Authorized calls do with the token. When LINE rejects the token, like after it was rotated, the
+// token is invalidated and do is called once more with a new one.
+func (m *TokenManager) Authorized(ctx context.Context, do func(token string) error) error {
+	for attempt := 1; ; attempt++ {
+		token, err := m.Token(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = do(token)
+
+		var httpErr *HTTPError
+		if attempt == 1 && errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized {
+			LoggerFromContext(ctx).Warn("LINE rejected the channel access token, refreshing it")
+			m.Invalidate(token)
+			continue
+		}
+
+		return err
+	}
+}
+
+// Run refreshes the token every interval until ctx is done. Tokens read from a secret are
+// always fetched again, short-lived tokens only when they are about to expire.
+func (m *TokenManager) Run(ctx context.Context) {
+	if m.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.refreshIfStale(ctx); err != nil {
+				LoggerFromContext(ctx).Error("failed to refresh channel access token", "error", err)
+			}
+		}
+	}
+}
+
+func (m *TokenManager) refreshIfStale(ctx context.Context) error {
+	m.mu.RLock()
+	token, expiresAt := m.token, m.expiresAt
+	m.mu.RUnlock()
+
+	if token != "" && !expiresAt.IsZero() && m.now().Add(tokenRefreshMargin).Before(expiresAt) {
+		return nil
+	}
+
+	_, err := m.refresh(ctx, token)
+	return err
+}
+
+// refresh fetches a new token, unless another caller already replaced the stale one.
+func (m *TokenManager) refresh(ctx context.Context, stale string) (string, error) {
+	m.refreshing.Lock()
+	defer m.refreshing.Unlock()
+
+	m.mu.RLock()
+	current := m.token
+	m.mu.RUnlock()
+	if current != "" && current != stale {
+		return current, nil
+	}
+
+	token, expiresAt, err := m.source.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch channel access token: %v", err)
+	}
+
+	m.mu.Lock()
+	m.token, m.expiresAt = token, expiresAt
+	m.mu.Unlock()
+
+	return token, nil
+}
@@ -0,0 +1,196 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTokenSource returns token-1, token-2... on each fetch.
+type countingTokenSource struct {
+	mu        sync.Mutex
+	fetches   int
+	expiresAt time.Time
+	err       error
+}
+
+func (s *countingTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return "", time.Time{}, s.err
+	}
+	s.fetches++
+	return fmt.Sprintf("token-%d", s.fetches), s.expiresAt, nil
+}
+
+func TestTokenManager(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("token is cached", func(t *testing.T) {
+		source := &countingTokenSource{}
+		manager := NewTokenManager(source, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			token, err := manager.Token(context.TODO())
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token)
+		}
+		assert.Equal(t, 1, source.fetches)
+	})
+
+	t.Run("invalidated token is fetched again once", func(t *testing.T) {
+		source := &countingTokenSource{}
+		manager := NewTokenManager(source, time.Hour)
+
+		token, _ := manager.Token(context.TODO())
+		manager.Invalidate(token)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := manager.Token(context.TODO())
+				assert.NoError(t, err)
+				assert.Equal(t, "token-2", token)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 2, source.fetches)
+
+		// a handler still holding the old token doesn't drop the new one
+		manager.Invalidate("token-1")
+		token, _ = manager.Token(context.TODO())
+		assert.Equal(t, "token-2", token)
+	})
+
+	t.Run("expired token is replaced", func(t *testing.T) {
+		source := &countingTokenSource{expiresAt: now.Add(time.Hour)}
+		manager := NewTokenManager(source, time.Hour)
+		manager.now = func() time.Time { return now }
+
+		token, _ := manager.Token(context.TODO())
+		assert.Equal(t, "token-1", token)
+
+		manager.now = func() time.Time { return now.Add(2 * time.Hour) }
+		token, _ = manager.Token(context.TODO())
+		assert.Equal(t, "token-2", token)
+	})
+
+	t.Run("periodic refresh", func(t *testing.T) {
+		source := &countingTokenSource{}
+		manager := NewTokenManager(source, time.Hour)
+		manager.now = func() time.Time { return now }
+		manager.Token(context.TODO())
+
+		// tokens read from a secret are always fetched again
+		assert.NoError(t, manager.refreshIfStale(context.TODO()))
+		assert.Equal(t, 2, source.fetches)
+
+		// short-lived tokens are kept until they are about to expire
+		source.expiresAt = now.Add(30 * 24 * time.Hour)
+		manager.Invalidate("token-2")
+		manager.Token(context.TODO())
+		assert.NoError(t, manager.refreshIfStale(context.TODO()))
+		assert.Equal(t, 3, source.fetches)
+
+		manager.now = func() time.Time { return now.Add(30*24*time.Hour - time.Hour) }
+		assert.NoError(t, manager.refreshIfStale(context.TODO()))
+		assert.Equal(t, 4, source.fetches)
+	})
+
+	t.Run("validity of the cached token", func(t *testing.T) {
+		source := &countingTokenSource{expiresAt: now.Add(time.Hour)}
+		manager := NewTokenManager(source, time.Hour)
+		manager.now = func() time.Time { return now }
+		assert.ErrorContains(t, manager.Valid(), "not loaded")
+
+		token, _ := manager.Token(context.TODO())
+		assert.NoError(t, manager.Valid())
+
+		manager.now = func() time.Time { return now.Add(time.Hour) }
+		assert.ErrorContains(t, manager.Valid(), "expired")
+
+		manager.now = func() time.Time { return now }
+		manager.Invalidate(token)
+		assert.ErrorContains(t, manager.Valid(), "not loaded")
+
+		// checking the token never fetches one
+		assert.Equal(t, 1, source.fetches)
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		manager := NewTokenManager(&countingTokenSource{err: fmt.Errorf("secret not found")}, time.Hour)
+
+		_, err := manager.Token(context.TODO())
+		assert.ErrorContains(t, err, "failed to fetch channel access token: secret not found")
+	})
+}
+
+func TestTokenManagerAuthorized(t *testing.T) {
+	tests := []struct {
+		name            string
+		rejected        map[string]bool
+		expectedTokens  []string
+		expectedFetches int
+		expectedError   string
+	}{
+		{name: "accepted token", expectedTokens: []string{"token-1"}, expectedFetches: 1},
+		{name: "rotated token", rejected: map[string]bool{"token-1": true}, expectedTokens: []string{"token-1", "token-2"}, expectedFetches: 2},
+		{name: "retried once", rejected: map[string]bool{"token-1": true, "token-2": true}, expectedTokens: []string{"token-1", "token-2"}, expectedFetches: 2, expectedError: "401"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &countingTokenSource{}
+			manager := NewTokenManager(source, time.Hour)
+
+			tokens := []string{}
+			err := manager.Authorized(context.TODO(), func(token string) error {
+				tokens = append(tokens, token)
+				if tt.rejected[token] {
+					return &HTTPError{StatusCode: http.StatusUnauthorized}
+				}
+				return nil
+			})
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedTokens, tokens)
+			assert.Equal(t, tt.expectedFetches, source.fetches)
+		})
+	}
+}
+
+func TestChannelTokenSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_id") != "1234" || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"short-lived","expires_in":2592000,"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	source := ChannelTokenSource{Endpoint: server.URL, ChannelId: "1234", ChannelSecret: "secret"}
+	token, expiresAt, err := source.Token(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "short-lived", token)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), expiresAt, time.Minute)
+
+	source.ChannelSecret = "wrong"
+	_, _, err = source.Token(context.TODO())
+	assert.ErrorContains(t, err, "unexpected status 400")
+}
@@ -0,0 +1,131 @@
+package libs
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CloudTraceHeader is the trace header set by Cloud Run on incoming requests.
+const CloudTraceHeader = "X-Cloud-Trace-Context"
+
+// Tracer creates the spans of the service from the global TracerProvider.
+var Tracer = otel.Tracer("ohohestudio/sogorro")
+
+// SetupTracing installs the global TracerProvider and propagators. The exporter is one of:
+//   - otlp: OTLP/HTTP, configured by the standard OTEL_EXPORTER_OTLP_* variables
+//   - stdout or console: pretty printed spans written to w, for local runs
+//   - none or empty: spans are only used to correlate logs and propagate the trace
+func SetupTracing(ctx context.Context, exporter string, w io.Writer) (*sdktrace.TracerProvider, error) {
+	var options []sdktrace.TracerProviderOption
+	switch exporter {
+	case "otlp":
+		otlpExporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP trace exporter: %v", err)
+		}
+		options = append(options, sdktrace.WithBatcher(otlpExporter))
+	case "stdout", "console":
+		stdoutExporter, err := stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout trace exporter: %v", err)
+		}
+		options = append(options, sdktrace.WithSyncer(stdoutExporter))
+	case "none", "":
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %q, use otlp, stdout or none", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		CloudTraceContext{},
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider, nil
+}
+
+// CloudTraceContext propagates the trace through the X-Cloud-Trace-Context header, formatted as
+// TRACE_ID/SPAN_ID;o=OPTIONS with a hex trace ID and a decimal span ID.
+type CloudTraceContext struct{}
+
+func (CloudTraceContext) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	spanId := sc.SpanID()
+	sampled := 0
+	if sc.IsSampled() {
+		sampled = 1
+	}
+
+	carrier.Set(CloudTraceHeader, fmt.Sprintf("%s/%d;o=%d", sc.TraceID(), binary.BigEndian.Uint64(spanId[:]), sampled))
+}
+
+func (CloudTraceContext) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	header := carrier.Get(CloudTraceHeader)
+	traceHex, rest, found := strings.Cut(header, "/")
+	if !found {
+		return ctx
+	}
+
+	traceId, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return ctx
+	}
+
+	spanDec, options, _ := strings.Cut(rest, ";")
+	spanNum, err := strconv.ParseUint(spanDec, 10, 64)
+	if err != nil || spanNum == 0 {
+		return ctx
+	}
+
+	spanId, err := trace.SpanIDFromHex(fmt.Sprintf("%016x", spanNum))
+	if err != nil {
+		return ctx
+	}
+
+	var flags trace.TraceFlags
+	if options == "o=1" {
+		flags = trace.FlagsSampled
+	}
+
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+func (CloudTraceContext) Fields() []string {
+	return []string{CloudTraceHeader}
+}
+
+// SpanAttrs returns the Cloud Logging trace and span fields of the span carried by ctx, or nil.
+func SpanAttrs(ctx context.Context, projectId string) []any {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || projectId == "" {
+		return nil
+	}
+
+	return []any{
+		slog.String(TraceKey, fmt.Sprintf("projects/%s/traces/%s", projectId, sc.TraceID())),
+		slog.String(SpanIdKey, sc.SpanID().String()),
+	}
+}
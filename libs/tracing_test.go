@@ -0,0 +1,84 @@
+package libs
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCloudTraceContext(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		expectedValid bool
+		expectedTrace string
+		expectedSpan  string
+		sampled       bool
+	}{
+		{
+			name:          "sampled trace",
+			header:        "105445aa7843bc8bf206b12000100000/1;o=1",
+			expectedValid: true,
+			expectedTrace: "105445aa7843bc8bf206b12000100000",
+			expectedSpan:  "0000000000000001",
+			sampled:       true,
+		},
+		{
+			name:          "trace without options",
+			header:        "105445aa7843bc8bf206b12000100000/18446744073709551615",
+			expectedValid: true,
+			expectedTrace: "105445aa7843bc8bf206b12000100000",
+			expectedSpan:  "ffffffffffffffff",
+		},
+		{name: "missing span", header: "105445aa7843bc8bf206b12000100000", expectedValid: false},
+		{name: "invalid trace", header: "not-a-trace/1;o=1", expectedValid: false},
+		{name: "missing header", header: "", expectedValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carrier := propagation.HeaderCarrier(http.Header{})
+			carrier.Set(CloudTraceHeader, tt.header)
+
+			ctx := CloudTraceContext{}.Extract(context.TODO(), carrier)
+			sc := trace.SpanContextFromContext(ctx)
+			assert.Equal(t, tt.expectedValid, sc.IsValid())
+			if !tt.expectedValid {
+				return
+			}
+
+			assert.Equal(t, tt.expectedTrace, sc.TraceID().String())
+			assert.Equal(t, tt.expectedSpan, sc.SpanID().String())
+			assert.Equal(t, tt.sampled, sc.IsSampled())
+
+			// injecting the extracted context gives back the header
+			injected := propagation.HeaderCarrier(http.Header{})
+			CloudTraceContext{}.Inject(ctx, injected)
+			sampled := "0"
+			if tt.sampled {
+				sampled = "1"
+			}
+			assert.Equal(t, tt.header[:len(tt.expectedTrace)], injected.Get(CloudTraceHeader)[:len(tt.expectedTrace)])
+			assert.Contains(t, injected.Get(CloudTraceHeader), ";o="+sampled)
+		})
+	}
+}
+
+func TestSetupTracing(t *testing.T) {
+	var buf bytes.Buffer
+	provider, err := SetupTracing(context.TODO(), "stdout", &buf)
+	assert.NoError(t, err)
+
+	_, span := provider.Tracer("test").Start(context.TODO(), "stations.Nearby")
+	span.End()
+	assert.NoError(t, provider.Shutdown(context.TODO()))
+	assert.Contains(t, buf.String(), "stations.Nearby")
+
+	_, err = SetupTracing(context.TODO(), "zipkin", &buf)
+	assert.ErrorContains(t, err, "unsupported trace exporter")
+}
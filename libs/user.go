@@ -0,0 +1,183 @@
+package libs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UsersCollection keeps a document per user, named after the user ID.
+const UsersCollection = "users"
+
+// UserSearch is the city and district of the nearest station found by the last search of a user,
+// the searched location is not kept.
+type UserSearch struct {
+	UserId     string    `firestore:"-"`
+	Channel    string    `firestore:"channel"`
+	City       string    `firestore:"city"`
+	District   string    `firestore:"district"`
+	SearchedAt time.Time `firestore:"searchedAt"`
+}
+
+// Preferences are the settings chosen by a user, the zero value is the default settings.
+type Preferences struct {
+	// Navigation is the name of the navigation app of the 立即前往 button.
+	Navigation string `firestore:"navigation"`
+}
+
+// UserStore keeps what is known about the users, like their last search used to target
+// announcements to a city.
+type UserStore interface {
+	RecordSearch(ctx context.Context, search UserSearch) error
+	// UsersInCity returns the last searches of the users who last searched in the city.
+	UsersInCity(ctx context.Context, city string) ([]UserSearch, error)
+	SavePreferences(ctx context.Context, userId string, preferences Preferences) error
+	// Preferences returns the preferences of the user, the default ones for a new user.
+	Preferences(ctx context.Context, userId string) (Preferences, error)
+}
+
+// FirestoreUserStore keeps the last search and the preferences under the lastSearch and preferences
+// fields of the user document, the other fields of the document are left untouched.
+type FirestoreUserStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+func NewFirestoreUserStore(client *firestore.Client, collection string) *FirestoreUserStore {
+	return &FirestoreUserStore{
+		client:     client,
+		collection: collection,
+	}
+}
+
+func (s *FirestoreUserStore) RecordSearch(ctx context.Context, search UserSearch) error {
+	// lastSearch is replaced as a whole, dropping the location recorded by earlier versions
+	_, err := s.client.Collection(s.collection).Doc(search.UserId).Set(ctx, map[string]interface{}{
+		"lastSearch": search,
+	}, firestore.Merge([]string{"lastSearch"}))
+	if err != nil {
+		return fmt.Errorf("failed to record user search: %v", err)
+	}
+
+	return nil
+}
+
+func (s *FirestoreUserStore) UsersInCity(ctx context.Context, city string) ([]UserSearch, error) {
+	iter := s.client.Collection(s.collection).Where("lastSearch.city", "==", city).Documents(ctx)
+	defer iter.Stop()
+
+	searches := []UserSearch{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate users: %v", err)
+		}
+
+		var user struct {
+			LastSearch UserSearch `firestore:"lastSearch"`
+		}
+		if err := doc.DataTo(&user); err != nil {
+			return nil, fmt.Errorf("user %s is invalid: %v", doc.Ref.ID, err)
+		}
+		user.LastSearch.UserId = doc.Ref.ID
+		searches = append(searches, user.LastSearch)
+	}
+
+	return searches, nil
+}
+
+func (s *FirestoreUserStore) SavePreferences(ctx context.Context, userId string, preferences Preferences) error {
+	_, err := s.client.Collection(s.collection).Doc(userId).Set(ctx, map[string]interface{}{
+		"preferences": preferences,
+	}, firestore.MergeAll)
+	if err != nil {
+		return fmt.Errorf("failed to save user preferences: %v", err)
+	}
+
+	return nil
+}
+
+func (s *FirestoreUserStore) Preferences(ctx context.Context, userId string) (Preferences, error) {
+	doc, err := s.client.Collection(s.collection).Doc(userId).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return Preferences{}, nil
+	}
+	if err != nil {
+		return Preferences{}, fmt.Errorf("failed to get user %s: %v", userId, err)
+	}
+
+	var user struct {
+		Preferences Preferences `firestore:"preferences"`
+	}
+	if err := doc.DataTo(&user); err != nil {
+		return Preferences{}, fmt.Errorf("user %s is invalid: %v", userId, err)
+	}
+
+	return user.Preferences, nil
+}
+
+// MemoryUserStore keeps the users in memory. It is meant for tests and local runs.
+type MemoryUserStore struct {
+	mu          sync.Mutex
+	searches    map[string]UserSearch
+	preferences map[string]Preferences
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		searches:    make(map[string]UserSearch),
+		preferences: make(map[string]Preferences),
+	}
+}
+
+func (s *MemoryUserStore) RecordSearch(ctx context.Context, search UserSearch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.searches[search.UserId] = search
+
+	return nil
+}
+
+func (s *MemoryUserStore) UsersInCity(ctx context.Context, city string) ([]UserSearch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	searches := []UserSearch{}
+	for _, search := range s.searches {
+		if search.City == city {
+			searches = append(searches, search)
+		}
+	}
+
+	sort.Slice(searches, func(j, k int) bool { return searches[j].UserId < searches[k].UserId })
+
+	return searches, nil
+}
+
+func (s *MemoryUserStore) SavePreferences(ctx context.Context, userId string, preferences Preferences) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.preferences[userId] = preferences
+
+	return nil
+}
+
+func (s *MemoryUserStore) Preferences(ctx context.Context, userId string) (Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.preferences[userId], nil
+}
@@ -0,0 +1,39 @@
+package libs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryUserStore(t *testing.T) {
+	store := NewMemoryUserStore()
+	ctx := context.TODO()
+
+	assert.NoError(t, store.RecordSearch(ctx, UserSearch{UserId: "user-b", City: "臺北市"}))
+	assert.NoError(t, store.RecordSearch(ctx, UserSearch{UserId: "user-a", City: "新北市"}))
+	assert.NoError(t, store.RecordSearch(ctx, UserSearch{UserId: "user-c", City: "臺中市"}))
+	// the last search replaces the previous one
+	assert.NoError(t, store.RecordSearch(ctx, UserSearch{UserId: "user-a", City: "臺北市"}))
+
+	users, err := store.UsersInCity(ctx, "臺北市")
+	assert.NoError(t, err)
+	assert.Equal(t, []UserSearch{{UserId: "user-a", City: "臺北市"}, {UserId: "user-b", City: "臺北市"}}, users)
+
+	users, _ = store.UsersInCity(ctx, "高雄市")
+	assert.Empty(t, users)
+}
+
+func TestMemoryUserPreferences(t *testing.T) {
+	store := NewMemoryUserStore()
+	ctx := context.TODO()
+
+	preferences, err := store.Preferences(ctx, "user-a")
+	assert.NoError(t, err)
+	assert.Equal(t, Preferences{}, preferences)
+
+	assert.NoError(t, store.SavePreferences(ctx, "user-a", Preferences{Navigation: AppleMapsNavigation}))
+	preferences, _ = store.Preferences(ctx, "user-a")
+	assert.Equal(t, AppleMapsNavigation, preferences.Navigation)
+}
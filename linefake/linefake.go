@@ -0,0 +1,528 @@
+// Package linefake emulates the parts of the LINE Messaging API used by sogorro, so whole
+// conversations can be tested offline.
+//
+// The fake records every message the bot sends, rejects requests with a wrong access token or
+// an invalid payload the way the real platform does, and signs webhook events with the channel
+// secret before delivering them to the bot.
+package linefake
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/libs"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Limits of the Messaging API checked by the fake.
+const (
+	maxMessages        = 5
+	maxTextLength      = 5000
+	maxAltTextLength   = 1500
+	maxQuickReplyItems = 13
+	maxRecipients      = 500
+)
+
+// Broadcast is the recipient of the messages broadcast to all the followers.
+const Broadcast = "*"
+
+// Message is a message sent by the bot, along with the endpoint and recipient it was sent to.
+type Message struct {
+	Endpoint   string
+	To         string
+	ReplyToken string
+	Type       string
+	Text       string
+	AltText    string
+	Raw        map[string]interface{}
+}
+
+// Rejection is a request the fake refused, with the reason returned to the bot.
+type Rejection struct {
+	Path       string
+	StatusCode int
+	Reason     string
+}
+
+type Profile struct {
+	UserId        string `json:"userId"`
+	DisplayName   string `json:"displayName"`
+	PictureUrl    string `json:"pictureUrl,omitempty"`
+	StatusMessage string `json:"statusMessage,omitempty"`
+	Language      string `json:"language,omitempty"`
+}
+
+type Server struct {
+	*httptest.Server
+	AccessToken   string
+	ChannelSecret string
+	Destination   string
+
+	mu          sync.Mutex
+	messages    []Message
+	rejections  []Rejection
+	profiles    map[string]Profile
+	replyTokens map[string]string
+	eventSeq    int
+	quota       int64
+	pushes      int64
+}
+
+// New starts a fake LINE platform accepting accessToken and signing webhooks with channelSecret.
+// Close it when done.
+func New(accessToken, channelSecret string) *Server {
+	s := &Server{
+		AccessToken:   accessToken,
+		ChannelSecret: channelSecret,
+		Destination:   "Ufakebotdestination",
+		profiles:      make(map[string]Profile),
+		replyTokens:   make(map[string]string),
+	}
+
+	r := mux.NewRouter()
+	r.Use(s.authenticate)
+	r.HandleFunc("/v2/bot/message/push", s.push).Methods("POST")
+	r.HandleFunc("/v2/bot/message/reply", s.reply).Methods("POST")
+	r.HandleFunc("/v2/bot/message/multicast", s.multicast).Methods("POST")
+	r.HandleFunc("/v2/bot/message/broadcast", s.broadcast).Methods("POST")
+	r.HandleFunc("/v2/bot/profile/{userId}", s.profile).Methods("GET")
+	r.HandleFunc("/v2/bot/message/quota", s.messageQuota).Methods("GET")
+	r.HandleFunc("/v2/bot/message/quota/consumption", s.quotaConsumption).Methods("GET")
+	s.Server = httptest.NewServer(r)
+
+	return s
+}
+
+// PushEndpoint returns the URL of the push message endpoint.
+func (s *Server) PushEndpoint() string {
+	return s.URL + "/v2/bot/message/push"
+}
+
+// ReplyEndpoint returns the URL of the reply message endpoint.
+func (s *Server) ReplyEndpoint() string {
+	return s.URL + "/v2/bot/message/reply"
+}
+
+// MulticastEndpoint returns the URL of the multicast message endpoint.
+func (s *Server) MulticastEndpoint() string {
+	return s.URL + "/v2/bot/message/multicast"
+}
+
+// BroadcastEndpoint returns the URL of the broadcast message endpoint.
+func (s *Server) BroadcastEndpoint() string {
+	return s.URL + "/v2/bot/message/broadcast"
+}
+
+// QuotaEndpoint returns the URL of the message quota endpoint.
+func (s *Server) QuotaEndpoint() string {
+	return s.URL + "/v2/bot/message/quota"
+}
+
+// SetQuota limits the pushes of the month to quota, 0 means no limit. Pushes beyond the quota are
+// rejected with 429 like the real platform.
+func (s *Server) SetQuota(quota int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quota = quota
+}
+
+// SetProfile registers the profile returned for the user.
+func (s *Server) SetProfile(profile Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.profiles[profile.UserId] = profile
+}
+
+// Messages returns every message accepted so far, in the order they were sent.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// MessagesTo returns the messages pushed to the user or replied to the user's events.
+func (s *Server) MessagesTo(userId string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var messages []Message
+	for _, m := range s.messages {
+		if m.To == userId {
+			messages = append(messages, m)
+		}
+	}
+
+	return messages
+}
+
+// Rejections returns the requests refused so far.
+func (s *Server) Rejections() []Rejection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Rejection(nil), s.rejections...)
+}
+
+// Reset forgets the recorded messages and rejections.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+	s.rejections = nil
+}
+
+// TextEvent builds a text message event sent by the user.
+func (s *Server) TextEvent(userId, text string) libs.WebhookEvent {
+	event := s.newEvent(userId)
+	event.Message = libs.WebhookMessage{
+		Type:       "text",
+		Id:         fmt.Sprintf("message-%s", event.WebhookEventId),
+		QuoteToken: fmt.Sprintf("quote-%s", event.WebhookEventId),
+		Text:       text,
+	}
+
+	return event
+}
+
+// LocationEvent builds a location message event sent by the user.
+func (s *Server) LocationEvent(userId string, latitude, longitude float64) libs.WebhookEvent {
+	event := s.newEvent(userId)
+	event.Message = libs.WebhookMessage{
+		Type:      "location",
+		Id:        fmt.Sprintf("message-%s", event.WebhookEventId),
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+
+	return event
+}
+
+// PostbackEvent builds a postback event sent by the user tapping a button carrying data.
+func (s *Server) PostbackEvent(userId, data string) libs.WebhookEvent {
+	event := s.newEvent(userId)
+	event.Type = "postback"
+	event.Postback.Data = data
+
+	return event
+}
+
+func (s *Server) newEvent(userId string) libs.WebhookEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.eventSeq++
+	event := libs.WebhookEvent{
+		Type:           "message",
+		WebhookEventId: fmt.Sprintf("01FAKEWEBHOOKEVENT%08d", s.eventSeq),
+		Timestamp:      time.Now().UnixMilli(),
+		ReplyToken:     fmt.Sprintf("reply-token-%d", s.eventSeq),
+		Mode:           "active",
+	}
+	event.Source.Type = "user"
+	event.Source.UserId = userId
+	s.replyTokens[event.ReplyToken] = userId
+
+	return event
+}
+
+// Deliver signs the events with the channel secret and posts them to the webhook URL, the same
+// way the LINE platform calls the bot.
+func (s *Server) Deliver(webhookURL string, events ...libs.WebhookEvent) (*http.Response, error) {
+	if events == nil {
+		events = []libs.WebhookEvent{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"destination": s.Destination,
+		"events":      events,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode webhook events: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Line-Signature", s.Sign(body))
+
+	return http.DefaultClient.Do(req)
+}
+
+// Sign returns the X-Line-Signature of body.
+func (s *Server) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(s.ChannelSecret))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+s.AccessToken {
+			s.reject(w, r, http.StatusUnauthorized, "Authentication failed. Confirm that the access token in the authorization header is valid.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) push(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		To       string                   `json:"to"`
+		Messages []map[string]interface{} `json:"messages"`
+	}
+
+	if err := s.decode(r, &payload); err != nil {
+		s.reject(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if payload.To == "" {
+		s.reject(w, r, http.StatusBadRequest, "The property, 'to', in the request body is invalid")
+		return
+	}
+
+	s.mu.Lock()
+	exceeded := s.quota > 0 && s.pushes >= s.quota
+	s.mu.Unlock()
+	if exceeded {
+		s.reject(w, r, http.StatusTooManyRequests, "You have reached your monthly limit.")
+		return
+	}
+
+	if s.accept(w, r, "push", []string{payload.To}, "", payload.Messages) {
+		s.mu.Lock()
+		s.pushes++
+		s.mu.Unlock()
+	}
+}
+
+func (s *Server) multicast(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		To       []string                 `json:"to"`
+		Messages []map[string]interface{} `json:"messages"`
+	}
+
+	if err := s.decode(r, &payload); err != nil {
+		s.reject(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(payload.To) == 0 || len(payload.To) > maxRecipients {
+		s.reject(w, r, http.StatusBadRequest, fmt.Sprintf("The property, 'to', must have between 1 and %d recipients", maxRecipients))
+		return
+	}
+
+	// every recipient counts against the quota
+	s.mu.Lock()
+	exceeded := s.quota > 0 && s.pushes+int64(len(payload.To)) > s.quota
+	s.mu.Unlock()
+	if exceeded {
+		s.reject(w, r, http.StatusTooManyRequests, "You have reached your monthly limit.")
+		return
+	}
+
+	if s.accept(w, r, "multicast", payload.To, "", payload.Messages) {
+		s.mu.Lock()
+		s.pushes += int64(len(payload.To))
+		s.mu.Unlock()
+	}
+}
+
+// broadcast records the messages to the Broadcast recipient, the fake doesn't know the followers.
+func (s *Server) broadcast(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+
+	if err := s.decode(r, &payload); err != nil {
+		s.reject(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.accept(w, r, "broadcast", []string{Broadcast}, "", payload.Messages)
+}
+
+func (s *Server) reply(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ReplyToken string                   `json:"replyToken"`
+		Messages   []map[string]interface{} `json:"messages"`
+	}
+
+	if err := s.decode(r, &payload); err != nil {
+		s.reject(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// reply tokens can only be used once
+	s.mu.Lock()
+	userId, ok := s.replyTokens[payload.ReplyToken]
+	delete(s.replyTokens, payload.ReplyToken)
+	s.mu.Unlock()
+
+	if !ok {
+		s.reject(w, r, http.StatusBadRequest, "Invalid reply token")
+		return
+	}
+
+	s.accept(w, r, "reply", []string{userId}, payload.ReplyToken, payload.Messages)
+}
+
+func (s *Server) profile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	profile, ok := s.profiles[mux.Vars(r)["userId"]]
+	s.mu.Unlock()
+
+	if !ok {
+		s.reject(w, r, http.StatusNotFound, "Not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (s *Server) messageQuota(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	quota := map[string]interface{}{"type": "none"}
+	if s.quota > 0 {
+		quota = map[string]interface{}{"type": "limited", "value": s.quota}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quota)
+}
+
+func (s *Server) quotaConsumption(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	usage := s.pushes
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"totalUsage": usage})
+}
+
+func (s *Server) decode(r *http.Request, payload interface{}) error {
+	if r.Header.Get("Content-Type") != "application/json" {
+		return fmt.Errorf("The request body has invalid content type")
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return fmt.Errorf("The request body has 1 error(s): %v", err)
+	}
+
+	return nil
+}
+
+// accept validates and records the messages of each recipient, it returns false when they are rejected.
+func (s *Server) accept(w http.ResponseWriter, r *http.Request, endpoint string, recipients []string, replyToken string, messages []map[string]interface{}) bool {
+	if len(messages) == 0 || len(messages) > maxMessages {
+		s.reject(w, r, http.StatusBadRequest, fmt.Sprintf("Size must be between 1 and %d", maxMessages))
+		return false
+	}
+
+	for i, m := range messages {
+		if err := validateMessage(m); err != nil {
+			s.reject(w, r, http.StatusBadRequest, fmt.Sprintf("messages[%d]: %v", i, err))
+			return false
+		}
+	}
+
+	s.mu.Lock()
+	var sent []map[string]string
+	for _, m := range messages {
+		text, _ := m["text"].(string)
+		altText, _ := m["altText"].(string)
+		for _, to := range recipients {
+			s.messages = append(s.messages, Message{
+				Endpoint:   endpoint,
+				To:         to,
+				ReplyToken: replyToken,
+				Type:       m["type"].(string),
+				Text:       text,
+				AltText:    altText,
+				Raw:        m,
+			})
+		}
+		sent = append(sent, map[string]string{
+			"id":         fmt.Sprintf("%d", len(s.messages)),
+			"quoteToken": fmt.Sprintf("quote-token-%d", len(s.messages)),
+		})
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"sentMessages": sent})
+
+	return true
+}
+
+func (s *Server) reject(w http.ResponseWriter, r *http.Request, statusCode int, reason string) {
+	s.mu.Lock()
+	s.rejections = append(s.rejections, Rejection{Path: r.URL.Path, StatusCode: statusCode, Reason: reason})
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"message": reason})
+}
+
+// validateMessage checks the fields the Messaging API requires for each message type.
+func validateMessage(m map[string]interface{}) error {
+	switch m["type"] {
+	case "text":
+		text, _ := m["text"].(string)
+		if text == "" || len([]rune(text)) > maxTextLength {
+			return fmt.Errorf("text must be between 1 and %d characters", maxTextLength)
+		}
+	case "flex":
+		altText, _ := m["altText"].(string)
+		if altText == "" || len([]rune(altText)) > maxAltTextLength {
+			return fmt.Errorf("altText must be between 1 and %d characters", maxAltTextLength)
+		}
+
+		contents, _ := m["contents"].(map[string]interface{})
+		if contents["type"] != "bubble" && contents["type"] != "carousel" {
+			return fmt.Errorf("contents.type must be bubble or carousel")
+		}
+	case "image", "location", "sticker", "template":
+	default:
+		return fmt.Errorf("unsupported message type: %v", m["type"])
+	}
+
+	if quickReply, ok := m["quickReply"].(map[string]interface{}); ok {
+		items, _ := quickReply["items"].([]interface{})
+		if len(items) == 0 || len(items) > maxQuickReplyItems {
+			return fmt.Errorf("quickReply.items must be between 1 and %d", maxQuickReplyItems)
+		}
+
+		for i, item := range items {
+			action, _ := item.(map[string]interface{})["action"].(map[string]interface{})
+			if action["type"] == nil || action["label"] == nil {
+				return fmt.Errorf("quickReply.items[%d].action requires type and label", i)
+			}
+
+			if imageUrl, ok := item.(map[string]interface{})["imageUrl"].(string); ok && !strings.HasPrefix(imageUrl, "https://") {
+				return fmt.Errorf("quickReply.items[%d].imageUrl must be an HTTPS URL", i)
+			}
+		}
+	}
+
+	return nil
+}
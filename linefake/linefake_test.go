@@ -0,0 +1,220 @@
+package linefake
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/libs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	fake := New("access-token", "channel-secret")
+	defer fake.Close()
+
+	send := func(url, token string, payload interface{}) int {
+		headers := map[string]string{
+			"Content-Type":  "application/json",
+			"Authorization": "Bearer " + token,
+		}
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	text := map[string]string{"type": "text", "text": "hello"}
+	event := fake.TextEvent("user-id", "hi")
+
+	tests := []struct {
+		name           string
+		url            string
+		token          string
+		payload        interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "push message",
+			url:            fake.PushEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"to": "user-id", "messages": []interface{}{text}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "reply message",
+			url:            fake.ReplyEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"replyToken": event.ReplyToken, "messages": []interface{}{text}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "reused reply token",
+			url:            fake.ReplyEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"replyToken": event.ReplyToken, "messages": []interface{}{text}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "wrong access token",
+			url:            fake.PushEndpoint(),
+			token:          "wrong-token",
+			payload:        map[string]interface{}{"to": "user-id", "messages": []interface{}{text}},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "missing recipient",
+			url:            fake.PushEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"messages": []interface{}{text}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "flex message without altText",
+			url:            fake.PushEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"to": "user-id", "messages": []interface{}{map[string]interface{}{"type": "flex", "contents": map[string]string{"type": "bubble"}}}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "bubble message",
+			url:            fake.PushEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"to": "user-id", "messages": []interface{}{libs.BubbleMessage(libs.GoStation{Location: "Station"}, libs.BubbleOptions{})}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "multicast message",
+			url:            fake.MulticastEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"to": []string{"user-id", "other-user-id"}, "messages": []interface{}{text}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "multicast without recipients",
+			url:            fake.MulticastEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"to": []string{}, "messages": []interface{}{text}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "broadcast message",
+			url:            fake.BroadcastEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"messages": []interface{}{text}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "too many messages",
+			url:            fake.PushEndpoint(),
+			token:          "access-token",
+			payload:        map[string]interface{}{"to": "user-id", "messages": []interface{}{text, text, text, text, text, text}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStatus, send(tt.url, tt.token, tt.payload))
+		})
+	}
+
+	messages := fake.MessagesTo("user-id")
+	assert.Len(t, messages, 4)
+	assert.Equal(t, "push", messages[0].Endpoint)
+	assert.Equal(t, "hello", messages[0].Text)
+	assert.Equal(t, "reply", messages[1].Endpoint)
+	assert.Equal(t, "flex", messages[2].Type)
+	assert.Equal(t, "multicast", messages[3].Endpoint)
+	assert.Len(t, fake.MessagesTo("other-user-id"), 1)
+	assert.Len(t, fake.MessagesTo(Broadcast), 1)
+	assert.Len(t, fake.Rejections(), 6)
+}
+
+func TestProfile(t *testing.T) {
+	fake := New("access-token", "channel-secret")
+	defer fake.Close()
+	fake.SetProfile(Profile{UserId: "user-id", DisplayName: "Rider"})
+
+	req, _ := http.NewRequest(http.MethodGet, fake.URL+"/v2/bot/profile/user-id", nil)
+	req.Header.Set("Authorization", "Bearer access-token")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	var profile Profile
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&profile))
+	assert.Equal(t, "Rider", profile.DisplayName)
+}
+
+func TestQuota(t *testing.T) {
+	fake := New("access-token", "channel-secret")
+	defer fake.Close()
+	fake.SetQuota(1)
+
+	get := func(url string) map[string]interface{} {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer access-token")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var result map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result
+	}
+	push := func() int {
+		body, _ := json.Marshal(map[string]interface{}{
+			"to":       "user-id",
+			"messages": []map[string]string{{"type": "text", "text": "hello"}},
+		})
+		req, _ := http.NewRequest(http.MethodPost, fake.PushEndpoint(), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer access-token")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, map[string]interface{}{"type": "limited", "value": float64(1)}, get(fake.QuotaEndpoint()))
+	assert.Equal(t, http.StatusOK, push())
+	assert.Equal(t, http.StatusTooManyRequests, push())
+	assert.Equal(t, map[string]interface{}{"totalUsage": float64(1)}, get(fake.QuotaEndpoint()+"/consumption"))
+}
+
+func TestDeliver(t *testing.T) {
+	fake := New("access-token", "channel-secret")
+	defer fake.Close()
+
+	var received []byte
+	var signature string
+	bot := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Line-Signature")
+	}))
+	defer bot.Close()
+
+	resp, err := fake.Deliver(bot.URL, fake.LocationEvent("user-id", 25.04, 121.56))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.True(t, libs.ValidateSignature("channel-secret", signature, received))
+
+	var payload struct {
+		Destination string              `json:"destination"`
+		Events      []libs.WebhookEvent `json:"events"`
+	}
+	assert.NoError(t, json.Unmarshal(received, &payload))
+	assert.Equal(t, fake.Destination, payload.Destination)
+	assert.Equal(t, "location", payload.Events[0].Message.Type)
+	assert.Equal(t, "user-id", payload.Events[0].Source.UserId)
+}
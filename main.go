@@ -2,77 +2,166 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"ohohestudio/sogorro/config"
 	"ohohestudio/sogorro/libs"
 	"ohohestudio/sogorro/metadata"
 	"os"
 	"os/signal"
-	"sort"
+	"strings"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/gorilla/mux"
-	"google.golang.org/api/iterator"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc"
 )
 
+// processed webhook events are remembered for a day, long enough to cover LINE's redelivery window
+const (
+	webhookEventsCollection = "webhookEvents"
+	webhookEventsTTL        = 24 * time.Hour
+)
+
+// stationFinder looks up the GoStations, it is implemented by libs.StationRepository.
+type stationFinder interface {
+	Nearby(ctx context.Context, latitude, longitude float64, limit int) ([]libs.GoStation, error)
+	Get(ctx context.Context, id string) (libs.GoStation, error)
+	All(ctx context.Context, filter libs.StationFilter) ([]libs.GoStation, error)
+}
+
 type App struct {
 	*http.Server
-	ctx                context.Context
-	fs                 *firestore.Client
-	lineBotAccessToken string
-	projectId          string
+	stop            context.CancelFunc
+	config          *config.Config
+	logger          *slog.Logger
+	meterProvider   *sdkmetric.MeterProvider
+	tracerProvider  *sdktrace.TracerProvider
+	metricsHandler  http.Handler
+	fs              *firestore.Client
+	stations        stationFinder
+	stationAdmin    stationEditor
+	stationCache    *libs.StationCache
+	subscriptions   libs.SubscriptionStore
+	reminders       libs.ReminderStore
+	users           libs.UserStore
+	dedupe          libs.DedupeStore
+	secrets         libs.SecretProvider
+	queue           *eventQueue
+	limiter         *libs.RateLimiter
+	mapLimiter      *libs.RateLimiter
+	channels        map[string]*channel
+	defaultChannel  *channel
+	projectId       string
+	region          string
+	readinessChecks map[string]func(context.Context) error
+	staticMap       libs.StaticMapProvider
+	mapTiles        *libs.MapTiles
+	routing         libs.RoutingProvider
+	grpcServer      *grpc.Server
+	grpcCalls       grpcCalls
+	schedulerToken  string
+	validateIDToken func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+	location        *time.Location
+
+	// now is the clock of the reminders, time.Now when nil
+	now func() time.Time
 
-	makeRequest func(method, url string, headers map[string]string, payload interface{}) ([]byte, error)
+	makeRequest func(ctx context.Context, method, url string, headers map[string]string, payload interface{}) ([]byte, error)
 }
 
+// shutdownSignals stop the server gracefully, Cloud Run sends SIGTERM before stopping an instance.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
 func main() {
 	ctx := context.Background()
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
 	}
 
-	projectId := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	level, _ := libs.ParseLogLevel(cfg.LogLevel)
+	logger := libs.NewLogger(os.Stdout, level)
+	slog.SetDefault(logger)
 
-	app, err := newApp(ctx, port, projectId)
+	app, err := newApp(ctx, logger, cfg)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("failed to start sogorro API server", "error", err)
+		os.Exit(1)
 	}
 
-	log.Printf("start sogorro API server, running on port: %s\n", port)
+	logger.Info("start sogorro API server", "port", cfg.Port, "grpc", cfg.Grpc)
 
 	go func() {
 		if err := app.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("sogorro API server closed unexpectedly: %v", err)
+			logger.Error("sogorro API server closed unexpectedly", "error", err)
+			os.Exit(1)
 		}
 	}()
 
-	nofityCtx, stop := signal.NotifyContext(ctx, os.Interrupt, os.Kill)
+	nofityCtx, stop := signal.NotifyContext(ctx, shutdownSignals...)
 	defer stop()
 	<-nofityCtx.Done()
-	log.Println("manually shutdown sogorro API server")
+	logger.Info("shutdown sogorro API server")
 
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	app.Shutdown(ctx)
-	log.Println("sogorro API server has been shutdown")
+	if err := app.Shutdown(ctx); err != nil {
+		logger.Error("failed to gracefully shutdown sogorro API server", "error", err)
+	}
+	logger.Info("sogorro API server has been shutdown")
 }
 
-func newApp(ctx context.Context, port, projectId string) (*App, error) {
+func newApp(ctx context.Context, logger *slog.Logger, cfg *config.Config) (*App, error) {
+	// background jobs, like the token refresh, run until the app is shutdown
+	ctx, stop := context.WithCancel(ctx)
 	app := &App{
-		ctx: ctx,
+		stop:   stop,
+		config: cfg,
+		logger: logger,
 		Server: &http.Server{
-			Addr:           fmt.Sprintf(":%s", port),
+			Addr:           fmt.Sprintf(":%s", cfg.Port),
 			ReadTimeout:    15 * time.Second,
 			WriteTimeout:   15 * time.Second,
 			MaxHeaderBytes: 1 << 20,
 		},
 	}
 
+	// Metrics
+	meterProvider, metricsHandler, err := libs.SetupMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	app.meterProvider = meterProvider
+	app.metricsHandler = metricsHandler
+
+	// Tracing
+	tracerProvider, err := libs.SetupTracing(ctx, cfg.TracesExporter, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	app.tracerProvider = tracerProvider
+
 	// Get Project ID
+	projectId := cfg.ProjectId
 	if projectId == "" {
 		projId, err := metadata.ProjectId(ctx)
 		if err != nil {
@@ -82,12 +171,32 @@ func newApp(ctx context.Context, port, projectId string) (*App, error) {
 	}
 	app.projectId = projectId
 
-	// Get Linebot access token
-	accessToken, err := libs.GetLineBotAccessToken(ctx, os.Getenv("SECRET_PROJECT_ID"), os.Getenv("SECRET_NAME"))
-	if err != nil {
-		return nil, err
+	// Get Region, only known when running on Google Cloud
+	if metadata.OnGCE() {
+		regionCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+		region, err := metadata.Region(regionCtx)
+		cancel()
+		if err != nil {
+			logger.Warn("unable to detect region from Google metadata server", "error", err)
+		}
+		app.region = region[strings.LastIndex(region, "/")+1:]
+	}
+
+	// Linebot channels, routed by the webhook destination
+	app.secrets = cfg.SecretProvider()
+	app.channels = map[string]*channel{}
+	for _, channelConfig := range cfg.ChannelConfigs() {
+		ch, err := newChannel(libs.WithLogger(ctx, logger), cfg, app.secrets, channelConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		if ch.destination == "" {
+			app.defaultChannel = ch
+			continue
+		}
+		app.channels[ch.destination] = ch
 	}
-	app.lineBotAccessToken = accessToken
 
 	// firestore
 	fsClient, err := libs.GetFirebaseClient(ctx, app.projectId)
@@ -95,106 +204,460 @@ func newApp(ctx context.Context, port, projectId string) (*App, error) {
 		return nil, err
 	}
 	app.fs = fsClient
+	stations := libs.NewStationRepository(fsClient, cfg.SearchRadius)
+	app.stationCache = libs.NewStationCache(stationCacheTTL)
+	app.stations = cachedStations{stationFinder: stations, cache: app.stationCache}
+	app.stationAdmin = stations
+	app.staticMap = cfg.StaticMapProvider()
+	if cfg.MapProvider == config.LocalMapProvider {
+		app.mapTiles = libs.NewMapTiles(cfg.MapTileUrl)
+	}
+	app.routing = cfg.RoutingProvider()
+	if cfg.Grpc {
+		app.grpcServer = app.newGrpcServer()
+	}
+	app.validateIDToken = metadata.ValidateIDToken
+	app.dedupe = libs.NewFirestoreDedupeStore(fsClient, webhookEventsCollection, webhookEventsTTL)
+	app.subscriptions = libs.NewFirestoreSubscriptionStore(fsClient, subscriptionsCollection)
+	if cfg.StationNotifications {
+		go app.watchStations(libs.WithLogger(ctx, logger))
+	}
+	app.reminders = libs.NewFirestoreReminderStore(fsClient, remindersCollection)
+	app.users = libs.NewFirestoreUserStore(fsClient, libs.UsersCollection)
+
+	// Reminders, run by Cloud Scheduler
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %s: %v", cfg.Timezone, err)
+	}
+	app.location = location
+	if cfg.SchedulerTokenName != "" {
+		schedulerToken, err := app.secrets.Secret(ctx, cfg.SchedulerTokenName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load scheduler token: %v", err)
+		}
+		app.schedulerToken = schedulerToken
+	}
 
-	app.makeRequest = libs.MakeRequest
+	app.makeRequest = libs.MakeRequestContext
+	app.limiter = libs.NewRateLimiter(cfg.UserRateLimit, cfg.UserRateBurst, cfg.RateLimit, cfg.RateBurst)
+	// the maps are requested by the LINE servers rather than the users, only the global rate applies
+	app.mapLimiter = libs.NewRateLimiter(0, 0, cfg.RateLimit, cfg.RateBurst)
+	app.queue = newEventQueue(cfg.EventWorkers, cfg.EventQueueSize, app.processEvent)
+	app.readinessChecks = map[string]func(context.Context) error{
+		"firestore": app.pingFirestore,
+		"lineToken": app.checkLineToken,
+	}
 
-	// Router
-	r := mux.NewRouter()
-	r.HandleFunc("/station", app.findStation).Methods("POST")
-	app.Handler = r
+	// h2c serves HTTP/2 without TLS, like Cloud Run sends it with --use-http2 for the gRPC calls
+	app.Handler = h2c.NewHandler(app.router(), &http2.Server{})
 
 	return app, nil
 }
 
+func (a *App) router() http.Handler {
+	r := mux.NewRouter()
+	r.Use(a.requestTracing, a.requestLogger, a.requestMetrics)
+	r.HandleFunc("/station", a.findStation).Methods("POST")
+	a.apiRouter(r)
+	if a.config.MapProvider == config.LocalMapProvider {
+		r.HandleFunc("/map/{lat:-?[0-9.]+},{lng:-?[0-9.]+}.png", a.limitMaps(a.stationMap)).Methods("GET")
+	}
+	if a.schedulerToken != "" {
+		r.HandleFunc("/cron/reminders", a.runReminders).Methods("POST")
+	}
+	if a.config.AdminAudience != "" {
+		admin := r.PathPrefix("/admin").Subrouter()
+		admin.Use(a.requireAdmin)
+		admin.HandleFunc("/stations/{id}", a.getStation).Methods("GET")
+		admin.HandleFunc("/stations/{id}", a.putStation).Methods("PUT")
+		admin.HandleFunc("/stations/{id}", a.patchStation).Methods("PATCH")
+		admin.HandleFunc("/stations/{id}", a.deleteStation).Methods("DELETE")
+	}
+	r.HandleFunc("/healthz", a.healthz).Methods("GET")
+	r.HandleFunc("/readyz", a.readyz).Methods("GET")
+	r.HandleFunc("/version", a.version).Methods("GET")
+	if a.metricsHandler != nil {
+		r.Handle("/metrics", a.metricsHandler).Methods("GET")
+	}
+	if a.grpcServer != nil {
+		return a.withGrpc(r)
+	}
+
+	return r
+}
+
+// requestTracing starts the root span of the request, continuing the trace propagated by Cloud Run.
+func (a *App) requestTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx, span := libs.Tracer.Start(ctx, fmt.Sprintf("%s %s", r.Method, route), trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		span.SetAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("http.route", route),
+		)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// requestLogger attaches a logger to the request context, carrying a request ID and the trace
+// of the X-Cloud-Trace-Context header so Cloud Logging groups the lines of a request.
+func (a *App) requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := a.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		requestId := r.Header.Get("X-Request-Id")
+		if requestId == "" {
+			requestId = newRequestId()
+		}
+
+		traceAttrs := libs.SpanAttrs(r.Context(), a.projectId)
+		if traceAttrs == nil {
+			traceAttrs = libs.TraceAttrs(a.projectId, r.Header.Get(libs.CloudTraceHeader))
+		}
+		logger = logger.With("requestId", requestId).With(traceAttrs...)
+		next.ServeHTTP(w, r.WithContext(libs.WithLogger(r.Context(), logger)))
+	})
+}
+
+// requestMetrics records the count and latency of requests by route template, method and status.
+func (a *App) requestMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		attrs := metric.WithAttributes(
+			attribute.String("route", route),
+			attribute.String("method", r.Method),
+			attribute.Int("status", recorder.status),
+		)
+		libs.HTTPRequests.Add(r.Context(), 1, attrs)
+		libs.HTTPRequestDuration.Record(r.Context(), time.Since(start).Seconds(), attrs)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func newRequestId() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Shutdown stops accepting webhook requests and gRPC calls, then waits for the queued events to be
+// processed.
+func (a *App) Shutdown(ctx context.Context) error {
+	err := a.Server.Shutdown(ctx)
+	if a.grpcServer != nil {
+		a.stopGrpc(ctx)
+	}
+	if a.stop != nil {
+		a.stop()
+	}
+	if drainErr := a.queue.drain(ctx); drainErr != nil && err == nil {
+		err = fmt.Errorf("failed to drain event queue: %v", drainErr)
+	}
+
+	// flush the metrics and spans recorded while draining
+	if a.tracerProvider != nil {
+		if tracingErr := a.tracerProvider.Shutdown(ctx); tracingErr != nil && err == nil {
+			err = fmt.Errorf("failed to shutdown tracer provider: %v", tracingErr)
+		}
+	}
+
+	if a.meterProvider != nil {
+		if metricsErr := a.meterProvider.Shutdown(ctx); metricsErr != nil && err == nil {
+			err = fmt.Errorf("failed to shutdown meter provider: %v", metricsErr)
+		}
+	}
+
+	return err
+}
+
 func (a *App) findStation(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	logger := libs.LoggerFromContext(r.Context())
 	var webhookPayload struct {
 		Destination string              `json:"destination"`
 		Events      []libs.WebhookEvent `json:"events"`
 	}
 
-	err := json.NewDecoder(r.Body).Decode(&webhookPayload)
+	err = json.Unmarshal(body, &webhookPayload)
 	if err != nil {
+		logger.Warn("failed to decode webhook payload", "error", err)
 		http.Error(w, fmt.Sprintf("failed to decode JSON string: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	payload := struct {
-		To       string        `json:"to"`
-		Messages []interface{} `json:"messages"`
-	}{}
-
-	payload.To = webhookPayload.Events[0].Source.UserId
-	if webhookPayload.Events[0].Message.Type == "location" {
-		latitude := webhookPayload.Events[0].Message.Latitude
-		longitude := webhookPayload.Events[0].Message.Longitude
-		query := a.fs.Collection("stations").Where("latitude", ">=", latitude-0.035).
-			Where("latitude", "<=", latitude+0.035).
-			Where("longitude", ">=", longitude-0.035).
-			Where("longitude", "<=", longitude+0.035).
-			Where("state", "==", 1)
-		iter := query.Documents(a.ctx)
-
-		var stations []libs.GoStation
-		for {
-			doc, err := iter.Next()
-			if err == iterator.Done {
-				break
+	// the destination picks the channel, it is trusted once the signature is validated with the channel secret
+	ch, ok := a.channelFor(webhookPayload.Destination)
+	if !ok {
+		logger.Warn("rejected webhook of unknown destination", "destination", webhookPayload.Destination)
+		http.Error(w, "unknown webhook destination", http.StatusNotFound)
+		return
+	}
+
+	logger = logger.With("channel", ch.name)
+	// a channel without secret can't tell a forged webhook, it rejects them all
+	if ch.channelSecret == "" || !libs.ValidateSignature(ch.channelSecret, r.Header.Get("X-Line-Signature"), body) {
+		logger.Warn("rejected webhook with invalid signature")
+		http.Error(w, "invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := libs.WithLogger(r.Context(), logger)
+	for _, event := range webhookPayload.Events {
+		// LINE redelivers the events when the webhook fails, those already queued are skipped then
+		if !a.queue.enqueue(ctx, ch, event) {
+			logger.Warn("event queue is full", "webhookEventId", event.WebhookEventId)
+			http.Error(w, "event queue is full", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{}"))
+}
+
+// processEvent runs on the event queue workers, each webhook event is processed at most once.
+func (a *App) processEvent(ctx context.Context, ch *channel, event libs.WebhookEvent) {
+	ctx, span := libs.Tracer.Start(ctx, "webhook.event", trace.WithAttributes(
+		attribute.String("webhook.event_id", event.WebhookEventId),
+		attribute.String("webhook.event_type", event.Type),
+		attribute.String("webhook.message_type", event.Message.Type),
+		attribute.Bool("webhook.redelivery", event.DeliveryContext.IsRedelivery),
+		attribute.String("webhook.channel", ch.name),
+	))
+	defer span.End()
+
+	logger := libs.LoggerFromContext(ctx).With(
+		"webhookEventId", event.WebhookEventId,
+		"userHash", libs.HashUserId(event.Source.UserId),
+	)
+	ctx = libs.WithLogger(ctx, logger)
+
+	libs.WebhookEvents.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("type", event.Type),
+		attribute.Bool("redelivery", event.DeliveryContext.IsRedelivery),
+	))
+
+	if event.WebhookEventId != "" {
+		first, err := a.dedupe.Claim(ctx, event.WebhookEventId)
+		if err != nil {
+			logger.Error("failed to check webhook event", "error", err)
+			return
+		}
+
+		if !first {
+			libs.WebhookDuplicates.Add(ctx, 1)
+			logger.Info("skip already processed webhook event", "redelivery", event.DeliveryContext.IsRedelivery)
+			return
+		}
+	}
+
+	// throttled events are dropped, the user is only asked once to slow down
+	if a.limiter != nil {
+		if decision := a.limiter.Allow(event.Source.UserId); !decision.Allowed {
+			libs.WebhookThrottled.Add(ctx, 1, metric.WithAttributes(attribute.String("scope", decision.Scope)))
+			logger.Warn("throttled webhook event", "scope", decision.Scope)
+			if decision.Notify {
+				// a reply doesn't use the push quota the throttled users would otherwise burn
+				if _, err := a.reply(ctx, ch, event, []interface{}{textMessage(ch.branding.SlowDownText)}); err != nil {
+					logger.Error("failed to ask user to slow down", "error", err)
+				}
 			}
+			return
+		}
+	}
 
-			if err != nil {
-				fmt.Printf("failed to iterate document: %v\n", err)
+	if _, err := a.handleEvent(ctx, ch, event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		logger.Error("failed to handle webhook event", "error", err)
+		if event.WebhookEventId != "" {
+			if err := a.dedupe.Release(ctx, event.WebhookEventId); err != nil {
+				logger.Error("failed to release webhook event", "error", err)
 			}
+		}
+		return
+	}
 
-			stations = append(stations, libs.GoStation{
-				Address:   doc.Data()["address"].(string),
-				City:      doc.Data()["city"].(string),
-				Distance:  libs.Haversine(doc.Data()["latitude"].(float64), doc.Data()["longitude"].(float64), latitude, longitude),
-				District:  doc.Data()["district"].(string),
-				Location:  doc.Data()["location"].(string),
-				Latitude:  doc.Data()["latitude"].(float64),
-				Longitude: doc.Data()["longitude"].(float64),
-				VMType:    doc.Data()["vmType"].(int64),
-			})
+	logger.Info("webhook event handled", "eventType", event.Type, "messageType", event.Message.Type)
+}
+
+func (a *App) handleEvent(ctx context.Context, ch *channel, event libs.WebhookEvent) ([]byte, error) {
+	if event.Type == "postback" {
+		return a.handlePostback(ctx, ch, event)
+	}
+
+	var messages []interface{}
+	if event.Message.Type == "location" {
+		stations, err := a.searchStations(ctx, stationQuery{
+			Latitude:  event.Message.Latitude,
+			Longitude: event.Message.Longitude,
+			Limit:     a.config.ResultCount,
+			Source:    "bot",
+		})
+		if err != nil {
+			return nil, err
 		}
 
 		if len(stations) > 0 {
-			sort.Slice(stations, func(j, k int) bool {
-				return stations[j].Distance < stations[k].Distance
-			})
-
-			for _, station := range stations[:3] {
-				payload.Messages = append(payload.Messages, libs.BubbleMessage(station))
+			a.recordSearch(ctx, ch, event, stations[0])
+			origin := libs.MapPoint{Latitude: event.Message.Latitude, Longitude: event.Message.Longitude}
+			options := a.bubbleOptions(ctx, event.Source.UserId, &origin)
+			for i, station := range stations {
+				message := libs.BubbleMessage(station, options)
+				message.AltText = ch.branding.AltText
+				message.Contents.Hero = a.mapHero(origin, station)
+				if i == len(stations)-1 && a.remindersEnabled() {
+					message.QuickReply = reminderQuickReply(origin)
+				}
+				messages = append(messages, message)
 			}
 		} else {
-			payload.Messages = append(payload.Messages, map[string]string{
+			messages = append(messages, map[string]string{
 				"type": "text",
-				"text": "抱歉，您附近沒有找到 Gogoro 充電站。請嘗試分享其他位置或稍後再試。",
+				"text": ch.branding.NotFoundText,
 			})
 		}
 	} else {
-		payload.Messages = append(payload.Messages, map[string]interface{}{
-			"type":       "text",
-			"text":       "歡迎使用 sogorro \n\n只要分享您的目前位置，我們會為您找到離您最近的 GoStation，方便您快速找到充電站！隨時隨地，讓騎乘更輕鬆愜意！",
-			"quickReply": libs.WelcomeQuickReplyMessage(),
-		})
+		messages = append(messages, welcomeMessage(ch))
 	}
 
-	result, err := a.makeRequest(
-		http.MethodPost,
-		os.Getenv("LINE_API_ENDPOINT"),
-		map[string]string{
-			"Content-Type":  "application/json",
-			"Authorization": fmt.Sprintf("Bearer %s", a.lineBotAccessToken),
-		},
-		payload,
-	)
+	return a.answer(ctx, ch, event, messages)
+}
 
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to push line message: %v", err), http.StatusInternalServerError)
+// recordSearch remembers the city of the nearest station found for the user, announcements are
+// targeted to the users of a city with it. The location itself is not kept. Failing to record it
+// doesn't fail the search.
+func (a *App) recordSearch(ctx context.Context, ch *channel, event libs.WebhookEvent, nearest libs.GoStation) {
+	if a.users == nil || event.Source.UserId == "" {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(result)
+	err := a.users.RecordSearch(ctx, libs.UserSearch{
+		UserId:     event.Source.UserId,
+		Channel:    ch.name,
+		City:       nearest.City,
+		District:   nearest.District,
+		SearchedAt: time.Now(),
+	})
+	if err != nil {
+		libs.LoggerFromContext(ctx).Warn("failed to record user search", "error", err)
+	}
+}
+
+// welcomeMessage introduces the bot, with a quick reply to share the location.
+func welcomeMessage(ch *channel) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "text",
+		"text":       ch.branding.WelcomeText,
+		"quickReply": libs.WelcomeQuickReplyMessage(),
+	}
+}
+
+// answer pushes the messages to the user of the event. Once the channel is over its push budget,
+// the messages are replied with the reply token instead, which doesn't use the quota, and events
+// without a reply token are left unanswered.
+func (a *App) answer(ctx context.Context, ch *channel, event libs.WebhookEvent, messages []interface{}) ([]byte, error) {
+	if ch.overBudget() {
+		if event.ReplyToken == "" {
+			libs.LinePushesBlocked.Add(ctx, 1, metric.WithAttributes(attribute.String("channel", ch.name)))
+			libs.LoggerFromContext(ctx).Warn("skip push over the push budget")
+			return nil, nil
+		}
+
+		return a.reply(ctx, ch, event, messages)
+	}
+
+	result, err := a.push(ctx, ch, a.config.LineAPIEndpoint, map[string]interface{}{
+		"to":       event.Source.UserId,
+		"messages": messages,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to push line message: %v", err)
+	}
+
+	return result, nil
+}
+
+// reply answers the event with its reply token, events without one are left unanswered.
+func (a *App) reply(ctx context.Context, ch *channel, event libs.WebhookEvent, messages []interface{}) ([]byte, error) {
+	if event.ReplyToken == "" {
+		return nil, nil
+	}
+
+	result, err := a.push(ctx, ch, a.config.LineReplyEndpoint, map[string]interface{}{
+		"replyToken": event.ReplyToken,
+		"messages":   messages,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reply line message: %v", err)
+	}
+
+	return result, nil
+}
+
+// push posts the payload to the LINE endpoint as the channel. When LINE rejects the channel access token, like
+// after it was rotated, the token is refreshed and the push retried once.
+func (a *App) push(ctx context.Context, ch *channel, endpoint string, payload interface{}) ([]byte, error) {
+	var result []byte
+	err := ch.tokens.Authorized(ctx, func(token string) error {
+		var err error
+		result, err = a.makeRequest(
+			ctx,
+			http.MethodPost,
+			endpoint,
+			map[string]string{
+				"Content-Type":  "application/json",
+				"Authorization": fmt.Sprintf("Bearer %s", token),
+			},
+			payload,
+		)
+		return err
+	})
+
+	return result, err
 }
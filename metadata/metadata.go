@@ -18,13 +18,24 @@ func ProjectId(ctx context.Context) (string, error) {
 func Region(ctx context.Context) (string, error) {
 	region, err := metadata.GetWithContext(ctx, "instance/region")
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	return region, nil
 }
 
+// OnGCE reports whether the service runs on Google Cloud, where the metadata server is available.
+func OnGCE() bool {
+	return metadata.OnGCE()
+}
+
 // IDToken returns a TokenSource that yields ID tokens. These tokens can be used to authenticate requests with the Token.SetAuthHeader method.
 func IDToken(ctx context.Context, aud string) (oauth2.TokenSource, error) {
 	return idtoken.NewTokenSource(ctx, aud)
 }
+
+// ValidateIDToken verifies a Google ID token, like those of IDToken, was issued for the audience
+// and returns its claims.
+func ValidateIDToken(ctx context.Context, token, aud string) (*idtoken.Payload, error) {
+	return idtoken.Validate(ctx, token, aud)
+}
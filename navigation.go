@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"ohohestudio/sogorro/libs"
+)
+
+// bubbleOptions opens the directions from the origin in the navigation app chosen by the user,
+// the default app is used when the preferences can't be read.
+func (a *App) bubbleOptions(ctx context.Context, userId string, origin *libs.MapPoint) libs.BubbleOptions {
+	options := libs.BubbleOptions{Origin: origin}
+	if a.users == nil || userId == "" {
+		return options
+	}
+
+	preferences, err := a.users.Preferences(ctx, userId)
+	if err != nil {
+		libs.LoggerFromContext(ctx).Warn("failed to get user preferences", "error", err)
+		return options
+	}
+	options.Navigation, _ = libs.NavigationProviderOf(preferences.Navigation)
+
+	return options
+}
+
+// navigationSettings answers the settings of the rich menu with the navigation app of the user,
+// and a quick reply to choose another one.
+func (a *App) navigationSettings(ctx context.Context, ch *channel, event libs.WebhookEvent) ([]byte, error) {
+	current := a.bubbleOptions(ctx, event.Source.UserId, nil).Navigation
+	if current == nil {
+		current = libs.NavigationProviders[0]
+	}
+
+	quickReply := libs.QuickReplyTemplate{}
+	for _, provider := range libs.NavigationProviders {
+		quickReply.Items = append(quickReply.Items, libs.QuickReplyItemTemplate{
+			Type: "action",
+			Action: libs.ActionTemplate{
+				Type:        libs.PostbackAction,
+				Label:       provider.Label(),
+				Data:        libs.NavigationPostback(provider.Name()),
+				DisplayText: "使用 " + provider.Label() + " 導航",
+			},
+		})
+	}
+
+	return a.answer(ctx, ch, event, []interface{}{
+		map[string]interface{}{
+			"type":       "text",
+			"text":       fmt.Sprintf("「立即前往」目前以 %s 導航，請選擇要使用的導航 App：", current.Label()),
+			"quickReply": quickReply,
+		},
+	})
+}
+
+// chooseNavigation saves the navigation app chosen in the settings.
+func (a *App) chooseNavigation(ctx context.Context, ch *channel, event libs.WebhookEvent) ([]byte, error) {
+	values, _ := url.ParseQuery(event.Postback.Data)
+	provider, ok := libs.NavigationProviderOf(values.Get("app"))
+	if !ok || a.users == nil {
+		return a.navigationSettings(ctx, ch, event)
+	}
+
+	if err := a.users.SavePreferences(ctx, event.Source.UserId, libs.Preferences{Navigation: provider.Name()}); err != nil {
+		return nil, err
+	}
+
+	return a.answer(ctx, ch, event, []interface{}{
+		textMessage(fmt.Sprintf("之後「立即前往」會以 %s 開啟導航。", provider.Label())),
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"ohohestudio/sogorro/libs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConversationNavigationSettings(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+	app.stations = memoryStations{
+		"station-1": {Id: "station-1", Location: "台北101站", Latitude: 25.033964, Longitude: 121.564468, State: libs.ActiveState},
+	}
+
+	deliver := func(events ...libs.WebhookEvent) {
+		resp, err := fake.Deliver(webhookURL, events...)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	navigationUri := func(message map[string]interface{}) string {
+		footer := message["contents"].(map[string]interface{})["footer"].(map[string]interface{})
+		button := footer["contents"].([]interface{})[0].(map[string]interface{})
+		return button["action"].(map[string]interface{})["uri"].(string)
+	}
+
+	deliver(fake.LocationEvent("rider-a", 25.03, 121.56))
+	deliver(fake.PostbackEvent("rider-a", libs.SettingsPostback))
+	deliver(fake.PostbackEvent("rider-a", libs.NavigationPostback(libs.AppleMapsNavigation)))
+	deliver(fake.PostbackEvent("rider-a", libs.NavigationPostback("here")))
+	deliver(fake.LocationEvent("rider-a", 25.03, 121.56))
+	assert.NoError(t, app.queue.drain(context.TODO()))
+	assert.Empty(t, fake.Rejections())
+
+	messages := fake.MessagesTo("rider-a")
+	assert.Len(t, messages, 5)
+
+	// Google Maps by default, from the shared location
+	assert.Equal(t, "https://www.google.com/maps/dir/?api=1&destination=25.033964%2C121.564468&origin=25.030000%2C121.560000&travelmode=two-wheeler", navigationUri(messages[0].Raw))
+
+	assert.Contains(t, messages[1].Text, "目前以 Google 地圖 導航")
+	items := messages[1].Raw["quickReply"].(map[string]interface{})["items"].([]interface{})
+	assert.Len(t, items, len(libs.NavigationProviders))
+
+	assert.Contains(t, messages[2].Text, "以 Apple 地圖 開啟導航")
+	// an unknown app shows the settings again
+	assert.Contains(t, messages[3].Text, "目前以 Apple 地圖 導航")
+	assert.True(t, strings.HasPrefix(navigationUri(messages[4].Raw), "https://maps.apple.com/?daddr=25.033964%2C121.564468&dirflg=d&saddr=25.030000%2C121.560000"))
+}
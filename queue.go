@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"ohohestudio/sogorro/libs"
+	"sync"
+)
+
+// eventQueue hands webhook events over to a fixed number of background workers, so the webhook
+// can be acknowledged before the events are processed.
+type eventQueue struct {
+	mu     sync.RWMutex
+	closed bool
+	events chan queuedEvent
+	wg     sync.WaitGroup
+}
+
+// queuedEvent keeps the context values of the webhook request, like its logger, and the channel
+// which received the event along with the event.
+type queuedEvent struct {
+	ctx     context.Context
+	channel *channel
+	event   libs.WebhookEvent
+}
+
+func newEventQueue(workers, size int, handle func(context.Context, *channel, libs.WebhookEvent)) *eventQueue {
+	q := &eventQueue{
+		events: make(chan queuedEvent, size),
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for e := range q.events {
+				handle(e.ctx, e.channel, e.event)
+			}
+		}()
+	}
+
+	return q
+}
+
+// enqueue adds the event to the queue without blocking. It returns false when the queue is full
+// or already drained. The event is processed with the values of ctx, but not its cancellation.
+func (q *eventQueue) enqueue(ctx context.Context, ch *channel, event libs.WebhookEvent) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.events <- queuedEvent{ctx: context.WithoutCancel(ctx), channel: ch, event: event}:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain stops accepting events and waits until the queued ones are processed or ctx is done.
+func (q *eventQueue) drain(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"ohohestudio/sogorro/libs"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// remindersCollection keeps a document per reminder of a user.
+const remindersCollection = "reminders"
+
+// schedulerTokenHeader carries the token Cloud Scheduler authenticates the cron requests with.
+const schedulerTokenHeader = "X-Scheduler-Token"
+
+// reminderWindows are the lengths of the windows offered once the start of a reminder is picked.
+var reminderWindows = []time.Duration{30 * time.Minute, time.Hour, libs.MaxReminderWindow}
+
+// reminderStart is the time initially shown by the picker of the reminder start.
+const reminderStart = "08:00"
+
+// clock returns the current time in the timezone of the reminders.
+func (a *App) clock() time.Time {
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+
+	location := a.location
+	if location == nil {
+		location = time.Local
+	}
+
+	return now().In(location)
+}
+
+// runReminders is called by Cloud Scheduler every few minutes, it pushes the nearest stations of
+// the reminders whose window includes the current time. Each reminder is sent once a day, a run
+// failing for some reminders answers 500 so Scheduler retries them within the window.
+func (a *App) runReminders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := libs.LoggerFromContext(ctx)
+
+	token := r.Header.Get(schedulerTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.schedulerToken)) != 1 {
+		logger.Warn("rejected cron request with invalid scheduler token")
+		http.Error(w, "invalid scheduler token", http.StatusUnauthorized)
+		return
+	}
+
+	result := struct {
+		Due     int `json:"due"`
+		Sent    int `json:"sent"`
+		Skipped int `json:"skipped"`
+		Failed  int `json:"failed"`
+	}{}
+
+	now := a.clock()
+	reminders, err := a.reminders.Due(ctx, now.Hour()*60+now.Minute())
+	if err != nil {
+		logger.Error("failed to get due reminders", "error", err)
+		http.Error(w, "failed to get due reminders", http.StatusInternalServerError)
+		return
+	}
+
+	for _, reminder := range reminders {
+		if !reminder.OnWeekday(int(now.Weekday())) {
+			continue
+		}
+		result.Due++
+
+		status := a.sendReminder(ctx, reminder, now)
+		libs.Reminders.Add(ctx, 1, metric.WithAttributes(attribute.String("result", status)))
+		switch status {
+		case "sent":
+			result.Sent++
+		case "skipped":
+			result.Skipped++
+		default:
+			result.Failed++
+		}
+	}
+
+	logger.Info("reminders run", "due", result.Due, "sent", result.Sent, "skipped", result.Skipped, "failed", result.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.Failed > 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// sendReminder pushes the nearest stations of the reminder, and returns sent, skipped or error.
+func (a *App) sendReminder(ctx context.Context, reminder libs.Reminder, date time.Time) string {
+	logger := libs.LoggerFromContext(ctx).With("reminderId", reminder.Id, "userHash", libs.HashUserId(reminder.UserId))
+
+	ch := a.channelNamed(reminder.Channel)
+	if ch == nil {
+		logger.Warn("skip reminder of unknown channel", "channel", reminder.Channel)
+		return "skipped"
+	}
+
+	// every run in the reminder window finds it and every instance may serve them, the first run
+	// to claim the reminder of the day sends it
+	key := fmt.Sprintf("reminder-%s-%s", reminder.Id, date.Format(time.DateOnly))
+	first, err := a.dedupe.Claim(ctx, key)
+	if err != nil {
+		logger.Error("failed to claim reminder", "error", err)
+		return "error"
+	}
+	if !first {
+		return "skipped"
+	}
+
+	if !ch.reserve(1) {
+		libs.LinePushesBlocked.Add(ctx, 1, metric.WithAttributes(attribute.String("channel", ch.name)))
+		logger.Warn("skip reminder over the push budget", "channel", ch.name)
+		return "skipped"
+	}
+
+	messages, err := a.reminderMessages(ctx, ch, reminder)
+	if err == nil {
+		_, err = a.push(ctx, ch, a.config.LineAPIEndpoint, map[string]interface{}{
+			"to":       reminder.UserId,
+			"messages": messages,
+		})
+	}
+	if err != nil {
+		logger.Error("failed to send reminder", "error", err)
+		if err := a.dedupe.Release(ctx, key); err != nil {
+			logger.Error("failed to release reminder", "error", err)
+		}
+		return "error"
+	}
+
+	return "sent"
+}
+
+// reminderMessages introduces the carousel of the nearest open stations around the reminder location.
+func (a *App) reminderMessages(ctx context.Context, ch *channel, reminder libs.Reminder) ([]interface{}, error) {
+	stations, err := a.searchStations(ctx, stationQuery{
+		Latitude:  reminder.Latitude,
+		Longitude: reminder.Longitude,
+		Limit:     a.config.ResultCount,
+		Source:    "reminder",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stations) == 0 {
+		return []interface{}{textMessage(ch.branding.NotFoundText)}, nil
+	}
+
+	label := reminder.Label
+	if label == "" {
+		label = "您設定的地點"
+	}
+
+	// the rider may not be at the reminder location yet, the directions start from the phone
+	carousel := libs.CarouselMessage(stations, a.bubbleOptions(ctx, reminder.UserId, nil))
+	carousel.AltText = ch.branding.AltText
+	origin := libs.MapPoint{Latitude: reminder.Latitude, Longitude: reminder.Longitude}
+	for i := range carousel.Contents.Contents {
+		carousel.Contents.Contents[i].Hero = a.mapHero(origin, stations[i])
+	}
+
+	return []interface{}{
+		textMessage(fmt.Sprintf("充電提醒：%s附近營運中的充電站", label)),
+		carousel,
+	}, nil
+}
+
+// remindersEnabled tells whether the reminders are sent, the scheduler only calls the bot with a token.
+func (a *App) remindersEnabled() bool {
+	return a.reminders != nil && a.schedulerToken != ""
+}
+
+// reminderQuickReply offers to remind the user of the stations around the location, at a time
+// picked in LINE.
+func reminderQuickReply(location libs.MapPoint) *libs.QuickReplyTemplate {
+	return &libs.QuickReplyTemplate{
+		Items: []libs.QuickReplyItemTemplate{{
+			Type: "action",
+			Action: libs.ActionTemplate{
+				Type:    libs.DatetimePickerAction,
+				Label:   "每天提醒我這附近的站",
+				Data:    libs.ReminderPostback(location, "", ""),
+				Mode:    "time",
+				Initial: reminderStart,
+			},
+		}},
+	}
+}
+
+// saveReminder answers the reminder postbacks. The time picked for the location is answered with
+// the windows to choose from, the chosen window saves the reminder of the user, replacing the
+// previous one.
+func (a *App) saveReminder(ctx context.Context, ch *channel, event libs.WebhookEvent) ([]byte, error) {
+	if !a.remindersEnabled() {
+		return a.answer(ctx, ch, event, []interface{}{textMessage("抱歉，目前無法設定提醒。")})
+	}
+
+	values, _ := url.ParseQuery(event.Postback.Data)
+	location, err := libs.ParseMapPoint(values.Get("at"))
+	if err != nil {
+		libs.LoggerFromContext(ctx).Warn("invalid reminder postback", "error", err)
+		return a.answer(ctx, ch, event, []interface{}{welcomeMessage(ch)})
+	}
+
+	// the start is picked with the datetimepicker, and sent along with the chosen window
+	start := values.Get("start")
+	if start == "" {
+		start = event.Postback.Params.Time
+	}
+	startMinute, err := libs.ParseTimeOfDay(start)
+	if err != nil {
+		return a.answer(ctx, ch, event, []interface{}{textMessage("請選擇提醒的時間，例如 08:00。")})
+	}
+
+	if values.Get("end") == "" {
+		quickReply := libs.QuickReplyTemplate{}
+		for _, window := range reminderWindows {
+			endMinute := min(startMinute+int(window/time.Minute), 24*60-1)
+			label := fmt.Sprintf("%s-%s", libs.FormatTimeOfDay(startMinute), libs.FormatTimeOfDay(endMinute))
+			quickReply.Items = append(quickReply.Items, libs.QuickReplyItemTemplate{
+				Type: "action",
+				Action: libs.ActionTemplate{
+					Type:        libs.PostbackAction,
+					Label:       label,
+					Data:        libs.ReminderPostback(location, start, libs.FormatTimeOfDay(endMinute)),
+					DisplayText: "在 " + label + " 提醒我",
+				},
+			})
+		}
+
+		return a.answer(ctx, ch, event, []interface{}{
+			map[string]interface{}{
+				"type":       "text",
+				"text":       fmt.Sprintf("要在 %s 之後多久內提醒您？", start),
+				"quickReply": quickReply,
+			},
+		})
+	}
+
+	endMinute, err := libs.ParseTimeOfDay(values.Get("end"))
+	reminder := libs.Reminder{
+		// a reminder per user, saving another one replaces it
+		Id:          "user-" + libs.HashUserId(event.Source.UserId),
+		UserId:      event.Source.UserId,
+		Channel:     ch.name,
+		Latitude:    location.Latitude,
+		Longitude:   location.Longitude,
+		StartMinute: startMinute,
+		EndMinute:   endMinute,
+	}
+	if err == nil {
+		err = reminder.Validate()
+	}
+	if err != nil {
+		libs.LoggerFromContext(ctx).Warn("invalid reminder window", "error", err)
+		return a.answer(ctx, ch, event, []interface{}{textMessage("請選擇提醒的時間，例如 08:00。")})
+	}
+
+	if _, err := a.reminders.Save(ctx, reminder); err != nil {
+		return nil, err
+	}
+
+	return a.answer(ctx, ch, event, []interface{}{
+		textMessage(fmt.Sprintf("已設定每天 %s-%s 提醒您這附近營運中的充電站，重新設定會取代這個提醒。", libs.FormatTimeOfDay(startMinute), libs.FormatTimeOfDay(endMinute))),
+	})
+}
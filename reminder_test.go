@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/libs"
+	"ohohestudio/sogorro/linefake"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunReminders(t *testing.T) {
+	app, fake, _ := newConversation(t)
+	taipei, _ := time.LoadLocation("Asia/Taipei")
+	app.location = taipei
+	app.schedulerToken = "scheduler-token"
+	app.stations = memoryStations{
+		"office": {Id: "office", Location: "Office", Latitude: 25.0478, Longitude: 121.5170, State: libs.ActiveState},
+	}
+
+	reminders := libs.NewMemoryReminderStore()
+	app.reminders = reminders
+	// Monday 2026-10-19
+	reminders.Save(context.TODO(), libs.Reminder{UserId: "commuter", Channel: "fake", Label: "公司", Latitude: 25.0478, Longitude: 121.5170, StartMinute: 8*60 + 10, EndMinute: 8*60 + 40, Weekdays: []int{1, 2, 3, 4, 5}})
+	reminders.Save(context.TODO(), libs.Reminder{UserId: "weekend-rider", Channel: "fake", Latitude: 25.0478, Longitude: 121.5170, StartMinute: 8 * 60, EndMinute: 9 * 60, Weekdays: []int{0, 6}})
+	reminders.Save(context.TODO(), libs.Reminder{UserId: "evening-rider", Channel: "fake", Latitude: 25.0478, Longitude: 121.5170, StartMinute: 18 * 60, EndMinute: 19 * 60})
+	app.now = func() time.Time { return time.Date(2026, 10, 19, 0, 15, 0, 0, time.UTC) }
+
+	bot := httptest.NewServer(app.router())
+	defer bot.Close()
+
+	run := func(token string) (int, map[string]int) {
+		req, _ := http.NewRequest(http.MethodPost, bot.URL+"/cron/reminders", nil)
+		req.Header.Set(schedulerTokenHeader, token)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		result := map[string]int{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, _ := run("wrong-token")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, result := run("scheduler-token")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]int{"due": 1, "sent": 1, "skipped": 0, "failed": 0}, result)
+
+	// the next run is still in the window, the reminder is only sent once a day
+	app.now = func() time.Time { return time.Date(2026, 10, 19, 0, 20, 0, 0, time.UTC) }
+	status, result = run("scheduler-token")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, result["skipped"])
+
+	assert.Empty(t, fake.Rejections())
+	messages := fake.MessagesTo("commuter")
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "充電提醒：公司附近營運中的充電站", messages[0].Text)
+	assert.Equal(t, "flex", messages[1].Type)
+	assert.Equal(t, "carousel", messages[1].Raw["contents"].(map[string]interface{})["type"])
+	assert.Empty(t, fake.MessagesTo("weekend-rider"))
+	assert.Empty(t, fake.MessagesTo("evening-rider"))
+}
+
+func TestConversationSaveReminder(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+	app.stations = memoryStations{
+		"office": {Id: "office", Location: "Office", Latitude: 25.0478, Longitude: 121.5170, State: libs.ActiveState},
+	}
+	reminders := libs.NewMemoryReminderStore()
+	app.reminders = reminders
+	app.schedulerToken = "scheduler-token"
+	office := libs.MapPoint{Latitude: 25.0478, Longitude: 121.517}
+
+	// deliver answers a single event, the queue is drained to read the answer and replaced
+	deliver := func(event libs.WebhookEvent) linefake.Message {
+		fake.Reset()
+		resp, err := fake.Deliver(webhookURL, event)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.NoError(t, app.queue.drain(context.TODO()))
+		app.queue = newEventQueue(1, 10, app.processEvent)
+		assert.Empty(t, fake.Rejections())
+
+		messages := fake.MessagesTo("commuter")
+		assert.Len(t, messages, 1)
+		return messages[0]
+	}
+	quickReplyAction := func(message linefake.Message, i int) map[string]interface{} {
+		items := message.Raw["quickReply"].(map[string]interface{})["items"].([]interface{})
+		return items[i].(map[string]interface{})["action"].(map[string]interface{})
+	}
+
+	// the search offers a reminder of the shared location
+	picker := quickReplyAction(deliver(fake.LocationEvent("commuter", 25.0478, 121.517)), 0)
+	assert.Equal(t, "datetimepicker", picker["type"])
+	assert.Equal(t, "time", picker["mode"])
+	assert.Equal(t, libs.ReminderPostback(office, "", ""), picker["data"])
+
+	// the picked time is answered with the windows
+	picked := fake.PostbackEvent("commuter", picker["data"].(string))
+	picked.Postback.Params.Time = "08:10"
+	windows := deliver(picked)
+	assert.Len(t, windows.Raw["quickReply"].(map[string]interface{})["items"], len(reminderWindows))
+	hour := quickReplyAction(windows, 1)
+	assert.Equal(t, "08:10-09:10", hour["label"])
+
+	saved := deliver(fake.PostbackEvent("commuter", hour["data"].(string)))
+	assert.Contains(t, saved.Text, "已設定每天 08:10-09:10 提醒您")
+
+	due, err := reminders.Due(context.TODO(), 9*60)
+	assert.NoError(t, err)
+	assert.Equal(t, []libs.Reminder{{
+		Id:          "user-" + libs.HashUserId("commuter"),
+		UserId:      "commuter",
+		Channel:     "fake",
+		Latitude:    25.0478,
+		Longitude:   121.517,
+		StartMinute: 8*60 + 10,
+		EndMinute:   9*60 + 10,
+	}}, due)
+
+	// saving another window replaces the reminder
+	deliver(fake.PostbackEvent("commuter", quickReplyAction(windows, 0)["data"].(string)))
+	due, _ = reminders.Due(context.TODO(), 9*60)
+	assert.Empty(t, due)
+	due, _ = reminders.Due(context.TODO(), 8*60+40)
+	assert.Len(t, due, 1)
+
+	// a window ending before its start is not saved
+	invalid := deliver(fake.PostbackEvent("commuter", libs.ReminderPostback(office, "09:00", "08:00")))
+	assert.Contains(t, invalid.Text, "請選擇提醒的時間")
+}
+
+func TestRunRemindersWithinPushBudget(t *testing.T) {
+	app, fake, _ := newConversation(t)
+	app.location = time.UTC
+	app.schedulerToken = "scheduler-token"
+	app.stations = memoryStations{
+		"office": {Id: "office", Location: "Office", Latitude: 25.0478, Longitude: 121.5170, State: libs.ActiveState},
+	}
+
+	// the budget leaves room for a single push until the next refresh
+	fake.SetQuota(2)
+	ch := app.defaultChannel
+	ch.quota = libs.NewQuotaTracker(ch.name, fake.QuotaEndpoint(), ch.tokens, 0.5, time.Minute)
+	assert.NoError(t, ch.quota.Refresh(context.TODO()))
+
+	reminders := libs.NewMemoryReminderStore()
+	app.reminders = reminders
+	for _, userId := range []string{"commuter-a", "commuter-b"} {
+		reminders.Save(context.TODO(), libs.Reminder{UserId: userId, Channel: "fake", Latitude: 25.0478, Longitude: 121.5170, StartMinute: 8 * 60, EndMinute: 9 * 60})
+	}
+	app.now = func() time.Time { return time.Date(2026, 10, 19, 8, 15, 0, 0, time.UTC) }
+
+	req := httptest.NewRequest(http.MethodPost, "/cron/reminders", nil)
+	req.Header.Set(schedulerTokenHeader, "scheduler-token")
+	rec := httptest.NewRecorder()
+	app.router().ServeHTTP(rec, req)
+
+	result := map[string]int{}
+	json.NewDecoder(rec.Body).Decode(&result)
+	assert.Equal(t, map[string]int{"due": 2, "sent": 1, "skipped": 1, "failed": 0}, result)
+	assert.Empty(t, fake.Rejections())
+}
@@ -1,8 +1,17 @@
 export GOOGLE_CLOUD_PROJECT=""
 export GOOGLE_APPLICATION_CREDENTIALS=""
+# secrets are read from environment variables, use SECRET_BACKEND=secretmanager to read them from Secret Manager
+export SECRET_BACKEND="env"
 export SECRET_PROJECT_ID=""
-export SECRET_NAME=""
+export SECRET_NAME="LINEBOT_ACCESS_TOKEN"
+export LINEBOT_ACCESS_TOKEN=""
+export CHANNEL_SECRET_NAME="LINEBOT_CHANNEL_SECRET"
+export LINEBOT_CHANNEL_SECRET=""
+export SCHEDULER_TOKEN_NAME="SCHEDULER_TOKEN"
+export SCHEDULER_TOKEN="local-scheduler-token"
 export PORT=8080
+export LOG_LEVEL="debug"
+export OTEL_TRACES_EXPORTER="stdout"
 export LINE_API_ENDPOINT="https://api.line.me/v2/bot/message/push"
 
-go run main.go
\ No newline at end of file
+go run .
\ No newline at end of file
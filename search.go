@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"ohohestudio/sogorro/libs"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// maxSearchLimit is the number of stations a search returns at most.
+const maxSearchLimit = 50
+
+// routingCandidates is the number of nearest stations re-ranked by travel time at least, so a
+// station a little further away but quicker to reach makes it into the results.
+const routingCandidates = 10
+
+// stationQuery is a search of the nearest stations, shared by the bot, the reminders and the REST API.
+type stationQuery struct {
+	Latitude  float64
+	Longitude float64
+	Limit     int
+	// VMType only keeps the stations of the type when set, like 3 for the Super GoStations.
+	VMType int64
+	// Source tells the searches of the bot, the reminders and the API apart in the metrics.
+	Source string
+}
+
+// searchStations returns the nearest active stations of the query, nearest first.
+func (a *App) searchStations(ctx context.Context, query stationQuery) ([]libs.GoStation, error) {
+	limit := query.Limit
+	if a.routing != nil && limit < routingCandidates {
+		limit = routingCandidates
+	}
+	if query.VMType != 0 {
+		// the stations of other types are filtered out, search past them
+		limit = maxSearchLimit
+	}
+
+	stations, err := a.stations.Nearby(ctx, query.Latitude, query.Longitude, limit)
+	if err != nil {
+		libs.StationSearches.Add(ctx, 1, metric.WithAttributes(attribute.String("result", "error"), attribute.String("source", query.Source)))
+		return nil, fmt.Errorf("failed to find nearby stations: %v", err)
+	}
+
+	if query.VMType != 0 {
+		matching := stations[:0]
+		for _, station := range stations {
+			if station.VMType == query.VMType {
+				matching = append(matching, station)
+			}
+		}
+		stations = matching
+	}
+	if a.routing != nil {
+		stations = a.rankByTravel(ctx, libs.MapPoint{Latitude: query.Latitude, Longitude: query.Longitude}, stations, max(query.Limit, routingCandidates))
+	}
+	if len(stations) > query.Limit {
+		stations = stations[:query.Limit]
+	}
+
+	result := "found"
+	if len(stations) == 0 {
+		result = "not_found"
+	}
+	libs.StationSearches.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result), attribute.String("source", query.Source)))
+
+	return stations, nil
+}
+
+// rankByTravel estimates the travel to the first candidates of the nearest stations and orders them
+// by travel time, the stations past them stay in straight-line order. The order is left untouched
+// when the travel can't be estimated.
+func (a *App) rankByTravel(ctx context.Context, origin libs.MapPoint, stations []libs.GoStation, candidates int) []libs.GoStation {
+	if len(stations) < candidates {
+		candidates = len(stations)
+	}
+
+	destinations := make([]libs.MapPoint, 0, candidates)
+	for _, station := range stations[:candidates] {
+		destinations = append(destinations, libs.MapPoint{Latitude: station.Latitude, Longitude: station.Longitude})
+	}
+
+	routes, err := a.routing.Routes(ctx, origin, destinations)
+	if err != nil || len(routes) != candidates {
+		libs.LoggerFromContext(ctx).Warn("failed to estimate travel to stations", "error", err, "routes", len(routes))
+		return stations
+	}
+
+	ranked := make([]int, candidates)
+	for i := range ranked {
+		ranked[i] = i
+		stations[i].TravelDistance = routes[i].Distance
+		stations[i].TravelMinutes = routes[i].Minutes()
+	}
+	sort.SliceStable(ranked, func(j, k int) bool { return routes[ranked[j]].Duration < routes[ranked[k]].Duration })
+
+	ordered := make([]libs.GoStation, 0, len(stations))
+	for _, i := range ranked {
+		ordered = append(ordered, stations[i])
+	}
+
+	return append(ordered, stations[candidates:]...)
+}
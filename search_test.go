@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"ohohestudio/sogorro/libs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// routesFunc is a RoutingProvider estimating the routes with a function.
+type routesFunc func(origin libs.MapPoint, destinations []libs.MapPoint) ([]libs.Route, error)
+
+func (f routesFunc) Routes(ctx context.Context, origin libs.MapPoint, destinations []libs.MapPoint) ([]libs.Route, error) {
+	return f(origin, destinations)
+}
+
+func TestSearchStationsRankedByTravel(t *testing.T) {
+	stations := memoryStations{
+		"across-river": {Id: "across-river", Latitude: 25.0341, Longitude: 121.5646, VMType: 1, State: libs.ActiveState},
+		"same-bank":    {Id: "same-bank", Latitude: 25.0360, Longitude: 121.5660, VMType: 3, State: libs.ActiveState},
+		"far":          {Id: "far", Latitude: 25.0500, Longitude: 121.5800, VMType: 1, State: libs.ActiveState},
+	}
+	// the nearest station is across the river, a long way around by the roads
+	minutes := map[float64]time.Duration{25.0341: 12 * time.Minute, 25.0360: 3 * time.Minute, 25.0500: 9 * time.Minute}
+
+	tests := []struct {
+		name             string
+		routing          libs.RoutingProvider
+		query            stationQuery
+		expectedStations []string
+		expectedMinutes  []int
+	}{
+		{
+			name:             "straight line without routing",
+			query:            stationQuery{Latitude: 25.0339, Longitude: 121.5645, Limit: 2},
+			expectedStations: []string{"across-river", "same-bank"},
+			expectedMinutes:  []int{0, 0},
+		},
+		{
+			name: "ranked by travel time",
+			routing: routesFunc(func(origin libs.MapPoint, destinations []libs.MapPoint) ([]libs.Route, error) {
+				routes := []libs.Route{}
+				for _, destination := range destinations {
+					routes = append(routes, libs.Route{Distance: 1, Duration: minutes[destination.Latitude]})
+				}
+				return routes, nil
+			}),
+			query:            stationQuery{Latitude: 25.0339, Longitude: 121.5645, Limit: 2},
+			expectedStations: []string{"same-bank", "far"},
+			expectedMinutes:  []int{3, 9},
+		},
+		{
+			name: "straight line when the routing fails",
+			routing: routesFunc(func(origin libs.MapPoint, destinations []libs.MapPoint) ([]libs.Route, error) {
+				return nil, errors.New("connection refused")
+			}),
+			query:            stationQuery{Latitude: 25.0339, Longitude: 121.5645, Limit: 2},
+			expectedStations: []string{"across-river", "same-bank"},
+			expectedMinutes:  []int{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{config: testConfig(), stations: stations, routing: tt.routing}
+
+			result, err := app.searchStations(context.TODO(), tt.query)
+			assert.NoError(t, err)
+
+			ids, travel := []string{}, []int{}
+			for _, station := range result {
+				ids = append(ids, station.Id)
+				travel = append(travel, station.TravelMinutes)
+			}
+			assert.Equal(t, tt.expectedStations, ids)
+			assert.Equal(t, tt.expectedMinutes, travel)
+		})
+	}
+}
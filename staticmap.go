@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"ohohestudio/sogorro/libs"
+
+	"github.com/gorilla/mux"
+)
+
+// mapHero returns the map of the user and the station shown on top of its bubble, nil when no map
+// provider is configured.
+func (a *App) mapHero(user libs.MapPoint, station libs.GoStation) *libs.ImageTemplate {
+	if a.staticMap == nil {
+		return nil
+	}
+
+	mapUrl := a.staticMap.MapUrl(user, libs.MapPoint{Latitude: station.Latitude, Longitude: station.Longitude})
+	if mapUrl == "" {
+		return nil
+	}
+
+	return libs.HeroImage(mapUrl)
+}
+
+// limitMaps throttles the drawing of the maps, past the rate the LINE servers are asked to retry.
+func (a *App) limitMaps(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.mapLimiter != nil && !a.mapLimiter.Allow("").Allowed {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many map requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// stationMap draws the map of the local map provider over the tiles, the station at the location
+// of the path and the user at the from parameter. Only the locations of the service area are drawn.
+func (a *App) stationMap(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	station, err := libs.ParseMapPoint(vars["lat"] + "," + vars["lng"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var user *libs.MapPoint
+	if from := r.URL.Query().Get("from"); from != "" {
+		point, err := libs.ParseMapPoint(from)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		user = &point
+	}
+
+	if !libs.ServiceArea.Contains(station) || (user != nil && !libs.ServiceArea.Contains(*user)) {
+		http.Error(w, "location is out of the service area", http.StatusBadRequest)
+		return
+	}
+
+	var image bytes.Buffer
+	if err := libs.RenderMap(r.Context(), &image, a.mapTiles, station, user); err != nil {
+		libs.LoggerFromContext(r.Context()).Error("failed to render map", "error", err)
+		http.Error(w, "failed to render map", http.StatusInternalServerError)
+		return
+	}
+
+	// the map of a location never changes, LINE and the clients can keep it
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(image.Bytes())
+}
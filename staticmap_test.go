@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"ohohestudio/sogorro/config"
+	"ohohestudio/sogorro/libs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newMapTiles serves a plain tile for every coordinate.
+func newMapTiles(t *testing.T) *libs.MapTiles {
+	var tile bytes.Buffer
+	assert.NoError(t, png.Encode(&tile, image.NewGray(image.Rect(0, 0, 256, 256))))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(tile.Bytes())
+	}))
+	t.Cleanup(server.Close)
+
+	return libs.NewMapTiles(server.URL + "/{z}/{x}/{y}.png")
+}
+
+func TestStationMap(t *testing.T) {
+	cfg := testConfig()
+	cfg.MapProvider = config.LocalMapProvider
+	server := httptest.NewServer((&App{config: cfg, mapTiles: newMapTiles(t)}).router())
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{name: "station and user", path: "/map/25.033964,121.564468.png?from=25.03,121.56", expectedStatus: http.StatusOK},
+		{name: "station alone", path: "/map/25.033964,121.564468.png", expectedStatus: http.StatusOK},
+		{name: "invalid user location", path: "/map/25.033964,121.564468.png?from=home", expectedStatus: http.StatusBadRequest},
+		{name: "out of the map", path: "/map/89.5,121.564468.png", expectedStatus: http.StatusBadRequest},
+		{name: "station out of the service area", path: "/map/35.681236,139.767125.png", expectedStatus: http.StatusBadRequest},
+		{name: "user out of the service area", path: "/map/25.033964,121.564468.png?from=35.681236,139.767125", expectedStatus: http.StatusBadRequest},
+		{name: "not a location", path: "/map/taipei.png", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+				assert.Equal(t, "public, max-age=86400", resp.Header.Get("Cache-Control"))
+				_, err := png.Decode(resp.Body)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStationMapThrottled(t *testing.T) {
+	cfg := testConfig()
+	cfg.MapProvider = config.LocalMapProvider
+	app := &App{config: cfg, mapTiles: newMapTiles(t), mapLimiter: libs.NewRateLimiter(0, 0, 1, 1)}
+	server := httptest.NewServer(app.router())
+	defer server.Close()
+
+	statuses := []int{}
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(server.URL + "/map/25.033964,121.564468.png")
+		assert.NoError(t, err)
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, statuses)
+}
+
+func TestConversationStationMap(t *testing.T) {
+	app, fake, webhookURL := newConversation(t)
+	app.stations = memoryStations{
+		"station-1": {Id: "station-1", Location: "台北101站", Latitude: 25.033964, Longitude: 121.564468, State: libs.ActiveState},
+	}
+	app.staticMap = libs.LocalStaticMap{BaseUrl: "https://sogorro.example.com"}
+
+	resp, err := fake.Deliver(webhookURL, fake.LocationEvent("rider-a", 25.03, 121.56))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.NoError(t, app.queue.drain(context.TODO()))
+
+	messages := fake.MessagesTo("rider-a")
+	assert.Len(t, messages, 1)
+	hero := messages[0].Raw["contents"].(map[string]interface{})["hero"].(map[string]interface{})
+	assert.Equal(t, "https://sogorro.example.com/map/25.033964,121.564468.png?from=25.030000%2C121.560000", hero["url"])
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ohohestudio/sogorro/libs"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// subscriptionsCollection keeps a document per user subscribed to a station.
+const subscriptionsCollection = "subscriptions"
+
+// stationWatchRetry is the delay before the stations listener is restarted after a failure.
+const stationWatchRetry = 30 * time.Second
+
+// maxQuickReplyLabel is the number of characters LINE accepts in a quick reply label.
+const maxQuickReplyLabel = 20
+
+// handlePostback answers the buttons of the station bubbles, the rich menu, the settings and the reminders.
+func (a *App) handlePostback(ctx context.Context, ch *channel, event libs.WebhookEvent) ([]byte, error) {
+	if event.Postback.Data == libs.FavouritesPostback {
+		return a.listSubscriptions(ctx, ch, event)
+	}
+	if event.Postback.Data == libs.SettingsPostback {
+		return a.navigationSettings(ctx, ch, event)
+	}
+
+	action, stationId := libs.ParsePostback(event.Postback.Data)
+	if action == libs.NavigationAction {
+		return a.chooseNavigation(ctx, ch, event)
+	}
+	if action == libs.ReminderAction {
+		return a.saveReminder(ctx, ch, event)
+	}
+	if (action != libs.SubscribeAction && action != libs.UnsubscribeAction) || stationId == "" {
+		return a.answer(ctx, ch, event, []interface{}{welcomeMessage(ch)})
+	}
+
+	station, err := a.stations.Get(ctx, stationId)
+	if errors.Is(err, libs.ErrStationNotFound) {
+		return a.answer(ctx, ch, event, []interface{}{textMessage("抱歉，找不到這個充電站。")})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if action == libs.SubscribeAction {
+		err := a.subscriptions.Subscribe(ctx, libs.Subscription{UserId: event.Source.UserId, StationId: stationId, Channel: ch.name, NotifiedState: station.State})
+		if err != nil {
+			return nil, err
+		}
+
+		return a.answer(ctx, ch, event, []interface{}{
+			textMessage(fmt.Sprintf("已訂閱「%s」的狀態通知，充電站暫停或恢復服務時會通知您。", station.Location)),
+		})
+	}
+
+	if err := a.subscriptions.Unsubscribe(ctx, event.Source.UserId, stationId); err != nil {
+		return nil, err
+	}
+
+	return a.answer(ctx, ch, event, []interface{}{
+		textMessage(fmt.Sprintf("已取消訂閱「%s」的狀態通知。", station.Location)),
+	})
+}
+
+// listSubscriptions answers the favourites of the rich menu with the subscribed stations, and a
+// quick reply to unsubscribe each of them.
+func (a *App) listSubscriptions(ctx context.Context, ch *channel, event libs.WebhookEvent) ([]byte, error) {
+	subscriptions, err := a.subscriptions.Subscriptions(ctx, event.Source.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subscriptions) == 0 {
+		return a.answer(ctx, ch, event, []interface{}{
+			textMessage("您還沒有訂閱任何充電站，分享位置查詢充電站後，點選「訂閱狀態通知」即可訂閱。"),
+		})
+	}
+
+	lines := []string{"您訂閱的充電站："}
+	quickReply := libs.QuickReplyTemplate{}
+	for _, subscription := range subscriptions {
+		station, err := a.stations.Get(ctx, subscription.StationId)
+		if errors.Is(err, libs.ErrStationNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		state := "服務中"
+		if station.State != libs.ActiveState {
+			state = "暫停服務"
+		}
+		lines = append(lines, fmt.Sprintf("・%s (%s)", station.Location, state))
+
+		if len(quickReply.Items) < 13 {
+			quickReply.Items = append(quickReply.Items, libs.QuickReplyItemTemplate{
+				Type: "action",
+				Action: libs.ActionTemplate{
+					Type:        libs.PostbackAction,
+					Label:       truncate("取消 "+station.Location, maxQuickReplyLabel),
+					Data:        libs.StationPostback(libs.UnsubscribeAction, station.Id),
+					DisplayText: "取消訂閱 " + station.Location,
+				},
+			})
+		}
+	}
+
+	return a.answer(ctx, ch, event, []interface{}{
+		map[string]interface{}{
+			"type":       "text",
+			"text":       strings.Join(lines, "\n"),
+			"quickReply": quickReply,
+		},
+	})
+}
+
+// watchStations notifies the subscribers of the stations changing state, the listener is
+// restarted when it fails until ctx is done.
+func (a *App) watchStations(ctx context.Context) {
+	for {
+		err := libs.WatchSubscribedStations(ctx, a.fs, subscriptionsCollection, a.notifyStationChange)
+		if ctx.Err() != nil {
+			return
+		}
+		libs.LoggerFromContext(ctx).Error("stations listener stopped", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(stationWatchRetry):
+		}
+	}
+}
+
+// notifyStationChange multicasts a message to the users subscribed to a station going offline or
+// back in service, through the channel they subscribed with. Each subscription keeps the state the
+// user was last told of, so the changes missed while no instance listened are notified too and
+// the listeners starting over don't notify them twice.
+func (a *App) notifyStationChange(ctx context.Context, change libs.StationChange) {
+	logger := libs.LoggerFromContext(ctx).With("stationId", change.Station.Id)
+	a.invalidateStation(change.Station.Id)
+
+	subscribers, err := a.subscriptions.Subscribers(ctx, change.Station.Id)
+	if err != nil {
+		logger.Error("failed to get station subscribers", "error", err)
+		return
+	}
+
+	// only the changes in and out of service are worth a message
+	isActive := change.Station.State == libs.ActiveState
+	recipients := map[string][]string{}
+	count := 0
+	for _, subscriber := range subscribers {
+		if subscriber.NotifiedState == 0 {
+			// the state wasn't recorded at the subscription, the user is assumed to know of it
+			if err := a.subscriptions.Notified(ctx, subscriber.UserId, subscriber.StationId, change.Station.State); err != nil {
+				logger.Error("failed to record the station state", "error", err)
+			}
+			continue
+		}
+		if (subscriber.NotifiedState == libs.ActiveState) == isActive {
+			continue
+		}
+
+		recipients[subscriber.Channel] = append(recipients[subscriber.Channel], subscriber.UserId)
+		count++
+	}
+	if count == 0 {
+		return
+	}
+
+	// every instance listens to the stations, the first one to claim the change notifies
+	id := fmt.Sprintf("station-%s-%d", change.Station.Id, change.UpdatedAt.UnixNano())
+	first, err := a.dedupe.Claim(ctx, id)
+	if err != nil {
+		logger.Error("failed to claim station change", "error", err)
+		return
+	}
+	if !first {
+		return
+	}
+
+	state, text := "offline", fmt.Sprintf("您訂閱的「%s」目前暫停服務，請改用其他充電站。", change.Station.Location)
+	if isActive {
+		state, text = "online", fmt.Sprintf("您訂閱的「%s」已恢復服務。", change.Station.Location)
+	}
+
+	// the subscribers left out are notified when the change is seen again, by the listener of
+	// another instance or after a restart, the notified ones are not since their state is recorded
+	unsent := false
+	for name, userIds := range recipients {
+		ch := a.channelNamed(name)
+		if ch == nil {
+			logger.Warn("skip subscribers of unknown channel", "channel", name, "subscribers", len(userIds))
+			continue
+		}
+
+		for _, chunk := range libs.ChunkRecipients(userIds) {
+			if !ch.reserve(len(chunk)) {
+				libs.LinePushesBlocked.Add(ctx, int64(len(chunk)), metric.WithAttributes(attribute.String("channel", ch.name)))
+				logger.Warn("skip station notification over the push budget", "channel", ch.name, "subscribers", len(chunk))
+				unsent = true
+				continue
+			}
+
+			_, err := a.push(ctx, ch, a.config.LineMulticastEndpoint, map[string]interface{}{
+				"to":       chunk,
+				"messages": []interface{}{textMessage(text)},
+			})
+			if err != nil {
+				logger.Error("failed to multicast station notification", "channel", ch.name, "error", err)
+				unsent = true
+				continue
+			}
+			libs.StationNotifications.Add(ctx, int64(len(chunk)), metric.WithAttributes(attribute.String("state", state)))
+
+			for _, userId := range chunk {
+				if err := a.subscriptions.Notified(ctx, userId, change.Station.Id, change.Station.State); err != nil {
+					logger.Error("failed to record the notified state", "error", err)
+				}
+			}
+		}
+	}
+
+	if unsent {
+		if err := a.dedupe.Release(ctx, id); err != nil {
+			logger.Error("failed to release station change", "error", err)
+		}
+	}
+
+	logger.Info("station change notified", "state", state, "subscribers", count)
+}
+
+func textMessage(text string) map[string]string {
+	return map[string]string{"type": "text", "text": text}
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	return string(runes[:length-1]) + "…"
+}